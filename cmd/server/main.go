@@ -3,13 +3,18 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	// Зоны баз (tenant.Timezone) — из встроенной tzdata: бинарник собирается с CGO_ENABLED=0
+	// и идёт под Windows (stdio-режим), где системной базы зон у Go нет.
+	_ "time/tzdata"
 
 	"example.com/mcp-sales-mvp/internal/admin"
 	"example.com/mcp-sales-mvp/internal/api"
@@ -23,7 +28,14 @@ import (
 )
 
 func main() {
+	stdio := flag.Bool("stdio", false, "serve one database over stdin/stdout instead of HTTP (MCP stdio transport)")
+	stdioTenant := flag.String("tenant", "", "database slug for --stdio")
+	flag.Parse()
+
 	log := logger.New()
+	if *stdio {
+		log = logger.NewTo(os.Stderr)
+	}
 
 	configPath := "configs/config.yml"
 	if envPath := os.Getenv("CONFIG_PATH"); envPath != "" {
@@ -50,6 +62,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *stdio {
+		if err := runStdio(cfg, tenantStore, *stdioTenant, log); err != nil {
+			log.Error("stdio mode failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// OAuth AS+RS опционален и общий по настройкам, но экземпляр Server — свой на каждую базу
 	// (у каждой свой issuer, свой audience и свой верификатор ключей). Storage один на всех,
 	// изоляция внутри — по колонке tenant.
@@ -71,7 +91,13 @@ func main() {
 		log.Info("OAuth AS+RS enabled", "public_url", cfg.OAuth.PublicURL)
 	}
 
-	registry := api.NewRegistry(buildTenants(cfg, tenantStore, oauthStorage, log), log)
+	// Сессии MCP общие на гейт и переживают пересборку реестра: правка любой базы в /admin
+	// не должна рвать открытые подключения всех остальных.
+	mcpSessions := mcp.NewSessions()
+
+	registry := api.NewRegistry(buildTenants(cfg, tenantStore, oauthStorage, mcpSessions, log), log)
+	// Пересборка могла поменять набор инструментов: открытые сессии перечитают tools/list.
+	registry.OnReload(func() { mcpSessions.NotifyToolsListChanged() })
 	if err := registry.Reload(context.Background()); err != nil {
 		log.Error("failed to load tenants", "error", err)
 		os.Exit(1)
@@ -157,6 +183,7 @@ func buildTenants(
 	cfg *config.Config,
 	tenantStore *tenant.Store,
 	oauthStorage *oauth.Storage,
+	mcpSessions *mcp.Sessions,
 	log *slog.Logger,
 ) api.BuildFunc {
 	return func(ctx context.Context) ([]*api.Tenant, error) {
@@ -172,16 +199,7 @@ func buildTenants(
 			}
 
 			tlog := log.With("tenant", rec.Slug)
-			onecClient := onec.NewClient(onec.Settings{
-				BaseURL:         rec.BaseURL,
-				Username:        rec.Username,
-				Password:        rec.Password,
-				Timeout:         rec.Timeout(),
-				ReportTimeout:   rec.ReportTimeout(),
-				TenantHeader:    rec.TenantHeader,
-				DefaultTenant:   rec.DefaultTenant,
-				ResolveCacheTTL: rec.ResolveCacheTTL(),
-			}, tlog)
+			onecClient := newOnecClient(rec, tlog)
 
 			t := &api.Tenant{
 				Slug:     rec.Slug,
@@ -231,9 +249,9 @@ func buildTenants(
 			switch {
 			case !cfg.MCP.Enabled:
 			case t.OAuth != nil:
-				t.MCP = mcp.NewHandler(onecClient, cfg, "", tlog)
+				t.MCP = mcp.NewHandler(rec.Slug, onecClient, cfg, "", mcpSessions, tlog)
 			case rec.MCPToken != "":
-				t.MCP = mcp.NewHandler(onecClient, cfg, rec.MCPToken, tlog)
+				t.MCP = mcp.NewHandler(rec.Slug, onecClient, cfg, rec.MCPToken, mcpSessions, tlog)
 			default:
 				tlog.Warn("mcp endpoint not mounted: oauth is disabled and mcp_token is empty")
 			}
@@ -244,6 +262,70 @@ func buildTenants(
 	}
 }
 
+// newOnecClient — клиент 1С по настройкам базы. Общий для HTTP-реестра и stdio-режима.
+func newOnecClient(rec *tenant.Tenant, log *slog.Logger) *onec.Client {
+	loc, err := rec.Location()
+	if err != nil {
+		log.Warn("tenant timezone not loaded, relative periods use the server zone", "error", err, "zone", loc.String())
+	}
+	return onec.NewClient(onec.Settings{
+		BaseURL:         rec.BaseURL,
+		Username:        rec.Username,
+		Password:        rec.Password,
+		Timeout:         rec.Timeout(),
+		ReportTimeout:   rec.ReportTimeout(),
+		TenantHeader:    rec.TenantHeader,
+		DefaultTenant:   rec.DefaultTenant,
+		ResolveCacheTTL: rec.ResolveCacheTTL(),
+		Location:        loc,
+	}, log)
+}
+
+// runStdio обслуживает одну базу по stdin/stdout (--stdio --tenant <slug>): так гейт
+// подключается к desktop MCP-клиенту локальным процессом, без HTTP и OAuth. Лог уходит в stderr.
+//
+// Права: без OAuth — как у статического mcp_token (без ограничений по scope, X-MCP-Scopes в 1С
+// не уходит). С OAuth — локальная админская identity со всеми scope, которые база вообще
+// выдаёт (supported): ACL гейта и заголовок X-MCP-Scopes работают как для обычного
+// пользователя, но без похода за ключом в 1С.
+func runStdio(cfg *config.Config, tenantStore *tenant.Store, slug string, log *slog.Logger) error {
+	if slug == "" {
+		return errors.New("--stdio requires --tenant <slug>")
+	}
+	if !cfg.MCP.Enabled {
+		return errors.New("mcp is disabled in config")
+	}
+
+	ctx := context.Background()
+
+	rec, err := tenantStore.Get(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("tenant %s: %w", slug, err)
+	}
+	if !rec.Enabled {
+		return fmt.Errorf("tenant %s is disabled", slug)
+	}
+
+	tlog := log.With("tenant", rec.Slug, "transport", "stdio")
+	onecClient := newOnecClient(rec, tlog)
+	defer onecClient.Close()
+
+	if cfg.OAuth.Enabled {
+		_, supported := scopesFor(cfg, rec)
+		ctx = oauth.ContextWithAuth(ctx, &oauth.AuthInfo{
+			Sub:    "stdio:local",
+			Scope:  strings.Join(supported, " "),
+			Scopes: supported,
+			Tenant: rec.Slug,
+		})
+	}
+
+	handler := mcp.NewHandler(rec.Slug, onecClient, cfg, "", nil, tlog)
+
+	tlog.Info("serving mcp over stdio")
+	return handler.ServeStdio(ctx, os.Stdin, os.Stdout)
+}
+
 // scopesFor — эффективные списки scope базы: свои, если заданы, иначе глобальные из конфига.
 func scopesFor(cfg *config.Config, rec *tenant.Tenant) (defaults, supported []string) {
 	defaults = rec.DefaultScopes
@@ -267,6 +267,7 @@ func parseForm(r *http.Request) (*tenant.Tenant, error) {
 		Password:      r.PostForm.Get("password"),
 		TenantHeader:  strings.TrimSpace(r.PostForm.Get("tenant_header")),
 		DefaultTenant: strings.TrimSpace(r.PostForm.Get("default_tenant")),
+		Timezone:      strings.TrimSpace(r.PostForm.Get("timezone")),
 		MCPToken:      strings.TrimSpace(r.PostForm.Get("mcp_token")),
 		APIToken:      strings.TrimSpace(r.PostForm.Get("api_token")),
 		DevAccessKey:  strings.TrimSpace(r.PostForm.Get("dev_access_key")),
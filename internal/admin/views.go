@@ -247,6 +247,11 @@ var formTemplate = template.Must(template.New("form").Parse(`<!doctype html>
       <input id="resolve_cache_ttl_sec" name="resolve_cache_ttl_sec" type="number" value="{{.T.ResolveCacheTTLSec}}">
       <span class="hint">Отрицательное значение отключает кэш.</span>
     </div>
+    <div class="field">
+      <label for="timezone">Часовой пояс</label>
+      <input id="timezone" name="timezone" type="text" value="{{.T.Timezone}}" placeholder="Europe/Kyiv" autocomplete="off">
+      <span class="hint">В нём считаются относительные периоды (today, last_month, …). Пусто — пояс сервера гейта.</span>
+    </div>
   </fieldset>
 
   <fieldset>
@@ -54,6 +54,9 @@ type Registry struct {
 	mu      sync.RWMutex
 	byslug  map[string]*Tenant
 	ordered []*Tenant
+
+	// onReload — подписчики пересборки (см. OnReload).
+	onReload []func()
 }
 
 func NewRegistry(build BuildFunc, logger *slog.Logger) *Registry {
@@ -95,9 +98,20 @@ func (r *Registry) Reload(ctx context.Context) error {
 	}
 
 	r.logger.Info("tenant registry reloaded", "count", len(tenants))
+
+	for _, fn := range r.onReload {
+		fn()
+	}
 	return nil
 }
 
+// OnReload подписывает fn на каждую успешную пересборку. Так MCP-сессии узнают, что набор
+// инструментов мог измениться (база выключена, правлены её scope), без зависимости api от mcp.
+// Подписываться нужно до первого Reload: список подписчиков не защищён от гонок.
+func (r *Registry) OnReload(fn func()) {
+	r.onReload = append(r.onReload, fn)
+}
+
 // Get — обвязка базы по слагу. Второй результат false, если базы нет или она выключена
 // (выключенные базы билдер не отдаёт вовсе).
 func (r *Registry) Get(slug string) (*Tenant, bool) {
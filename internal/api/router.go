@@ -87,7 +87,10 @@ func NewRouter(reg *Registry, adminHandler http.Handler, limiters *OAuthLimiters
 		r.With(authorizeMW).Post("/oauth/authorize", reg.Handle(oauthEndpoint((*oauth.Server).HandleAuthorize)))
 		r.With(tokenMW).Post("/oauth/token", reg.Handle(oauthEndpoint((*oauth.Server).HandleToken)))
 
+		// Streamable HTTP: POST — сообщения клиента, GET — SSE-поток сессии, DELETE — её закрытие.
 		r.Post("/mcp", reg.Handle(serveMCP))
+		r.Get("/mcp", reg.Handle(serveMCP))
+		r.Delete("/mcp", reg.Handle(serveMCP))
 
 		r.Post("/resolve/customer", reg.Handle(restEndpoint((*Handler).ResolveCustomer)))
 		r.Post("/resolve/warehouse", reg.Handle(restEndpoint((*Handler).ResolveWarehouse)))
@@ -64,6 +64,9 @@ type RateLimitsConfig struct {
 
 type MCPConfig struct {
 	Enabled bool `yaml:"enabled" env-default:"true"`
+	// ToolsPageSize — сколько инструментов отдаёт одна страница tools/list; дальше клиент идёт
+	// по nextCursor. 0 — весь список одной страницей.
+	ToolsPageSize int `yaml:"tools_page_size" env-default:"0"`
 }
 
 type ServerConfig struct {
@@ -74,6 +77,12 @@ type ServerConfig struct {
 type LimitsConfig struct {
 	ResolveLimit int `yaml:"resolve_limit" env-default:"10"`
 	MaxRows      int `yaml:"max_rows" env-default:"5000"`
+	// PageRows — строк на странице табличного отчёта; остальное клиент забирает по next_cursor
+	// из памяти гейта, не повторяя запрос в 1С. 0 — без страниц, весь результат одним ответом.
+	// По умолчанию страниц нет: клиент, ждущий весь отчёт, не должен молча получить его начало.
+	PageRows int `yaml:"page_rows" env-default:"0"`
+	// PageTTL — сколько гейт держит полный результат для следующих страниц; 0 — страниц нет.
+	PageTTL time.Duration `yaml:"page_ttl" env-default:"10m"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -1,12 +1,19 @@
 package logger
 
 import (
+	"io"
 	"log/slog"
 	"os"
 )
 
 func New() *slog.Logger {
-	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	return NewTo(os.Stdout)
+}
+
+// NewTo — тот же логгер, но в произвольный поток. Нужен stdio-режиму: там stdout занят
+// протоколом MCP, и любая строка лога в нём сломала бы клиенту разбор ответов.
+func NewTo(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
 }
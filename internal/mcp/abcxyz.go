@@ -0,0 +1,337 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// abc_xyz_analysis — классификация номенклатуры, которую категорийные менеджеры каждый месяц
+// собирали в Excel из выгрузки sales_report. Модель в лимит строк её не укладывала: нужен полный
+// срез «товар × месяц», а из него — накопленная доля и разброс по месяцам.
+//
+// ABC — по накопленной доле меры (по умолчанию выручки): товары по убыванию, класс A — пока
+// накопленная доля ДО товара меньше порога a, B — меньше b, остальное C. Товар, пересекающий
+// порог, остаётся в старшем классе: иначе единственный товар с 90% выручки оказался бы B.
+// Товары с нулевой или отрицательной мерой (возвраты) — всегда C.
+//
+// XYZ — по коэффициенту вариации помесячного количества: σ/среднее по всем месяцам периода,
+// месяц без продаж — ноль. CV ≤ x — X, ≤ y — Y, иначе (и без продаж вовсе) — Z.
+//
+// level=product_group классифицирует товарные группы вместо товаров. ABC товаров внутри групп
+// одним вызовом не получить: sales_report молча отбрасывает product_group рядом с product.
+
+const (
+	defaultABCA = 80.0
+	defaultABCB = 95.0
+	defaultXYZX = 10.0
+	defaultXYZY = 25.0
+)
+
+// abcMeasures — меры, по которым считается ABC. profit требует mcp:report:cost — это проверит
+// checkReportCall на вложенном sales_report.
+var abcMeasures = []string{"amount", "qty", "profit"}
+
+// abcLevels — что классифицируется: измерение sales_report.
+var abcLevels = []string{"product", "product_group"}
+
+type abcXYZArgs struct {
+	Period        onec.Period    `json:"period"`
+	Filters       map[string]any `json:"filters"`
+	Measure       string         `json:"measure"`
+	ABCThresholds struct {
+		A flexFloat `json:"a"`
+		B flexFloat `json:"b"`
+	} `json:"abc_thresholds"`
+	XYZThresholds struct {
+		X flexFloat `json:"x"`
+		Y flexFloat `json:"y"`
+	} `json:"xyz_thresholds"`
+	Level string  `json:"level"`
+	Top   flexInt `json:"top"`
+}
+
+// abcItem — один товар (группа): мера для ABC и помесячное количество для XYZ.
+type abcItem struct {
+	ref        any
+	value, qty float64
+	monthly    map[string]float64
+
+	share, cumulative float64
+	cv                any // null без продаж
+	abc, xyz          string
+}
+
+func (h *Handler) callABCXYZAnalysis(ctx context.Context, args any) (*CallToolResult, error) {
+	var a abcXYZArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	measure := a.Measure
+	if measure == "" {
+		measure = "amount"
+	}
+	level := a.Level
+	if level == "" {
+		level = "product"
+	}
+	thA := orDefault(float64(a.ABCThresholds.A), defaultABCA)
+	thB := orDefault(float64(a.ABCThresholds.B), defaultABCB)
+	thX := orDefault(float64(a.XYZThresholds.X), defaultXYZX)
+	thY := orDefault(float64(a.XYZThresholds.Y), defaultXYZY)
+
+	var errs argumentErrors
+	if thA <= 0 || thA >= thB || thB > 100 {
+		errs = append(errs, argumentError{Path: "abc_thresholds", Message: "expected 0 < a < b <= 100"})
+	}
+	if thX <= 0 || thX >= thY {
+		errs = append(errs, argumentError{Path: "xyz_thresholds", Message: "expected 0 < x < y"})
+	}
+	months, err := periodMonths(a.Period)
+	if err != nil {
+		errs = append(errs, argumentError{Path: "period", Message: err.Error()})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	measures := []any{measure}
+	if measure != "qty" {
+		measures = append(measures, "qty")
+	}
+	inner := map[string]any{
+		"period":   map[string]any{"from": a.Period.From, "to": a.Period.To},
+		"group_by": []any{level, "month"},
+		"measures": measures,
+	}
+	if len(a.Filters) > 0 {
+		inner["filters"] = a.Filters
+	}
+	if err := h.checkReportCall(ctx, ToolSalesReport, inner); err != nil {
+		return nil, err
+	}
+	report, err := h.fetchReport(ctx, ToolSalesReport, inner)
+	if err != nil {
+		return nil, err
+	}
+
+	items := collectABCItems(report, level, measure)
+	classifyABC(items, thA, thB)
+	for _, it := range items {
+		classifyXYZ(it, months, thX, thY)
+	}
+
+	var columns []map[string]any
+	for _, c := range []struct{ name, typ string }{
+		{level, "ref"}, {measure, "number"}, {"share_pct", "number"}, {"cumulative_pct", "number"},
+		{"abc", "string"}, {"qty", "number"}, {"cv_pct", "number"}, {"xyz", "string"}, {"class", "string"},
+	} {
+		columns = append(columns, map[string]any{"name": c.name, "type": c.typ})
+	}
+
+	rows := make([][]any, 0, len(items))
+	var totalValue, totalQty float64
+	for _, it := range items {
+		totalValue += it.value
+		totalQty += it.qty
+		rows = append(rows, []any{it.ref, roundTo(it.value, 2), roundTo(it.share, 2), roundTo(it.cumulative, 2),
+			it.abc, roundTo(it.qty, 3), it.cv, it.xyz, it.abc + it.xyz})
+	}
+	summary := abcSummary(items)
+	if top := int(a.Top); top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	resp := map[string]any{
+		"columns": columns,
+		"rows":    rows,
+		"totals": map[string]any{
+			measure: roundTo(totalValue, 2),
+			"qty":   roundTo(totalQty, 3),
+			"items": len(items),
+		},
+		"summary":        summary,
+		"period":         a.Period,
+		"months":         len(months),
+		"measure":        measure,
+		"level":          level,
+		"abc_thresholds": map[string]any{"a": thA, "b": thB},
+		"xyz_thresholds": map[string]any{"x": thX, "y": thY},
+	}
+	if filters, ok := report.fields["applied_filters"]; ok {
+		resp["applied_filters"] = filters
+	}
+	// Срез «позиция × месяц» упёрся в max_rows — классы посчитаны по неполным данным.
+	if len(report.rows) >= h.cfg.Limits.MaxRows {
+		resp["truncated"] = true
+	}
+	return toolResult(resp)
+}
+
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// periodMonths — месяцы периода в виде YYYY-MM: XYZ считает и месяцы без продаж.
+func periodMonths(p onec.Period) ([]string, error) {
+	from, errFrom := time.Parse(time.DateOnly, p.From)
+	to, errTo := time.Parse(time.DateOnly, p.To)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		return nil, errors.New("expected from <= to as YYYY-MM-DD")
+	}
+	var months []string
+	for m := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(to); m = m.AddDate(0, 1, 0) {
+		months = append(months, m.Format("2006-01"))
+	}
+	return months, nil
+}
+
+// collectABCItems сворачивает строки «позиция × месяц» в позиции, упорядоченные по убыванию меры.
+func collectABCItems(t tableReport, level, measure string) []*abcItem {
+	var items []*abcItem
+	byKey := map[string]*abcItem{}
+	for _, row := range t.rows {
+		ref := t.cell(row, level)
+		key := rowKey([]any{ref}, []int{0})
+		it, ok := byKey[key]
+		if !ok {
+			it = &abcItem{ref: ref, monthly: map[string]float64{}}
+			byKey[key] = it
+			items = append(items, it)
+		}
+		qty := numberValue(t.cell(row, "qty"))
+		it.value += numberValue(t.cell(row, measure))
+		it.qty += qty
+		if month := cellText(t.cell(row, "month")); len(month) >= 7 {
+			it.monthly[month[:7]] += qty
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].value != items[j].value {
+			return items[i].value > items[j].value
+		}
+		return strings.ToLower(cellText(items[i].ref)) < strings.ToLower(cellText(items[j].ref))
+	})
+	return items
+}
+
+// classifyABC — классы позиций, уже упорядоченных по убыванию меры.
+func classifyABC(items []*abcItem, a, b float64) {
+	var total float64
+	for _, it := range items {
+		if it.value > 0 {
+			total += it.value
+		}
+	}
+
+	var cumulative float64
+	for _, it := range items {
+		if it.value <= 0 || total == 0 {
+			it.abc = "C"
+			it.cumulative = cumulative
+			continue
+		}
+		before := cumulative
+		it.share = it.value / total * 100
+		cumulative += it.share
+		it.cumulative = cumulative
+		switch {
+		case before < a:
+			it.abc = "A"
+		case before < b:
+			it.abc = "B"
+		default:
+			it.abc = "C"
+		}
+	}
+}
+
+// classifyXYZ — коэффициент вариации помесячного количества (σ генеральной совокупности).
+func classifyXYZ(it *abcItem, months []string, x, y float64) {
+	n := float64(len(months))
+	mean := 0.0
+	for _, m := range months {
+		mean += it.monthly[m]
+	}
+	mean /= n
+	if mean <= 0 {
+		it.xyz = "Z"
+		return
+	}
+
+	var sq float64
+	for _, m := range months {
+		d := it.monthly[m] - mean
+		sq += d * d
+	}
+	cv := math.Sqrt(sq/n) / mean * 100
+	it.cv = roundTo(cv, 2)
+	switch {
+	case cv <= x:
+		it.xyz = "X"
+	case cv <= y:
+		it.xyz = "Y"
+	default:
+		it.xyz = "Z"
+	}
+}
+
+// abcSummary — матрица 3×3: число позиций, сумма меры и её доля в каждой клетке. Пустые клетки
+// тоже в ответе — по матрице видно, что «AZ» пуст, а не забыт. Доля — от суммы положительных
+// значений, как и share_pct в строках.
+func abcSummary(items []*abcItem) map[string]any {
+	type cell struct {
+		items int
+		value float64
+	}
+	var total float64
+	cells := map[string]*cell{}
+	for _, it := range items {
+		if it.value > 0 {
+			total += it.value
+		}
+		c := cells[it.abc+it.xyz]
+		if c == nil {
+			c = &cell{}
+			cells[it.abc+it.xyz] = c
+		}
+		c.items++
+		c.value += it.value
+	}
+
+	var rows [][]any
+	for _, abc := range []string{"A", "B", "C"} {
+		for _, xyz := range []string{"X", "Y", "Z"} {
+			c := cells[abc+xyz]
+			if c == nil {
+				c = &cell{}
+			}
+			var share any
+			if total != 0 {
+				share = roundTo(c.value/total*100, 2)
+			}
+			rows = append(rows, []any{abc + xyz, abc, xyz, c.items, roundTo(c.value, 2), share})
+		}
+	}
+	return map[string]any{
+		"columns": []map[string]any{
+			{"name": "class", "type": "string"},
+			{"name": "abc", "type": "string"},
+			{"name": "xyz", "type": "string"},
+			{"name": "items", "type": "number"},
+			{"name": "value", "type": "number"},
+			{"name": "share_pct", "type": "number"},
+		},
+		"rows": rows,
+	}
+}
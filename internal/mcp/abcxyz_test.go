@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// abcFake — срез «товар × месяц» за январь–март: p1 ровный, p2 скачет, p3 умеренно,
+// p4 продан один раз, p5 — только возврат.
+func abcFake(path string, body map[string]any) string {
+	sales := []struct {
+		id     string
+		amount float64
+		qty    [3]float64
+	}{
+		{"p1", 700, [3]float64{10, 10, 10}},
+		{"p2", 200, [3]float64{5, 0, 10}},
+		{"p3", 60, [3]float64{10, 12, 8}},
+		{"p4", 40, [3]float64{0, 1, 0}},
+		{"p5", -10, [3]float64{-1, 0, 0}},
+	}
+	var rows [][]any
+	for _, s := range sales {
+		amount := s.amount // вся сумма — в первом месяце с продажами
+		for m, qty := range s.qty {
+			if qty == 0 {
+				continue
+			}
+			rows = append(rows, []any{refJSON(s.id, "Product "+s.id), fmt.Sprintf("2026-0%d-01", m+1), amount, qty})
+			amount = 0
+		}
+	}
+	return reportJSON([]string{"product:ref", "month:date", "amount:number", "qty:number"}, rows)
+}
+
+func TestABCXYZAnalysis(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = abcFake
+
+	res := callTool(t, h, ToolABCXYZAnalysis, map[string]any{
+		"period":  map[string]any{"from": "2026-01-01", "to": "2026-03-31"},
+		"filters": map[string]any{"warehouse_ids": []any{"w-1"}},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	body := fake.recorded(t, 0).body
+	if groupBy, _ := json.Marshal(body["group_by"]); string(groupBy) != `["product","month"]` {
+		t.Errorf("group_by = %s", groupBy)
+	}
+	if filters, _ := body["filters"].(map[string]any); filters["warehouse_ids"] == nil {
+		t.Errorf("filters not forwarded: %v", body["filters"])
+	}
+
+	var got struct {
+		Rows    [][]any
+		Months  int
+		Summary struct{ Rows [][]any }
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Months != 3 {
+		t.Errorf("months = %d", got.Months)
+	}
+
+	// Пересёкший порог p2 (70% → 90%) остаётся A; p3 (90% → 96%) — B; возврат — C без CV.
+	classes := map[string]string{}
+	for _, row := range got.Rows {
+		ref, _ := row[0].(map[string]any)
+		classes[fmt.Sprint(ref["id"])] = fmt.Sprint(row[8])
+	}
+	want := map[string]string{"p1": "AX", "p2": "AZ", "p3": "BY", "p4": "CZ", "p5": "CZ"}
+	for id, class := range want {
+		if classes[id] != class {
+			t.Errorf("%s class = %s, want %s", id, classes[id], class)
+		}
+	}
+	if cv := got.Rows[2][6]; cv != 16.33 {
+		t.Errorf("p3 cv_pct = %v", cv)
+	}
+	if cv := got.Rows[4][6]; cv != nil {
+		t.Errorf("p5 cv_pct = %v, want null", cv)
+	}
+
+	// Матрица — все девять клеток; CZ — две позиции, 40 − 10 из 1000 положительной выручки.
+	if len(got.Summary.Rows) != 9 {
+		t.Fatalf("summary rows = %d", len(got.Summary.Rows))
+	}
+	if cz := got.Summary.Rows[8]; cz[0] != "CZ" || cz[3] != 2.0 || cz[5] != 3.0 {
+		t.Errorf("CZ cell = %v", cz)
+	}
+}
+
+// Кривые пороги — ошибка аргументов до 1С; profit без права на себестоимость — отказ.
+func TestABCXYZAnalysisRejects(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = abcFake
+	period := map[string]any{"from": "2026-01-01", "to": "2026-03-31"}
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name":      ToolABCXYZAnalysis,
+		"arguments": map[string]any{"period": period, "abc_thresholds": map[string]any{"a": 90, "b": 80}},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, "abc_thresholds") {
+		t.Errorf("thresholds: %+v", res.Error)
+	}
+
+	res = rpc(t, h, withScopes("mcp:report:sales"), "tools/call", map[string]any{
+		"name":      ToolABCXYZAnalysis,
+		"arguments": map[string]any{"period": period, "measure": "profit"},
+	})
+	if !strings.Contains(string(res.Result), "permission denied") {
+		t.Errorf("profit without cost scope: %s", res.Result)
+	}
+
+	if fake.count() != 0 {
+		t.Errorf("rejected calls reached 1C %d times", fake.count())
+	}
+}
@@ -0,0 +1,343 @@
+package mcp
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// detect_anomalies — утренний «что вчера было необычного?» без того, чтобы модель разглядывала
+// девяносто строк по дням. Гейт берёт дневной ряд отчёта (sales_report или cash_flow, с одним
+// необязательным измерением) и сравнивает проверяемые дни с базой — днями до них.
+//
+// База устойчивая: медиана и MAD (медиана абсолютных отклонений), а не среднее и σ — один
+// прошлый выброс (распродажа, инкассация за неделю) не должен делать нормой следующие. Сезонность
+// по дням недели: понедельник сравнивается с понедельниками, пока их в базе хотя бы
+// minWeekdaySamples; иначе — со всеми днями. Оценка — модифицированный z (Iglewicz–Hoaglin):
+// 0.6745·(x − медиана)/MAD; при MAD = 0 — (x − медиана)/(1.2533·среднее абсолютное отклонение).
+// База без разброса вовсе (ряд из одних нулей) — отклонение помечается без оценки: score = null.
+//
+// День без строки в отчёте — ноль: в свёртках 1С пустота означает отсутствие оборота. Поэтому
+// обрезанный по max_rows ответ нельзя считать: пропавшие строки стали бы нулями и ложными
+// провалами. Проверяемые дни и база запрашиваются двумя вызовами — упёршийся в лимит отклоняется
+// ошибкой аргумента с подсказкой, что сузить, а не считается по неполным строкам.
+//
+// Инструмент виден с правом на любой из отчётов (toolAnyScopes), право на выбранный отчёт
+// проверяет checkReportCall.
+
+const (
+	defaultAnomalyLookback  = 90
+	defaultAnomalyThreshold = 3.5
+	minWeekdaySamples       = 4
+	maxAnomalyCheckDays     = 31
+)
+
+// anomalyReport — отчёт, по которому ищутся аномалии: его меры и измерения для разреза.
+// Меры себестоимости не предлагаются — дневной ряд маржи слишком шумный для такой базы.
+type anomalyReport struct {
+	measures   []string
+	dimensions []string
+}
+
+var anomalyReports = map[string]anomalyReport{
+	ToolSalesReport: {
+		measures:   []string{"amount", "qty", "receipts", "customers"},
+		dimensions: []string{"warehouse", "sales_channel", "product_group", "customer_group", "seller"},
+	},
+	ToolCashFlow: {
+		measures:   []string{"net", "inflow", "outflow"},
+		dimensions: []string{"account", "operation", "firm"},
+	},
+}
+
+// anomalyDirections — какие отклонения нужны: оба, только рост или только провал.
+var anomalyDirections = []string{"both", "high", "low"}
+
+type detectAnomaliesArgs struct {
+	Report       string         `json:"report"`
+	Measure      string         `json:"measure"`
+	Dimension    string         `json:"dimension"`
+	Filters      map[string]any `json:"filters"`
+	Date         string         `json:"date"`
+	CheckDays    flexInt        `json:"check_days"`
+	LookbackDays flexInt        `json:"lookback_days"`
+	Threshold    flexFloat      `json:"threshold"`
+	Direction    string         `json:"direction"`
+	Top          flexInt        `json:"top"`
+}
+
+// anomaly — отмеченный день одного ряда.
+type anomaly struct {
+	ref      any
+	day      time.Time
+	actual   float64
+	expected float64
+	score    *float64
+}
+
+func (h *Handler) callDetectAnomalies(ctx context.Context, args any) (*CallToolResult, error) {
+	var a detectAnomaliesArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	report := a.Report
+	if report == "" {
+		report = ToolSalesReport
+	}
+	spec := anomalyReports[report]
+	measure := a.Measure
+	if measure == "" {
+		measure = spec.measures[0]
+	}
+	lookback := int(a.LookbackDays)
+	if lookback == 0 {
+		lookback = defaultAnomalyLookback
+	}
+	checkDays := int(a.CheckDays)
+	if checkDays == 0 {
+		checkDays = 1
+	}
+	threshold := float64(a.Threshold)
+	if threshold == 0 {
+		threshold = defaultAnomalyThreshold
+	}
+	direction := a.Direction
+	if direction == "" {
+		direction = "both"
+	}
+
+	now := h.onecClient.Now()
+	last := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+
+	var errs argumentErrors
+	if !containsString(spec.measures, measure) {
+		errs = append(errs, argumentError{Path: "measure", Message: "not a measure of " + report, Allowed: spec.measures})
+	}
+	if a.Dimension != "" && !containsString(spec.dimensions, a.Dimension) {
+		errs = append(errs, argumentError{Path: "dimension", Message: "not a dimension of " + report, Allowed: spec.dimensions})
+	}
+	if a.Date != "" {
+		d, err := time.Parse(time.DateOnly, a.Date)
+		if err != nil {
+			errs = append(errs, argumentError{Path: "date", Message: "expected YYYY-MM-DD"})
+		}
+		last = d
+	}
+	if lookback < minWeekdaySamples*7 || lookback > 365 {
+		errs = append(errs, argumentError{Path: "lookback_days", Message: "expected 28..365"})
+	}
+	if checkDays < 1 || checkDays > maxAnomalyCheckDays {
+		errs = append(errs, argumentError{Path: "check_days", Message: "expected 1..31"})
+	}
+	if threshold <= 0 {
+		errs = append(errs, argumentError{Path: "threshold", Message: "expected a positive number"})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// База — lookback_days дней перед проверяемыми, проверяемые — check_days дней по date.
+	checkFrom := last.AddDate(0, 0, 1-checkDays)
+	from := checkFrom.AddDate(0, 0, -lookback)
+
+	groupBy := []any{"day"}
+	if a.Dimension != "" {
+		groupBy = []any{a.Dimension, "day"}
+	}
+	call := func(from, to time.Time) reportCall {
+		args := map[string]any{
+			"period":   map[string]any{"from": from.Format(time.DateOnly), "to": to.Format(time.DateOnly)},
+			"group_by": groupBy,
+			"measures": []any{measure},
+		}
+		if len(a.Filters) > 0 {
+			args["filters"] = a.Filters
+		}
+		return reportCall{report, args}
+	}
+	calls := []reportCall{call(checkFrom, last), call(from, checkFrom.AddDate(0, 0, -1))}
+	if err := h.checkReportCalls(ctx, calls); err != nil {
+		// Из вложенного отчёта схемой проверяются только filters — путь от аргументов инструмента.
+		if fieldErrs, ok := err.(argumentErrors); ok {
+			for i := range fieldErrs {
+				fieldErrs[i].Path = strings.TrimPrefix(fieldErrs[i].Path, "arguments.")
+			}
+		}
+		return nil, err
+	}
+	reports, err := h.fetchReports(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+	hint := "add filters"
+	if a.Dimension != "" {
+		hint = "add filters or drop dimension"
+	}
+	switch {
+	case len(reports[0].rows) >= h.cfg.Limits.MaxRows:
+		return nil, argumentErrors{{Path: "check_days", Message: "the checked days hit the row limit, so some series are incomplete; check fewer days or " + hint}}
+	case len(reports[1].rows) >= h.cfg.Limits.MaxRows:
+		return nil, argumentErrors{{Path: "lookback_days", Message: "the baseline hit the row limit, so some series are incomplete; use fewer lookback_days or " + hint}}
+	}
+
+	// Ряды: значение измерения → день → значение меры.
+	type series struct {
+		ref    any
+		values map[string]float64
+	}
+	var order []string
+	all := map[string]*series{}
+	for _, t := range reports {
+		for _, row := range t.rows {
+			var ref any
+			if a.Dimension != "" {
+				ref = t.cell(row, a.Dimension)
+			}
+			key := rowKey([]any{ref}, []int{0})
+			s, ok := all[key]
+			if !ok {
+				s = &series{ref: ref, values: map[string]float64{}}
+				all[key] = s
+				order = append(order, key)
+			}
+			s.values[firstN(cellText(t.cell(row, "day")), 10)] += numberValue(t.cell(row, measure))
+		}
+	}
+
+	var found []anomaly
+	for _, key := range order {
+		s := all[key]
+		for day := checkFrom; !day.After(last); day = day.AddDate(0, 0, 1) {
+			var baseline, sameWeekday []float64
+			for d := from; d.Before(checkFrom); d = d.AddDate(0, 0, 1) {
+				v := s.values[d.Format(time.DateOnly)]
+				baseline = append(baseline, v)
+				if d.Weekday() == day.Weekday() {
+					sameWeekday = append(sameWeekday, v)
+				}
+			}
+			if len(sameWeekday) >= minWeekdaySamples {
+				baseline = sameWeekday
+			}
+
+			actual := s.values[day.Format(time.DateOnly)]
+			expected, score := robustScore(actual, baseline)
+			if actual == expected {
+				continue
+			}
+			if score != nil && math.Abs(*score) < threshold {
+				continue
+			}
+			if (direction == "high" && actual < expected) || (direction == "low" && actual > expected) {
+				continue
+			}
+			found = append(found, anomaly{ref: s.ref, day: day, actual: actual, expected: expected, score: score})
+		}
+	}
+
+	// Сильнее отклонение — выше; отклонение от ряда без разброса (score = null) — первым.
+	sort.SliceStable(found, func(i, j int) bool {
+		si, sj := found[i].score, found[j].score
+		switch {
+		case si == nil || sj == nil:
+			return si == nil && sj != nil
+		default:
+			return math.Abs(*si) > math.Abs(*sj)
+		}
+	})
+
+	var columns []map[string]any
+	if a.Dimension != "" {
+		columns = append(columns, map[string]any{"name": a.Dimension, "type": "ref"})
+	}
+	for _, c := range []struct{ name, typ string }{
+		{"day", "date"}, {"weekday", "string"}, {"actual", "number"}, {"expected", "number"},
+		{"deviation", "number"}, {"deviation_pct", "number"}, {"score", "number"}, {"direction", "string"},
+	} {
+		columns = append(columns, map[string]any{"name": c.name, "type": c.typ})
+	}
+
+	rows := make([][]any, 0, len(found))
+	for _, f := range found {
+		var row []any
+		if a.Dimension != "" {
+			row = append(row, f.ref)
+		}
+		var pct, score any
+		if f.expected != 0 {
+			pct = roundTo((f.actual-f.expected)/math.Abs(f.expected)*100, 1)
+		}
+		if f.score != nil {
+			score = roundTo(*f.score, 2)
+		}
+		dir := "high"
+		if f.actual < f.expected {
+			dir = "low"
+		}
+		rows = append(rows, append(row, f.day.Format(time.DateOnly), strings.ToLower(f.day.Weekday().String()),
+			roundTo(f.actual, 2), roundTo(f.expected, 2), roundTo(f.actual-f.expected, 2), pct, score, dir))
+	}
+	if top := int(a.Top); top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	resp := map[string]any{
+		"columns":       columns,
+		"rows":          rows,
+		"report":        report,
+		"measure":       measure,
+		"period":        map[string]any{"from": from.Format(time.DateOnly), "to": last.Format(time.DateOnly)},
+		"checked":       map[string]any{"from": checkFrom.Format(time.DateOnly), "to": last.Format(time.DateOnly)},
+		"lookback_days": lookback,
+		"threshold":     threshold,
+		"series":        len(order),
+		"totals":        map[string]any{"anomalies": len(found)},
+	}
+	if a.Dimension != "" {
+		resp["dimension"] = a.Dimension
+	}
+	if filters, ok := reports[0].fields["applied_filters"]; ok {
+		resp["applied_filters"] = filters
+	}
+	return toolResult(resp)
+}
+
+// robustScore — ожидаемое значение (медиана базы) и модифицированный z дня. score = nil, когда у
+// базы нет разброса и оценка не определена.
+func robustScore(actual float64, baseline []float64) (float64, *float64) {
+	if len(baseline) == 0 {
+		return 0, nil
+	}
+	m := median(baseline)
+	deviations := make([]float64, len(baseline))
+	var meanAbs float64
+	for i, v := range baseline {
+		deviations[i] = math.Abs(v - m)
+		meanAbs += deviations[i]
+	}
+	meanAbs /= float64(len(baseline))
+
+	var score float64
+	switch mad := median(deviations); {
+	case mad > 0:
+		score = 0.6745 * (actual - m) / mad
+	case meanAbs > 0:
+		score = (actual - m) / (1.2533 * meanAbs)
+	default:
+		return m, nil
+	}
+	return m, &score
+}
+
+func median(values []float64) float64 {
+	s := append([]float64(nil), values...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// anomalyDay — проверяемый день anomaliesFake.
+const anomalyDay = "2026-03-31"
+
+// anomaliesFake — дневные продажи трёх складов за запрошенный период: w1 ровный и взлетает в
+// anomalyDay, w2 ровный и остаётся ровным, w3 впервые продаёт в anomalyDay.
+func anomaliesFake(path string, body map[string]any) string {
+	period, _ := body["period"].(map[string]any)
+	from, _ := time.Parse(time.DateOnly, fmt.Sprint(period["from"]))
+	to, _ := time.Parse(time.DateOnly, fmt.Sprint(period["to"]))
+
+	var rows [][]any
+	row := func(w string, d time.Time, v float64) {
+		rows = append(rows, []any{refJSON(w, "Warehouse "+w), d.Format(time.DateOnly), v})
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		last, i := d.Format(time.DateOnly) == anomalyDay, d.YearDay()
+		row("w1", d, pick(last, 300, float64(100+i%3)))
+		row("w2", d, pick(last, 51, float64(50+i%3)))
+		if last {
+			row("w3", d, 20)
+		}
+	}
+	return reportJSON([]string{"warehouse:ref", "day:date", "amount:number"}, rows)
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = anomaliesFake
+
+	res := callTool(t, h, ToolDetectAnomalies, map[string]any{
+		"dimension":     "warehouse",
+		"date":          anomalyDay,
+		"lookback_days": 28,
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	// Проверяемый день и база — отдельными вызовами, в любом порядке.
+	var periods []string
+	for i := range fake.count() {
+		body := fake.recorded(t, i).body
+		period, _ := json.Marshal(body["period"])
+		periods = append(periods, string(period))
+		if groupBy, _ := json.Marshal(body["group_by"]); string(groupBy) != `["warehouse","day"]` {
+			t.Errorf("group_by = %s", groupBy)
+		}
+	}
+	slices.Sort(periods)
+	if strings.Join(periods, " ") != `{"from":"2026-03-03","to":"2026-03-30"} {"from":"2026-03-31","to":"2026-03-31"}` {
+		t.Errorf("periods = %v", periods)
+	}
+
+	var got struct{ Rows [][]any }
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	// w3 — база из нулей, оценки нет, но отклонение есть — первым; w1 — всплеск; w2 — норма.
+	if len(got.Rows) != 2 {
+		t.Fatalf("rows = %v", got.Rows)
+	}
+	if id, _, _ := refCell(got.Rows[0][0]); id != "w3" || got.Rows[0][7] != nil {
+		t.Errorf("first row = %v, want w3 with score null", got.Rows[0])
+	}
+	w1 := got.Rows[1]
+	if id, _, _ := refCell(w1[0]); id != "w1" || w1[1] != "2026-03-31" || w1[2] != "tuesday" ||
+		w1[3] != 300.0 || w1[8] != "high" {
+		t.Errorf("w1 row = %v", w1)
+	}
+	if score, _ := w1[7].(float64); score < 3.5 {
+		t.Errorf("w1 score = %v", w1[7])
+	}
+}
+
+// Мера и измерение — из списка выбранного отчёта; ошибки до похода в 1С.
+func TestDetectAnomaliesRejects(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name": ToolDetectAnomalies,
+		"arguments": map[string]any{
+			"measure":       "inflow",
+			"dimension":     "account",
+			"lookback_days": 10,
+		},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams {
+		t.Fatalf("error = %+v", res.Error)
+	}
+	for _, want := range []string{"measure: not a measure of sales_report", "dimension: not a dimension of sales_report", "lookback_days"} {
+		if !strings.Contains(res.Error.Message, want) {
+			t.Errorf("message %q lacks %q", res.Error.Message, want)
+		}
+	}
+	if fake.count() != 0 {
+		t.Errorf("rejected call reached 1C %d times", fake.count())
+	}
+}
+
+// Ответ, упёршийся в max_rows, не считается: пропавшие строки стали бы нулями и ложными провалами.
+// Отказ указывает, какое окно сузить.
+func TestDetectAnomaliesTruncated(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = anomaliesFake
+
+	cases := []struct {
+		maxRows int
+		want    string
+	}{
+		{3, "check_days: the checked days hit the row limit"}, // проверяемый день — 3 строки
+		{40, "lookback_days: the baseline hit the row limit"}, // база — 28 дней × 2 склада
+	}
+	for _, c := range cases {
+		h.cfg.Limits.MaxRows = c.maxRows
+		res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+			"name":      ToolDetectAnomalies,
+			"arguments": map[string]any{"dimension": "warehouse", "date": anomalyDay, "lookback_days": 28},
+		})
+		if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, c.want) {
+			t.Errorf("max_rows %d: error = %+v", c.maxRows, res.Error)
+		}
+	}
+}
+
+// Инструмент виден с правом на продажи или на деньги; отчёт проверяется по своему праву.
+func TestDetectAnomaliesScopes(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = anomaliesFake
+
+	if _, ok := findVisibleTool(&oauth.AuthInfo{Scopes: []string{"mcp:report:money"}}, ToolDetectAnomalies); !ok {
+		t.Fatal("detect_anomalies hidden from a money-only caller")
+	}
+	if _, ok := findVisibleTool(&oauth.AuthInfo{Scopes: []string{"mcp:resolve"}}, ToolDetectAnomalies); ok {
+		t.Error("detect_anomalies visible without sales or money scope")
+	}
+
+	money := withScopes("mcp:report:money")
+	call := func(report string) CallToolResult {
+		res := rpc(t, h, money, "tools/call", map[string]any{
+			"name":      ToolDetectAnomalies,
+			"arguments": map[string]any{"report": report, "date": anomalyDay, "lookback_days": 28},
+		})
+		var result CallToolResult
+		if err := json.Unmarshal(res.Result, &result); err != nil {
+			t.Fatalf("%s: %s %+v", report, res.Result, res.Error)
+		}
+		return result
+	}
+	if res := call(ToolCashFlow); res.IsError {
+		t.Errorf("cash_flow: %s", res.Content[0].Text)
+	}
+	if res := call(ToolSalesReport); !res.IsError || !strings.Contains(res.Content[0].Text, `requires scope "mcp:report:sales"`) {
+		t.Errorf("sales_report: %+v", res)
+	}
+}
+
+func TestRobustScore(t *testing.T) {
+	// Один прошлый выброс не сдвигает ни медиану, ни MAD.
+	expected, score := robustScore(10, []float64{10, 11, 9, 10, 1000})
+	if expected != 10 || score == nil || *score != 0 {
+		t.Errorf("robustScore = %v, %v", expected, score)
+	}
+	if _, score := robustScore(5, []float64{0, 0, 0, 0}); score != nil {
+		t.Errorf("constant baseline score = %v, want nil", *score)
+	}
+	// MAD = 0, но разброс есть — оценка по среднему абсолютному отклонению.
+	if _, score := robustScore(20, []float64{10, 10, 10, 30}); score == nil || *score <= 0 {
+		t.Errorf("mean-abs fallback score = %v", score)
+	}
+}
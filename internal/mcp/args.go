@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -114,8 +115,11 @@ func (f *flexBool) UnmarshalJSON(data []byte) error {
 
 // clampLimit — размер выдачи resolve_*: 0/отрицательное и всё сверх resolve_limit
 // схлопывается в resolve_limit.
-func (h *Handler) clampLimit(limit flexInt) int {
+func (h *Handler) clampLimit(ctx context.Context, limit flexInt) int {
 	v := int(limit)
+	if v > h.cfg.Limits.ResolveLimit {
+		logClamp(ctx, "limit", v, h.cfg.Limits.ResolveLimit, "resolve_limit")
+	}
 	if v <= 0 || v > h.cfg.Limits.ResolveLimit {
 		return h.cfg.Limits.ResolveLimit
 	}
@@ -124,16 +128,17 @@ func (h *Handler) clampLimit(limit flexInt) int {
 
 // clampTop — число строк отчёта: 0/отрицательное означает «без явного ограничения»,
 // то есть max_rows; всё сверх max_rows тоже режется до max_rows.
-func (h *Handler) clampTop(top flexInt) int {
-	return h.clampTopDefault(top, h.cfg.Limits.MaxRows)
+func (h *Handler) clampTop(ctx context.Context, top flexInt) int {
+	return h.clampTopDefault(ctx, "top", top, h.cfg.Limits.MaxRows)
 }
 
 // clampTopDefault — то же, но с собственным значением по умолчанию. Нужно для top_products,
 // где «сколько-нибудь» разумнее трактовать как 10, а не как весь max_rows.
 //
 // max_rows — потолок и для def: иначе вызывающий с завышенным дефолтом протаскивал бы мимо
-// лимита ровно то, ради чего лимит и заведён.
-func (h *Handler) clampTopDefault(top flexInt, def int) int {
+// лимита ровно то, ради чего лимит и заведён. argument — имя аргумента для сообщения клиенту
+// об урезании (logClamp).
+func (h *Handler) clampTopDefault(ctx context.Context, argument string, top flexInt, def int) int {
 	if def > h.cfg.Limits.MaxRows {
 		def = h.cfg.Limits.MaxRows
 	}
@@ -142,6 +147,7 @@ func (h *Handler) clampTopDefault(top flexInt, def int) int {
 		return def
 	}
 	if v > h.cfg.Limits.MaxRows {
+		logClamp(ctx, argument, v, h.cfg.Limits.MaxRows, "max_rows")
 		return h.cfg.Limits.MaxRows
 	}
 	return v
@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// batchConcurrency — сколько элементов батча выполняется одновременно. Элементы независимы,
+// но все идут в одну базу 1С: без потолка батч из полусотни resolve превратился бы в полсотни
+// параллельных сеансов на HTTP-сервисе 1С.
+const batchConcurrency = 4
+
+// isBatch — тело POST является JSON-массивом (JSON-RPC 2.0 §6).
+func isBatch(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// serveBatch обслуживает JSON-RPC батч: каждый элемент проходит тот же dispatch, что и одиночный
+// запрос, уведомления ответа не получают, ответы собираются в массив в порядке запросов.
+// Элементы выполняются параллельно (с потолком batchConcurrency) — ради этого батч и шлют:
+// несколько resolve за один HTTP round trip и за время самого медленного из них.
+func (h *Handler) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		h.writeJSON(w, http.StatusOK, ParseError(nil))
+		return
+	}
+
+	// Пустой массив — не батч, а ошибка: ответ одиночный, не массив (JSON-RPC 2.0 §6).
+	if len(raw) == 0 {
+		h.writeJSON(w, http.StatusOK, InvalidRequest(nil))
+		return
+	}
+
+	// Батч с initialize сессию открывает, а не предъявляет — как и одиночный initialize
+	// (servePost): устаревший Mcp-Session-Id при переподключении не должен давать 404.
+	var sess *Session
+	if !containsInitialize(raw) {
+		var ok bool
+		if sess, ok = h.checkSession(w, r, nil); !ok {
+			return
+		}
+		if !h.checkProtocolHeader(w, r, nil) {
+			return
+		}
+	}
+	ctx := h.requestContext(r, sess)
+
+	b := h.parseBatch(ctx, raw)
+
+	// Батч из одних уведомлений — как одиночное уведомление: 202 без тела.
+	if b.empty() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Mcp-Session-Id выдаётся заголовком, а у SSE-ответа заголовки уходят до выполнения батча —
+	// раньше, чем станет известен ответ initialize. Батч с initialize поэтому всегда отвечает
+	// JSON: сессия открывается так же, как для одиночного initialize.
+	if b.hasToolCall && !b.hasInitialize && acceptsEventStream(r) {
+		h.serveEventStream(ctx, w, func(ctx context.Context) any {
+			if out := h.runBatch(ctx, b); len(out) > 0 {
+				return out
+			}
+			return nil
+		})
+		return
+	}
+
+	out := h.runBatch(ctx, b)
+
+	// Все запросы батча отменены клиентом — как и одиночный отменённый: 202 без тела.
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// initialize в батче допустим (старые клиенты шлют его вместе с tools/list): сессию открываем
+	// так же, как для одиночного, — по первому успешному.
+	for _, i := range b.pending {
+		if b.reqs[i].Method == "initialize" && b.responses[i] != nil && b.responses[i].Error == nil {
+			if !h.openSession(w, r, b.responses[i]) {
+				b.responses[i] = InternalError(b.reqs[i].ID, "failed to create session")
+				out = compactResponses(b.responses)
+			}
+			break
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, out)
+}
+
+// containsInitialize — среди элементов батча есть initialize. Смотрит только на method: битые
+// элементы разберёт и отклонит parseBatch.
+func containsInitialize(raw []json.RawMessage) bool {
+	for _, msg := range raw {
+		var probe struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(msg, &probe) == nil && probe.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+// batch — разобранный батч. Разбор и выполнение разделены: между ними HTTP-транспорт решает,
+// отвечать ли SSE-потоком, а для этого нужно знать, есть ли в батче tools/call.
+type batch struct {
+	reqs          []Request
+	responses     []*Response
+	pending       []int // индексы запросов, которые предстоит выполнить
+	hasToolCall   bool
+	hasInitialize bool
+}
+
+// parseBatch разбирает элементы батча. Битые элементы сразу получают ошибку, уведомления
+// обрабатываются на месте и места в ответе не занимают.
+func (h *Handler) parseBatch(ctx context.Context, raw []json.RawMessage) *batch {
+	b := &batch{
+		reqs:      make([]Request, len(raw)),
+		responses: make([]*Response, len(raw)),
+		pending:   make([]int, 0, len(raw)),
+	}
+
+	for i, msg := range raw {
+		if err := json.Unmarshal(msg, &b.reqs[i]); err != nil {
+			// Элемент, который не разобрать, получает ошибку без id: его id тоже не разобрать.
+			b.responses[i] = InvalidRequest(nil)
+			continue
+		}
+		if b.reqs[i].JSONRPC != JSONRPCVersion {
+			b.responses[i] = InvalidRequest(b.reqs[i].ID)
+			continue
+		}
+		if b.reqs[i].ID == nil {
+			h.handleNotification(ctx, b.reqs[i]) // уведомление: записи в ответе нет, как и вне батча
+			continue
+		}
+		switch b.reqs[i].Method {
+		case "tools/call":
+			b.hasToolCall = true
+		case "initialize":
+			b.hasInitialize = true
+		}
+		b.pending = append(b.pending, i)
+	}
+
+	h.logger.Info("mcp batch", "size", len(raw), "requests", len(b.pending))
+	return b
+}
+
+// empty — в батче не было ничего, что требует ответа.
+func (b *batch) empty() bool {
+	return len(b.pending) == 0 && len(compactResponses(b.responses)) == 0
+}
+
+// runBatch выполняет запросы батча параллельно и возвращает ответы в порядке запросов.
+func (h *Handler) runBatch(ctx context.Context, b *batch) []*Response {
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for _, i := range b.pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			h.logger.Info("mcp request", "method", b.reqs[i].Method, "id", b.reqs[i].ID, "batch", true)
+			b.responses[i] = h.dispatch(ctx, b.reqs[i])
+		}(i)
+	}
+	wg.Wait()
+	return compactResponses(b.responses)
+}
+
+// compactResponses выбрасывает пустые места уведомлений, сохраняя порядок остальных ответов.
+func compactResponses(responses []*Response) []*Response {
+	out := make([]*Response, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// postRaw отправляет тело POST как есть — батчи и заведомо битые сообщения.
+func postRaw(t *testing.T, h *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader([]byte(body))).WithContext(context.Background())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBatchKeepsOrderAndSkipsNotifications(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	rec := postRaw(t, h, `[
+		{"jsonrpc":"2.0","id":"a","method":"tools/list"},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stock_balance","arguments":{}}},
+		42,
+		{"jsonrpc":"2.0","id":3,"method":"no/such"}
+	]`)
+
+	var out []Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", rec.Body.String(), err)
+	}
+	if len(out) != 4 {
+		t.Fatalf("got %d responses, want 4 (notification has no entry): %s", len(out), rec.Body.String())
+	}
+
+	if out[0].ID != "a" || out[0].Error != nil {
+		t.Errorf("entry 0: %+v", out[0])
+	}
+	if out[1].ID != float64(2) || out[1].Error != nil {
+		t.Errorf("entry 1: %+v", out[1])
+	}
+	if out[2].Error == nil || out[2].Error.Code != CodeInvalidRequest {
+		t.Errorf("entry 2 (not an object): %+v", out[2])
+	}
+	if out[3].Error == nil || out[3].Error.Code != CodeMethodNotFound {
+		t.Errorf("entry 3: %+v", out[3])
+	}
+}
+
+func TestBatchEdgeCases(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	rec := postRaw(t, h, `[]`)
+	var single Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &single); err != nil || single.Error == nil || single.Error.Code != CodeInvalidRequest {
+		t.Errorf("empty batch: %s", rec.Body.String())
+	}
+
+	rec = postRaw(t, h, `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+	if rec.Code != http.StatusAccepted || rec.Body.Len() != 0 {
+		t.Errorf("notifications-only batch: HTTP %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	rec = postRaw(t, h, `[{"jsonrpc":"2.0","id":1`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &single); err != nil || single.Error == nil || single.Error.Code != CodeParseError {
+		t.Errorf("broken batch: %s", rec.Body.String())
+	}
+}
+
+// Независимые tools/call батча идут в 1С параллельно: батч занимает время самого долгого
+// вызова, а не их сумму.
+func TestBatchRunsToolCallsConcurrently(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.delay = 200 * time.Millisecond
+
+	started := time.Now()
+	rec := postRaw(t, h, `[
+		{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"stock_balance","arguments":{}}},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stock_balance","arguments":{}}},
+		{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"stock_balance","arguments":{}}}
+	]`)
+	elapsed := time.Since(started)
+
+	var out []Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out) != 3 {
+		t.Fatalf("batch response: %s", rec.Body.String())
+	}
+	if fake.count() != 3 {
+		t.Errorf("1C got %d requests, want 3", fake.count())
+	}
+	if elapsed >= 500*time.Millisecond {
+		t.Errorf("batch took %v: calls ran sequentially", elapsed)
+	}
+}
+
+// initialize в батче с tools/call открывает сессию и у клиента, принимающего SSE: такой батч
+// отвечает JSON, иначе заголовок Mcp-Session-Id ушёл бы раньше ответа initialize.
+func TestBatchInitializeOpensSessionForEventStreamClient(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+ProtocolVersionStreamable+`","clientInfo":{"name":"test"}}},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stock_balance","arguments":{}}}
+	]`)))
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Header().Get(SessionHeader) == "" {
+		t.Errorf("no %s header", SessionHeader)
+	}
+	var out []Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out) != 2 {
+		t.Fatalf("batch response: %s", rec.Body.String())
+	}
+}
+
+// Батч с initialize, как и одиночный initialize, не проверяет Mcp-Session-Id: клиент
+// переподключается со старым заголовком и получает новую сессию, а не 404.
+func TestBatchInitializeIgnoresStaleSession(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+ProtocolVersionStreamable+`","clientInfo":{"name":"test"}}},
+		{"jsonrpc":"2.0","id":2,"method":"tools/list"}
+	]`)))
+	req.Header.Set(SessionHeader, "expired")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", rec.Code, rec.Body.String())
+	}
+	if sid := rec.Header().Get(SessionHeader); sid == "" || sid == "expired" {
+		t.Errorf("%s = %q, want a new session", SessionHeader, sid)
+	}
+}
@@ -0,0 +1,138 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// cash_conversion_cycle — цикл оборота денег (CCC = DIO + DSO − DPO) одним вызовом. Остатки,
+// взаиморасчёты и закупки давно отдаются по отдельности, но собрать из них CCC модель должна была
+// сама: пять-восемь вызовов и арифметика, в которой легко перепутать знаменатель.
+//
+//	DIO = средние запасы (stock_balance.amount)      / себестоимость продаж (sales_report.cost) × дни
+//	DSO = средняя ДЗ (receivables_balance.receivable) / выручка (sales_report.amount)           × дни
+//	DPO = средняя КЗ (payables_balance.payable)       / закупки (purchases_report.amount)        × дни
+//
+// Среднее — полусумма остатков на period.from и period.to. Авансы в ДЗ/КЗ не входят: это не
+// отсрочка, а предоплата.
+//
+// Наружу уходят итоги пяти отчётов, поэтому право на инструмент — права на все их контуры:
+// mcp:report:money (ToolScopes) плюс mcp:report:cost, mcp:report:sales и mcp:report:stock
+// (toolExtraScopes). Вложенные вызовы, как и у остальных составных инструментов, ещё и
+// проверяются checkReportCall (см. composite.go).
+
+type cccArgs struct {
+	Period       onec.Period `json:"period"`
+	FirmIDs      []string    `json:"firm_ids"`
+	WarehouseIDs []string    `json:"warehouse_ids"`
+}
+
+func (h *Handler) callCashConversionCycle(ctx context.Context, args any) (*CallToolResult, error) {
+	var a cccArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	from, errFrom := time.Parse(time.DateOnly, a.Period.From)
+	to, errTo := time.Parse(time.DateOnly, a.Period.To)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		return nil, argumentErrors{{Path: "period", Message: "expected from <= to as YYYY-MM-DD"}}
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+
+	// Фильтры раскладываются по отчётам, которые их понимают: у остатков и продаж нет фирмы,
+	// у взаиморасчётов — склада.
+	filters := func(firms, warehouses bool) map[string]any {
+		f := map[string]any{}
+		if firms && len(a.FirmIDs) > 0 {
+			f["firm_ids"] = jsonStrings(a.FirmIDs)
+		}
+		if warehouses && len(a.WarehouseIDs) > 0 {
+			f["warehouse_ids"] = jsonStrings(a.WarehouseIDs)
+		}
+		return f
+	}
+	period := func() map[string]any { return map[string]any{"from": a.Period.From, "to": a.Period.To} }
+	balance := func(tool, date, groupBy, measure string, firms, warehouses bool) reportCall {
+		return reportCall{tool, map[string]any{
+			"date":     date,
+			"filters":  filters(firms, warehouses),
+			"group_by": []any{groupBy},
+			"measures": []any{measure},
+		}}
+	}
+
+	calls := []reportCall{
+		balance(ToolStockBalance, a.Period.From, "warehouse", "amount", false, true),
+		balance(ToolStockBalance, a.Period.To, "warehouse", "amount", false, true),
+		balance(ToolReceivablesBalance, a.Period.From, "firm", "receivable", true, false),
+		balance(ToolReceivablesBalance, a.Period.To, "firm", "receivable", true, false),
+		balance(ToolPayablesBalance, a.Period.From, "firm", "payable", true, false),
+		balance(ToolPayablesBalance, a.Period.To, "firm", "payable", true, false),
+		{ToolSalesReport, map[string]any{
+			"period":   period(),
+			"filters":  filters(false, true),
+			"group_by": []any{"warehouse"},
+			"measures": []any{"amount", "cost"},
+		}},
+		{ToolPurchasesReport, map[string]any{
+			"period":   period(),
+			"filters":  filters(true, true),
+			"group_by": []any{"firm"},
+			"measures": []any{"amount"},
+		}},
+	}
+	if err := h.checkReportCalls(ctx, calls); err != nil {
+		return nil, err
+	}
+	reports, err := h.fetchReports(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	dio := cccComponent("inventory", reportTotal(reports[0], "amount"), reportTotal(reports[1], "amount"),
+		"cogs", reportTotal(reports[6], "cost"), days)
+	dso := cccComponent("receivables", reportTotal(reports[2], "receivable"), reportTotal(reports[3], "receivable"),
+		"revenue", reportTotal(reports[6], "amount"), days)
+	dpo := cccComponent("payables", reportTotal(reports[4], "payable"), reportTotal(reports[5], "payable"),
+		"purchases", reportTotal(reports[7], "amount"), days)
+
+	var ccc any
+	if dio["days"] != nil && dso["days"] != nil && dpo["days"] != nil {
+		ccc = roundTo(dio["days"].(float64)+dso["days"].(float64)-dpo["days"].(float64), 1)
+	}
+
+	return toolResult(map[string]any{
+		"period":      a.Period,
+		"period_days": days,
+		"dio":         dio,
+		"dso":         dso,
+		"dpo":         dpo,
+		"ccc":         ccc,
+		"applied_filters": map[string]any{
+			"firm_ids":      a.FirmIDs,
+			"warehouse_ids": a.WarehouseIDs,
+		},
+	})
+}
+
+// cccComponent — одна составляющая цикла с числителем и знаменателем, чтобы модель могла
+// объяснить число, а не только назвать его. Нулевой знаменатель — days = null: оборачиваемость
+// без оборота не определена.
+func cccComponent(balance string, opening, closing float64, flow string, turnover float64, days int) map[string]any {
+	average := (opening + closing) / 2
+
+	var value any
+	if turnover != 0 {
+		value = roundTo(average/turnover*float64(days), 1)
+	}
+	return map[string]any{
+		"days":               value,
+		balance + "_opening": roundTo(opening, 2),
+		balance + "_closing": roundTo(closing, 2),
+		balance + "_average": roundTo(average, 2),
+		flow:                 roundTo(turnover, 2),
+	}
+}
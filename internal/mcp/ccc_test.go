@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// cccFake отвечает итогами по пути отчёта; у остатков — по дате (начало/конец периода).
+func cccFake(purchases float64) func(path string, body map[string]any) string {
+	return func(path string, body map[string]any) string {
+		totals := map[string]any{}
+		opening := body["date"] == "2026-01-01"
+		switch path {
+		case "/mcp/reports/stock":
+			totals["amount"] = pick(opening, 1000, 1400)
+		case "/mcp/reports/receivables":
+			totals["receivable"] = pick(opening, 900, 1100)
+		case "/mcp/reports/payables":
+			totals["payable"] = pick(opening, 500, 700)
+		case "/mcp/reports/sales":
+			totals["amount"], totals["cost"] = 3100, 1860
+		case "/mcp/reports/purchases":
+			totals["amount"] = purchases
+		}
+		return reportJSON([]string{"x:ref"}, nil, totals)
+	}
+}
+
+func TestCashConversionCycle(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = cccFake(1860)
+
+	res := rpc(t, h, withScopes("mcp:report:money", ScopeReportCost, "mcp:report:sales", "mcp:report:stock"), "tools/call", map[string]any{
+		"name": ToolCashConversionCycle,
+		"arguments": map[string]any{
+			"period":   map[string]any{"from": "2026-01-01", "to": "2026-01-31"},
+			"firm_ids": []any{"f-1"},
+		},
+	})
+	var result CallToolResult
+	if err := json.Unmarshal(res.Result, &result); err != nil || result.IsError {
+		t.Fatalf("call: %s %+v", res.Result, res.Error)
+	}
+
+	var got struct {
+		DIO, DSO, DPO map[string]any
+		CCC           any
+	}
+	if err := json.Unmarshal([]byte(resultText(t, result)), &got); err != nil {
+		t.Fatal(err)
+	}
+	// 31 день: DIO = 1200/1860·31, DSO = 1000/3100·31, DPO = 600/1860·31.
+	if got.DIO["days"] != 20.0 || got.DSO["days"] != 10.0 || got.DPO["days"] != 10.0 || got.CCC != 20.0 {
+		t.Errorf("dio=%v dso=%v dpo=%v ccc=%v", got.DIO["days"], got.DSO["days"], got.DPO["days"], got.CCC)
+	}
+	if got.DIO["inventory_average"] != 1200.0 || got.DIO["cogs"] != 1860.0 {
+		t.Errorf("dio components = %v", got.DIO)
+	}
+
+	// firm_ids — только отчётам, у которых есть фирма.
+	if fake.count() != 8 {
+		t.Fatalf("1C calls = %d, want 8", fake.count())
+	}
+	for i := range 8 {
+		r := fake.recorded(t, i)
+		filters, _ := r.body["filters"].(map[string]any)
+		_, hasFirm := filters["firm_ids"]
+		wantFirm := r.path != "/mcp/reports/stock" && r.path != "/mcp/reports/sales"
+		if hasFirm != wantFirm {
+			t.Errorf("%s: firm_ids sent = %v", r.path, hasFirm)
+		}
+	}
+}
+
+// Без оборота оборачиваемость не определена: days и ccc — null, а не деление на ноль.
+func TestCashConversionCycleZeroDenominator(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = cccFake(0)
+
+	res := callTool(t, h, ToolCashConversionCycle, map[string]any{
+		"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"},
+	})
+	text := resultText(t, res)
+	if !strings.Contains(text, `"ccc":null`) || !strings.Contains(text, `"days":null`) {
+		t.Errorf("zero purchases: %s", text)
+	}
+}
+
+// Инструмент требует и money, и cost: без второго он не виден и не вызывается.
+func TestCashConversionCycleNeedsCostScope(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	ctx := withScopes("mcp:report:money")
+	if _, ok := findVisibleTool(oauth.FromContext(ctx), ToolCashConversionCycle); ok {
+		t.Error("tool listed without mcp:report:cost")
+	}
+	res := rpc(t, h, ctx, "tools/call", map[string]any{
+		"name":      ToolCashConversionCycle,
+		"arguments": map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}},
+	})
+	if !strings.Contains(string(res.Result), `requires scope \"mcp:report:cost\"`) || fake.count() != 0 {
+		t.Errorf("call without cost scope: %s", res.Result)
+	}
+}
+
+// Наружу уходят итоги продаж и остатков: токен с одними деньгами и себестоимостью их не получит.
+func TestCashConversionCycleNeedsSalesAndStockScopes(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = cccFake(1860)
+
+	for _, missing := range []string{"mcp:report:sales", "mcp:report:stock"} {
+		scopes := []string{"mcp:report:money", ScopeReportCost, "mcp:report:sales", "mcp:report:stock"}
+		scopes = slices.DeleteFunc(scopes, func(s string) bool { return s == missing })
+		ctx := withScopes(scopes...)
+		if _, ok := findVisibleTool(oauth.FromContext(ctx), ToolCashConversionCycle); ok {
+			t.Errorf("tool listed without %s", missing)
+		}
+		res := rpc(t, h, ctx, "tools/call", map[string]any{
+			"name":      ToolCashConversionCycle,
+			"arguments": map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}},
+		})
+		if !strings.Contains(string(res.Result), fmt.Sprintf(`requires scope \"%s\"`, missing)) {
+			t.Errorf("call without %s: %s", missing, res.Result)
+		}
+	}
+	if fake.count() != 0 {
+		t.Errorf("1C got %d requests", fake.count())
+	}
+}
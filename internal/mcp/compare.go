@@ -0,0 +1,256 @@
+package mcp
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// compare_periods — один и тот же отчёт за два периода со сведёнными дельтами. «Как март к
+// прошлому марту» модель раньше собирала из двух вызовов и сама сопоставляла строки — и часто
+// путала их. Гейт запрашивает оба периода параллельно, сводит строки по измерениям (по id ссылок,
+// не по наименованиям) и по каждой мере отдаёт base, current, delta и delta_pct.
+
+// Сдвиги базового периода для compare_to.
+const (
+	compareToPrevious = "previous" // предыдущий период той же длины, вплотную к текущему
+	compareToMoM      = "mom"      // тот же период месяцем раньше
+	compareToYoY      = "yoy"      // тот же период годом раньше
+)
+
+// comparableReports — отчёты за период, которые умеет сравнивать compare_periods.
+var comparableReports = []string{ToolSalesReport, ToolPurchasesReport, ToolCashFlow}
+
+type comparePeriodsArgs struct {
+	Report     string      `json:"report"`
+	Arguments  any         `json:"arguments"`
+	Period     onec.Period `json:"period"`
+	BasePeriod onec.Period `json:"base_period"`
+	CompareTo  string      `json:"compare_to"`
+	Top        flexInt     `json:"top"`
+}
+
+func (h *Handler) callComparePeriods(ctx context.Context, args any) (*CallToolResult, error) {
+	var a comparePeriodsArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	base, err := basePeriod(a.Period, a.BasePeriod, a.CompareTo)
+	if err != nil {
+		return nil, err
+	}
+
+	// top и sort вложенного отчёта отрезали бы строки до сведения: строка, выпавшая из top
+	// в одном периоде, выглядела бы как нулевая. Отчёт берётся целиком, top — после сведения.
+	inner, _ := a.Arguments.(map[string]any)
+	withPeriod := func(p onec.Period) map[string]any {
+		m := make(map[string]any, len(inner)+1)
+		for k, v := range inner {
+			switch k {
+			case "top", "sort", "cursor", "output_format":
+				continue
+			}
+			m[k] = v
+		}
+		m["period"] = map[string]any{"from": p.From, "to": p.To}
+		return m
+	}
+
+	if err := h.checkReportCall(ctx, a.Report, withPeriod(a.Period)); err != nil {
+		return nil, err
+	}
+
+	reports, err := h.fetchReports(ctx, []reportCall{
+		{a.Report, withPeriod(base)},
+		{a.Report, withPeriod(a.Period)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := compareReports(reports[0], reports[1])
+	if top := int(a.Top); top > 0 {
+		if rows := resp["rows"].([][]any); len(rows) > top {
+			resp["rows"] = rows[:top]
+		}
+	}
+	resp["report"] = a.Report
+	resp["period"] = a.Period
+	resp["base_period"] = base
+	return toolResult(resp)
+}
+
+// basePeriod — период сравнения: явный base_period или сдвиг текущего по compare_to.
+func basePeriod(current, explicit onec.Period, compareTo string) (onec.Period, error) {
+	if explicit.From != "" || explicit.To != "" {
+		if explicit.From == "" || explicit.To == "" {
+			return onec.Period{}, argumentErrors{{Path: "base_period", Message: "needs both from and to"}}
+		}
+		return explicit, nil
+	}
+
+	from, errFrom := time.Parse(time.DateOnly, current.From)
+	to, errTo := time.Parse(time.DateOnly, current.To)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		return onec.Period{}, argumentErrors{{Path: "period", Message: "expected from <= to as YYYY-MM-DD"}}
+	}
+
+	switch compareTo {
+	case compareToMoM:
+		from, to = shiftMonths(from, -1, false), shiftMonths(to, -1, true)
+	case compareToYoY:
+		from, to = shiftMonths(from, -12, false), shiftMonths(to, -12, true)
+	default:
+		days := int(to.Sub(from).Hours()/24) + 1
+		to = from.AddDate(0, 0, -1)
+		from = to.AddDate(0, 0, 1-days)
+	}
+	return onec.Period{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly)}, nil
+}
+
+// shiftMonths сдвигает дату на n месяцев. День, которого нет в целевом месяце, прижимается
+// к его концу (31.03 → 28.02). end — дата конца периода: последний день месяца остаётся
+// последним (29.02.2024 → 28.02.2023, 28.02.2026 → 31.01.2026), чтобы «февраль» сдвигался
+// в «январь» целиком.
+func shiftMonths(d time.Time, n int, end bool) time.Time {
+	first := time.Date(d.Year(), d.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+
+	day := d.Day()
+	if day > last || (end && d.AddDate(0, 0, 1).Day() == 1) {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// compareReports сводит два ответа отчёта по измерениям. Строка, которой нет в одном из периодов,
+// считается там нулевой. Строки упорядочены по модулю изменения первой меры — «что изменилось
+// сильнее всего» и есть обычный вопрос к сравнению.
+func compareReports(base, current tableReport) map[string]any {
+	dims, measures := splitColumns(current)
+
+	columns := make([]map[string]any, 0, len(dims)+4*len(measures))
+	for _, d := range dims {
+		columns = append(columns, map[string]any{"name": current.columns[d], "type": current.types[d]})
+	}
+	for _, m := range measures {
+		name := current.columns[m]
+		for _, suffix := range []string{"_base", "_current", "_delta", "_delta_pct"} {
+			columns = append(columns, map[string]any{"name": name + suffix, "type": "number"})
+		}
+	}
+
+	// Меры базового отчёта ищутся по имени: колонки обоих периодов совпадают, но полагаться
+	// на порядок незачем.
+	baseIndex := make(map[string]int, len(base.columns))
+	for i, name := range base.columns {
+		baseIndex[name] = i
+	}
+
+	type joined struct {
+		dims          []any
+		base, current []any
+	}
+	var order []string
+	byKey := map[string]*joined{}
+	add := func(row []any, isBase bool) {
+		key := rowKey(row, dims)
+		j, ok := byKey[key]
+		if !ok {
+			j = &joined{dims: make([]any, len(dims))}
+			for i, d := range dims {
+				if d < len(row) {
+					j.dims[i] = row[d]
+				}
+			}
+			byKey[key] = j
+			order = append(order, key)
+		}
+		if isBase {
+			j.base = row
+		} else {
+			j.current = row
+		}
+	}
+	for _, row := range current.rows {
+		add(row, false)
+	}
+	for _, row := range base.rows {
+		add(row, true)
+	}
+
+	cell := func(row []any, i int) float64 {
+		if i < 0 || i >= len(row) {
+			return 0
+		}
+		return numberValue(row[i])
+	}
+
+	// change — модуль изменения первой меры, по нему строки и упорядочены.
+	type ranked struct {
+		row    []any
+		change float64
+	}
+	out := make([]ranked, 0, len(order))
+	for _, key := range order {
+		j := byKey[key]
+		r := ranked{row: append([]any{}, j.dims...)}
+		for n, m := range measures {
+			b, ok := baseIndex[current.columns[m]]
+			if !ok {
+				b = -1
+			}
+			bv, cv := cell(j.base, b), cell(j.current, m)
+			r.row = append(r.row, delta(bv, cv)...)
+			if n == 0 {
+				r.change = math.Abs(cv - bv)
+			}
+		}
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].change > out[b].change })
+
+	rows := make([][]any, len(out))
+	for i, r := range out {
+		rows[i] = r.row
+	}
+
+	totals := map[string]any{}
+	baseTotals, _ := base.fields["totals"].(map[string]any)
+	currentTotals, _ := current.fields["totals"].(map[string]any)
+	for _, m := range measures {
+		name := current.columns[m]
+		bv, bok := baseTotals[name]
+		cv, cok := currentTotals[name]
+		if !bok && !cok {
+			continue
+		}
+		values := delta(numberValue(bv), numberValue(cv))
+		totals[name+"_base"] = values[0]
+		totals[name+"_current"] = values[1]
+		totals[name+"_delta"] = values[2]
+		totals[name+"_delta_pct"] = values[3]
+	}
+
+	return map[string]any{"columns": columns, "rows": rows, "totals": totals}
+}
+
+// delta — base, current, абсолютное и относительное изменение. Процент от нулевой базы не
+// определён — null, а не бесконечность.
+func delta(base, current float64) []any {
+	var pct any
+	if base != 0 {
+		pct = roundTo(((current-base)/math.Abs(base))*100, 2)
+	}
+	return []any{base, current, roundTo(current-base, 4), pct}
+}
+
+// roundTo убирает хвосты двоичной арифметики (0.30000000000000004) из вычисленных значений.
+func roundTo(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+func TestBasePeriod(t *testing.T) {
+	cases := []struct {
+		from, to, compareTo string
+		want                onec.Period
+	}{
+		{"2026-03-01", "2026-03-31", compareToYoY, onec.Period{From: "2025-03-01", To: "2025-03-31"}},
+		{"2026-03-01", "2026-03-31", compareToMoM, onec.Period{From: "2026-02-01", To: "2026-02-28"}},
+		{"2026-02-01", "2026-02-28", compareToMoM, onec.Period{From: "2026-01-01", To: "2026-01-31"}},
+		{"2024-02-01", "2024-02-29", compareToYoY, onec.Period{From: "2023-02-01", To: "2023-02-28"}},
+		{"2026-03-10", "2026-03-16", compareToPrevious, onec.Period{From: "2026-03-03", To: "2026-03-09"}},
+		{"2026-03-01", "2026-03-31", "", onec.Period{From: "2026-01-29", To: "2026-02-28"}},
+	}
+	for _, tc := range cases {
+		got, err := basePeriod(onec.Period{From: tc.from, To: tc.to}, onec.Period{}, tc.compareTo)
+		if err != nil || got != tc.want {
+			t.Errorf("%s..%s %q = %+v, %v; want %+v", tc.from, tc.to, tc.compareTo, got, err, tc.want)
+		}
+	}
+
+	if _, err := basePeriod(onec.Period{From: "2026-03-01", To: "2026-03-31"}, onec.Period{From: "2025-01-01"}, ""); err == nil {
+		t.Error("half-open base_period accepted")
+	}
+}
+
+// Строки сводятся по id измерения, а не по наименованию; отсутствующая в периоде строка — ноль.
+func TestComparePeriodsJoinsRows(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = func(_ string, body map[string]any) string {
+		period, _ := body["period"].(map[string]any)
+		if period["from"] == "2025-03-01" {
+			return `{"columns":[{"name":"warehouse","type":"ref"},{"name":"amount","type":"number"}],` +
+				`"rows":[[{"id":"w-1","label":"Old name"},100],[{"id":"w-2","label":"Closed"},50]],"totals":{"amount":150}}`
+		}
+		return `{"columns":[{"name":"warehouse","type":"ref"},{"name":"amount","type":"number"}],` +
+			`"rows":[[{"id":"w-1","label":"Main"},130.1],[{"id":"w-3","label":"New"},20]],"totals":{"amount":150.1}}`
+	}
+
+	res := callTool(t, h, ToolComparePeriods, map[string]any{
+		"report":     ToolSalesReport,
+		"arguments":  map[string]any{"group_by": []any{"warehouse"}, "top": 1.0},
+		"period":     map[string]any{"from": "2026-03-01", "to": "2026-03-31"},
+		"compare_to": "yoy",
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	var got struct {
+		Columns []map[string]string `json:"columns"`
+		Rows    [][]any             `json:"rows"`
+		Totals  map[string]any      `json:"totals"`
+		Base    onec.Period         `json:"base_period"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Columns) != 5 || got.Columns[4]["name"] != "amount_delta_pct" {
+		t.Errorf("columns = %v", got.Columns)
+	}
+	// По модулю изменения: w-2 (−50), w-1 (+30.1), w-3 (+20).
+	want := map[string][]any{
+		"w-2": {50.0, 0.0, -50.0, -100.0},
+		"w-1": {100.0, 130.1, 30.1, 30.1},
+		"w-3": {0.0, 20.0, 20.0, nil},
+	}
+	order := []string{"w-2", "w-1", "w-3"}
+	if len(got.Rows) != 3 {
+		t.Fatalf("rows = %v", got.Rows)
+	}
+	for i, row := range got.Rows {
+		id := row[0].(map[string]any)["id"]
+		if id != order[i] {
+			t.Errorf("row %d = %v, want %s", i, id, order[i])
+			continue
+		}
+		for j, v := range want[order[i]] {
+			if row[j+1] != v {
+				t.Errorf("%s: %v, want %v", id, row[1:], want[order[i]])
+				break
+			}
+		}
+	}
+	if got.Totals["amount_delta"] != 0.1 || got.Base.From != "2025-03-01" {
+		t.Errorf("totals = %v, base = %+v", got.Totals, got.Base)
+	}
+
+	// top вложенного отчёта до 1С не доходит: сводить нужно полные выборки.
+	for i := range 2 {
+		if top := fake.recorded(t, i).body["top"]; top != 5000.0 {
+			t.Errorf("inner report got top=%v, want max_rows", top)
+		}
+	}
+}
+
+// Вложенный отчёт проверяется так же, как прямой вызов: scope, меры себестоимости, схема.
+func TestComparePeriodsChecksInnerReport(t *testing.T) {
+	h, fake := newTestHandler(t)
+	period := map[string]any{"from": "2026-03-01", "to": "2026-03-31"}
+	ctx := withScopes("mcp:report:sales")
+
+	call := func(args map[string]any) rpcResult {
+		return rpc(t, h, ctx, "tools/call", map[string]any{"name": ToolComparePeriods, "arguments": args})
+	}
+
+	res := call(map[string]any{"report": ToolCashFlow, "period": period})
+	if res.Error != nil || !strings.Contains(string(res.Result), `requires scope \"mcp:report:money\"`) {
+		t.Errorf("cash_flow without money scope: %s %+v", res.Result, res.Error)
+	}
+
+	res = call(map[string]any{"report": ToolSalesReport, "period": period, "arguments": map[string]any{"measures": []any{"profit"}}})
+	if res.Error != nil || !strings.Contains(string(res.Result), "permission denied: measures [profit]") {
+		t.Errorf("profit without cost scope: %s %+v", res.Result, res.Error)
+	}
+
+	res = call(map[string]any{"report": ToolSalesReport, "period": period, "arguments": map[string]any{"group_by": []any{"colour"}}})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, "arguments.group_by[0]") {
+		t.Errorf("bad inner group_by: %+v", res.Error)
+	}
+
+	if fake.count() != 0 {
+		t.Errorf("rejected comparisons reached 1C %d times", fake.count())
+	}
+}
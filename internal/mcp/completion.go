@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// completionMaxValues — потолок вариантов в одном ответе, заданный спецификацией.
+const completionMaxValues = 100
+
+// Типы ссылок completion/complete. ref/tool — расширение гейта: спецификация дополняет только
+// аргументы промптов и шаблонов ресурсов, а самые частые ручные значения — склад в фильтре
+// отчёта, набор group_by — живут в аргументах инструментов.
+const (
+	refPrompt   = "ref/prompt"
+	refResource = "ref/resource"
+	refTool     = "ref/tool"
+)
+
+// toolCatalogArgs — аргументы инструментов, значения которых берутся из справочника:
+// подсказываются UUID, потому что именно их ждёт фильтр.
+var toolCatalogArgs = map[string]string{
+	"warehouse_ids":     "warehouses",
+	"sales_channel_ids": "sales_channels",
+	"cash_ids":          "cash_desks",
+	"operation_ids":     "operations",
+}
+
+// promptCatalogArgs — аргументы промптов со значением из справочника. Промпт вставляет
+// аргумент в текст для модели, поэтому подсказываются наименования, а не UUID.
+var promptCatalogArgs = map[string]string{
+	"warehouse": "warehouses",
+}
+
+// handleComplete — completion/complete. Подсказки строятся по тем же правам, что и списки:
+// закрытый промпт, ресурс или инструмент не дополняется (InvalidParams, как на prompts/get),
+// а enum-значения берутся из схемы после stripCostMeasures.
+//
+// Аргумент без источника подсказок — не ошибка, а пустой список: клиент спрашивает про каждое
+// поле формы подряд.
+func (h *Handler) handleComplete(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	var params CompleteParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Argument.Name == "" {
+		return InvalidParams(req.ID, "failed to parse params")
+	}
+	arg := params.Argument
+
+	var values []string
+	switch params.Ref.Type {
+	case refPrompt:
+		t, ok := findPromptTemplate(params.Ref.Name)
+		if !ok || !promptAllowed(auth, t) {
+			return InvalidParams(req.ID, "unknown prompt: "+params.Ref.Name)
+		}
+		if name, ok := promptCatalogArgs[arg.Name]; ok {
+			values = h.completeCatalog(ctx, auth, name, arg.Value, false)
+		}
+
+	case refResource:
+		// URI шаблона (onec://slug/warehouses{?query}) разбирается без своей {?...}-части.
+		uri, _, _ := strings.Cut(params.Ref.URI, "{")
+		c, _, ok := h.parseResourceURI(uri)
+		if !ok || !resourceAllowed(auth, c) {
+			return InvalidParams(req.ID, "unknown resource: "+params.Ref.URI)
+		}
+		if arg.Name == "query" {
+			values = h.completeCatalog(ctx, auth, c.name, arg.Value, false)
+		}
+
+	case refTool:
+		tool, ok := findVisibleTool(auth, params.Ref.Name)
+		if !ok {
+			return InvalidParams(req.ID, "unknown tool: "+params.Ref.Name)
+		}
+		// Вложенный аргумент можно назвать и путём (filters.warehouse_ids): значим последний сегмент.
+		name := arg.Name
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		if catalog, ok := toolCatalogArgs[name]; ok {
+			values = h.completeCatalog(ctx, auth, catalog, arg.Value, true)
+		} else {
+			values = completeEnum(tool.InputSchema, name, arg.Value)
+		}
+
+	default:
+		return InvalidParams(req.ID, "unsupported ref type: "+params.Ref.Type)
+	}
+
+	return NewResponse(req.ID, CompleteResult{Completion: completion(values)})
+}
+
+// completion упаковывает варианты с учётом потолка спецификации.
+func completion(values []string) Completion {
+	if values == nil {
+		values = []string{}
+	}
+	c := Completion{Values: values, Total: len(values)}
+	if len(values) > completionMaxValues {
+		c.Values = values[:completionMaxValues]
+		c.HasMore = true
+	}
+	return c
+}
+
+// completeCatalog ищет введённое в справочнике тем же Resolve*, что и ресурс каталога, — через
+// resolve-кэш и с resolve_limit: подсказка — это тот же поиск кандидатов. ids — вернуть UUID
+// вместо наименований. Справочник, закрытый правами, и ошибка 1С дают пустой список:
+// выпадающему списку нечего показать, но и ломать ввод незачем.
+func (h *Handler) completeCatalog(ctx context.Context, auth *oauth.AuthInfo, name, value string, ids bool) []string {
+	c, ok := findCatalogResource(name)
+	if !ok || !resourceAllowed(auth, c) {
+		return nil
+	}
+
+	resp, err := c.read(h, ctx, strings.TrimSpace(value), h.cfg.Limits.ResolveLimit)
+	if err != nil {
+		h.logger.Warn("completion lookup failed", "catalog", name, "error", err)
+		return nil
+	}
+
+	// Ответы Resolve* разных типов, но у всех candidates с id и label.
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil
+	}
+	var parsed struct {
+		Candidates []struct {
+			ID    string `json:"id"`
+			Label string `json:"label"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil
+	}
+
+	values := make([]string, 0, len(parsed.Candidates))
+	for _, cand := range parsed.Candidates {
+		if ids {
+			values = append(values, cand.ID)
+		} else {
+			values = append(values, cand.Label)
+		}
+	}
+	return values
+}
+
+// findVisibleTool — инструмент, как его видит вызывающий (см. visibleTools).
+func findVisibleTool(auth *oauth.AuthInfo, name string) (Tool, bool) {
+	for _, t := range visibleTools(auth) {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// completeEnum — допустимые значения аргумента name из входной схемы: enum самого свойства или
+// его элементов (group_by, measures — массивы). Свойство ищется на любой глубине, так что
+// filters.* находятся так же, как верхнеуровневые. Фильтр — по началу значения без учёта регистра.
+func completeEnum(schema any, name, prefix string) []string {
+	enum := findEnum(schema, name)
+	prefix = strings.ToLower(prefix)
+
+	values := make([]string, 0, len(enum))
+	for _, v := range enum {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func findEnum(schema any, name string) []string {
+	node, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+	props, _ := node["properties"].(map[string]any)
+
+	if prop, ok := props[name].(map[string]any); ok {
+		if enum, ok := prop["enum"].([]string); ok {
+			return enum
+		}
+		if items, ok := prop["items"].(map[string]any); ok {
+			if enum, ok := items["enum"].([]string); ok {
+				return enum
+			}
+		}
+	}
+
+	// Обход в порядке имён: при совпадении имён на разных ветках ответ не должен зависеть
+	// от случайного порядка map.
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if enum := findEnum(props[k], name); enum != nil {
+			return enum
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func complete(t *testing.T, h *Handler, ctx context.Context, ref map[string]any, name, value string) (Completion, *Error) {
+	t.Helper()
+
+	res := rpc(t, h, ctx, "completion/complete", map[string]any{
+		"ref":      ref,
+		"argument": map[string]any{"name": name, "value": value},
+	})
+	if res.Error != nil {
+		return Completion{}, res.Error
+	}
+
+	var out CompleteResult
+	if err := json.Unmarshal(res.Result, &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", res.Result, err)
+	}
+	return out.Completion, nil
+}
+
+// Значения enum — по правам вызывающего: меры себестоимости без mcp:report:cost не подсказываются.
+func TestCompleteToolEnumFollowsScopes(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ref := map[string]any{"type": refTool, "name": ToolSalesReport}
+
+	got, rpcErr := complete(t, h, withScopes("mcp:report:sales"), ref, "measures", "")
+	if rpcErr != nil {
+		t.Fatalf("complete: %+v", rpcErr)
+	}
+	if slices.Contains(got.Values, "profit") || !slices.Contains(got.Values, "amount") {
+		t.Errorf("measures without cost scope = %v", got.Values)
+	}
+
+	got, _ = complete(t, h, withScopes("mcp:report:sales", ScopeReportCost), ref, "measures", "pro")
+	if !slices.Equal(got.Values, []string{"profit"}) {
+		t.Errorf("measures with prefix pro = %v, want [profit]", got.Values)
+	}
+
+	got, _ = complete(t, h, context.Background(), ref, "group_by", "W")
+	if !slices.Equal(got.Values, []string{"warehouse", "week"}) {
+		t.Errorf("group_by with prefix W = %v", got.Values)
+	}
+
+	if _, rpcErr := complete(t, h, withScopes("mcp:resolve"), ref, "measures", ""); rpcErr == nil || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("completion for a hidden tool: %+v", rpcErr)
+	}
+}
+
+// Аргумент-фильтр инструмента подсказывается UUID из справочника, аргумент промпта — наименованием.
+func TestCompleteCatalogArguments(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = `{"candidates":[{"id":"w-1","label":"Main warehouse","archived":false},{"id":"w-2","label":"Minsk","archived":false}]}`
+
+	got, rpcErr := complete(t, h, context.Background(),
+		map[string]any{"type": refTool, "name": ToolStockBalance}, "filters.warehouse_ids", "M")
+	if rpcErr != nil {
+		t.Fatalf("complete: %+v", rpcErr)
+	}
+	if !slices.Equal(got.Values, []string{"w-1", "w-2"}) {
+		t.Errorf("warehouse_ids = %v", got.Values)
+	}
+	if body := fake.recorded(t, 0).body; body["query"] != "M" {
+		t.Errorf("resolve query = %v, want the typed value", body["query"])
+	}
+
+	got, _ = complete(t, h, context.Background(),
+		map[string]any{"type": refPrompt, "name": PromptCategoryWatchdog}, "warehouse", "M")
+	if !slices.Equal(got.Values, []string{"Main warehouse", "Minsk"}) {
+		t.Errorf("prompt warehouse = %v", got.Values)
+	}
+
+	got, _ = complete(t, h, context.Background(),
+		map[string]any{"type": refResource, "uri": "onec://test/warehouses{?query}"}, "query", "M")
+	if len(got.Values) != 2 {
+		t.Errorf("resource query = %v", got.Values)
+	}
+
+	// Аргумент без источника подсказок — пустой список, не ошибка.
+	got, rpcErr = complete(t, h, context.Background(),
+		map[string]any{"type": refPrompt, "name": PromptCategoryWatchdog}, "from", "2026")
+	if rpcErr != nil || len(got.Values) != 0 {
+		t.Errorf("from = %v, %+v", got.Values, rpcErr)
+	}
+
+	if _, rpcErr := complete(t, h, context.Background(), map[string]any{"type": "ref/unknown"}, "x", ""); rpcErr == nil {
+		t.Error("unknown ref type accepted")
+	}
+}
+
+// Capability completions появилась в 2025-03-26: клиенту 2024-11-05 она не объявляется.
+func TestCompletionsCapabilityFollowsRevision(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for version, want := range map[string]bool{ProtocolVersion: false, ProtocolVersionStreamable: true} {
+		var envelope struct {
+			Result InitializeResult `json:"result"`
+		}
+		rec := initialize(t, h, context.Background(), version)
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if got := envelope.Result.Capabilities.Completions != nil; got != want {
+			t.Errorf("%s: completions capability = %v, want %v", version, got, want)
+		}
+
+		// Без capability нет и метода: completion/complete — method not found.
+		rec = postRPC(t, h, context.Background(), map[string]any{
+			"jsonrpc": "2.0", "id": 2, "method": "completion/complete",
+			"params": map[string]any{"ref": map[string]any{"type": refPrompt, "name": "none"}, "argument": map[string]any{"name": "x"}},
+		}, map[string]string{ProtocolVersionHeader: version})
+		var res rpcResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if got := res.Error == nil || res.Error.Code != CodeMethodNotFound; got != want {
+			t.Errorf("%s: completion/complete error = %+v", version, res.Error)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// Составные инструменты (compare_periods и аналитика поверх отчётов) считают на гейте, а данные
+// берут обычными табличными отчётами. Вложенный отчёт вызывается в обход handleToolsCall, поэтому
+// его проверки повторяются здесь: scope самого отчёта, право на меры себестоимости и схема
+// аргументов. Иначе составной инструмент стал бы обходным путём к закрытому отчёту.
+
+// reportCalls — табличные отчёты, доступные составным инструментам.
+var reportCalls = map[string]func(h *Handler, ctx context.Context, args any) (*CallToolResult, error){
+	ToolSalesReport:        (*Handler).callSalesReport,
+	ToolPurchasesReport:    (*Handler).callPurchasesReport,
+	ToolCashFlow:           (*Handler).callCashFlow,
+	ToolStockBalance:       (*Handler).callStockBalance,
+	ToolReceivablesBalance: (*Handler).callReceivablesBalance,
+	ToolPayablesBalance:    (*Handler).callPayablesBalance,
+	ToolAvailabilityReport: (*Handler).callAvailabilityReport,
+	ToolGoodsInTransit:     (*Handler).callGoodsInTransit,
+}
+
+// reportCall — один вложенный вызов отчёта.
+type reportCall struct {
+	tool string
+	args map[string]any
+}
+
+// permissionError — отказ по правам во вложенном вызове. handleToolsCall отвечает на него так же,
+// как на отказ по scope самого инструмента.
+type permissionError struct {
+	message string
+}
+
+func (e permissionError) Error() string { return e.message }
+
+// checkReportCall — проверки handleToolsCall для вложенного вызова отчёта tool с аргументами args.
+// Ошибки схемы возвращаются с путём от arguments, как их видит вызывающий составной инструмент.
+func (h *Handler) checkReportCall(ctx context.Context, tool string, args map[string]any) error {
+	if _, ok := reportCalls[tool]; !ok {
+		return argumentErrors{{Path: "report", Message: fmt.Sprintf("unsupported report %q", tool)}}
+	}
+
+	if err := checkToolScope(ctx, tool); err != nil {
+		return err
+	}
+	if auth := oauth.FromContext(ctx); auth != nil && !auth.HasScope(ScopeReportCost) {
+		if blocked := costMeasuresIn(args); len(blocked) > 0 {
+			return permissionError{fmt.Sprintf("permission denied: measures %v require scope %q", blocked, ScopeReportCost)}
+		}
+	}
+
+	if errs := validateArguments(toolInputSchema(tool), args); len(errs) > 0 {
+		for i := range errs {
+			errs[i].Path = joinPath("arguments", errs[i].Path)
+		}
+		return argumentErrors(errs)
+	}
+	return nil
+}
+
+// checkToolScope — право на вложенный вызов инструмента tool: его scope из ToolScopes. Без
+// OAuth-контекста (аутентификация выключена) проверять нечего.
+func checkToolScope(ctx context.Context, tool string) error {
+	if auth := oauth.FromContext(ctx); auth != nil {
+		if required := ToolScopes[tool]; !auth.HasScope(required) {
+			return permissionError{fmt.Sprintf("permission denied: tool %q requires scope %q", tool, required)}
+		}
+	}
+	return nil
+}
+
+// jsonStrings — список строк в том виде, в каком его дал бы JSON клиента: схема аргументов
+// вложенного вызова ждёт []any.
+func jsonStrings(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// checkReportCalls — checkReportCall для каждого вызова; первая ошибка прерывает проверку.
+func (h *Handler) checkReportCalls(ctx context.Context, calls []reportCall) error {
+	for _, c := range calls {
+		if err := h.checkReportCall(ctx, c.tool, c.args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchReport вызывает отчёт tool и разбирает ответ в таблицу. Аргументы должны быть уже
+// проверены checkReportCall.
+func (h *Handler) fetchReport(ctx context.Context, tool string, args map[string]any) (tableReport, error) {
+	result, err := reportCalls[tool](h, ctx, args)
+	if err != nil {
+		return tableReport{}, err
+	}
+	if len(result.Content) == 0 {
+		return tableReport{}, fmt.Errorf("%s: empty response", tool)
+	}
+	if result.IsError {
+		return tableReport{}, errors.New(result.Content[0].Text)
+	}
+	report, ok := decodeReport([]byte(result.Content[0].Text))
+	if !ok {
+		return tableReport{}, fmt.Errorf("%s: response has no columns", tool)
+	}
+	return report, nil
+}
+
+// fetchReports выполняет вызовы параллельно — каждый отчёт 1С считает отдельно, и ждать их по
+// очереди незачем. Ответы — в порядке calls; ошибки всех вызовов собираются вместе.
+//
+// Каждый вызов получает свою глубокую копию аргументов: разбор аргументов (unstringifyJSON)
+// переписывает вложенные map и срезы на месте, а составной инструмент обычно собирает вызовы из
+// общих частей (filters, group_by, period) — параллельная запись в одну map роняет процесс.
+func (h *Handler) fetchReports(ctx context.Context, calls []reportCall) ([]tableReport, error) {
+	reports := make([]tableReport, len(calls))
+	errs := make([]error, len(calls))
+
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		args := deepCopyArgs(c.args).(map[string]any)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = h.fetchReport(ctx, c.tool, args)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// deepCopyArgs — глубокая копия аргументов: map и срезы копируются, скаляры — как есть
+// (cloneArgs в names.go копирует только верхний уровень).
+func deepCopyArgs(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, val := range t {
+			m[k] = deepCopyArgs(val)
+		}
+		return m
+	case []any:
+		s := make([]any, len(t))
+		for i, val := range t {
+			s[i] = deepCopyArgs(val)
+		}
+		return s
+	case []string:
+		return append([]string(nil), t...)
+	default:
+		return v
+	}
+}
+
+// reportTotal — итог меры measure: из totals, а без них — сумма колонки по строкам.
+func reportTotal(t tableReport, measure string) float64 {
+	if totals, ok := t.fields["totals"].(map[string]any); ok {
+		if v, ok := totals[measure]; ok {
+			return numberValue(v)
+		}
+	}
+	var sum float64
+	for i, name := range t.columns {
+		if name != measure {
+			continue
+		}
+		for _, row := range t.rows {
+			if i < len(row) {
+				sum += numberValue(row[i])
+			}
+		}
+	}
+	return sum
+}
+
+// numberValue — числовая ячейка отчёта. Пустая ячейка — 0: в свёртках 1С пустота означает
+// отсутствие оборота.
+func numberValue(v any) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	case float64:
+		return t
+	}
+	return 0
+}
+
+// rowKey — ключ строки по ячейкам-измерениям: id ссылки, иначе текст значения.
+func rowKey(row []any, dims []int) string {
+	key := make([]string, len(dims))
+	for i, d := range dims {
+		if d >= len(row) {
+			continue
+		}
+		if id, _, ok := refCell(row[d]); ok {
+			key[i] = id
+		} else {
+			key[i] = cellText(row[d])
+		}
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+// cell — ячейка строки отчёта по имени колонки; нет колонки — nil.
+func (t tableReport) cell(row []any, name string) any {
+	for i, c := range t.columns {
+		if c == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return nil
+}
+
+// splitColumns делит колонки отчёта на измерения и меры: мера — колонка типа number.
+func splitColumns(t tableReport) (dims, measures []int) {
+	for i, typ := range t.types {
+		if typ == "number" {
+			measures = append(measures, i)
+		} else {
+			dims = append(dims, i)
+		}
+	}
+	return dims, measures
+}
@@ -0,0 +1,328 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Производные меры (derived). Модели постоянно ошибаются в простой арифметике поверх отчёта:
+// считают долю от суммы видимых строк, когда top уже отрезал хвост, а totals несут полные суммы;
+// путают порядок месяцев в нарастающем итоге; ранжируют по всему списку вместо группы. Гейт
+// считает эти колонки сам, по полученному ответу, и дописывает их в columns/rows:
+//
+//	share_of_total — доля меры в процентах. Без within — от totals ответа 1С (полная сумма и при
+//	                 обрезанных top строках), с within — от суммы строк с тем же значением within;
+//	running_total  — нарастающий итог по измерению-дате over внутри остальных измерений строки;
+//	rank           — место по убыванию меры (1 — наибольшее, равные делят место) внутри within
+//	                 или по всему ответу;
+//	pct_change     — изменение к предыдущему значению over в процентах внутри остальных измерений.
+//
+// Считаются до pivot и до нарезки на страницы: колонку можно развернуть, а страницы режут уже
+// дополненный ответ. В 1С derived не уходит, но в ключ страницы входит — как и pivot.
+
+const (
+	derivedShare     = "share_of_total"
+	derivedRunning   = "running_total"
+	derivedRank      = "rank"
+	derivedPctChange = "pct_change"
+
+	maxDerived = 8
+)
+
+var derivedTypes = []string{derivedShare, derivedRunning, derivedRank, derivedPctChange}
+
+// derivedSuffix — имя колонки: мера плюс суффикс типа (amount → amount_share_pct).
+var derivedSuffix = map[string]string{
+	derivedShare:     "_share_pct",
+	derivedRunning:   "_running_total",
+	derivedRank:      "_rank",
+	derivedPctChange: "_pct_change",
+}
+
+// derivedSpec — элемент аргумента derived.
+type derivedSpec struct {
+	Type    string `json:"type"`
+	Measure string `json:"measure"`
+	Over    string `json:"over"`
+	Within  string `json:"within"`
+}
+
+// derivedProp — аргумент derived табличных отчётов.
+func derivedProp() map[string]any {
+	return map[string]any{
+		"type":     "array",
+		"maxItems": maxDerived,
+		"description": "Columns the gateway computes from the result and appends to columns/rows, named <measure>_share_pct, <measure>_running_total, <measure>_rank, <measure>_pct_change. " +
+			"share_of_total is the percentage of the report total (from totals, so it stays right when top cuts rows) or, with `within`, of the rows with the same `within` value. " +
+			"running_total and pct_change go along the date dimension `over` (default: the date dimension of group_by) separately for each combination of the other dimensions; pct_change is vs the previous bucket present in the result. " +
+			"rank is 1 for the largest value, over the whole result or within each value of `within`. Prefer these to computing shares or changes yourself.",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"type":    map[string]any{"type": "string", "enum": derivedTypes},
+				"measure": map[string]any{"type": "string", "description": "Base measure (default: the first measure of the report)."},
+				"over":    map[string]any{"type": "string", "description": "running_total, pct_change: date dimension in group_by (day, week, month)."},
+				"within":  map[string]any{"type": "string", "description": "share_of_total, rank: parent dimension in group_by (e.g. product_group)."},
+			},
+			"required": []string{"type"},
+		},
+	}
+}
+
+// takeDerived отделяет аргумент derived. Схему он уже прошёл, поэтому разбор не падает.
+func takeDerived(args any) ([]derivedSpec, any) {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return nil, args
+	}
+	raw, present := m["derived"]
+	if !present {
+		return nil, args
+	}
+	rest := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != "derived" {
+			rest[k] = v
+		}
+	}
+	var specs []derivedSpec
+	if err := mapToStruct(raw, &specs); err != nil {
+		return nil, rest
+	}
+	return specs, rest
+}
+
+// nonAdditive — мера, сумма которой ничего не значит: отношения и средние отчётов, а также
+// производные колонки, кроме долей (доли строк складываются в долю группы).
+func nonAdditive(name string) bool {
+	if nonAdditiveMeasures[name] {
+		return true
+	}
+	for _, typ := range []string{derivedRunning, derivedRank, derivedPctChange} {
+		if strings.HasSuffix(name, derivedSuffix[typ]) {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveReport дописывает в ответ отчёта колонки по specs, по порядку: следующая спецификация
+// может опираться на колонку предыдущей (rank по amount_share_pct). Ответ-ошибка и ответ не в
+// форме {columns, rows} возвращаются как есть; неподходящие мера и измерения — ошибка аргумента.
+func deriveReport(result *CallToolResult, specs []derivedSpec) (*CallToolResult, error) {
+	if len(specs) == 0 || result == nil || result.IsError || len(result.Content) == 0 {
+		return result, nil
+	}
+	t, ok := decodeReport([]byte(result.Content[0].Text))
+	if !ok {
+		return result, nil
+	}
+
+	var errs argumentErrors
+	var echo []map[string]any
+	for i, spec := range specs {
+		path := fmt.Sprintf("derived[%d]", i)
+		column, values, specEcho, specErrs := deriveColumn(t, spec, path)
+		if len(specErrs) > 0 {
+			errs = append(errs, specErrs...)
+			continue
+		}
+		if containsString(t.columns, column) {
+			errs = append(errs, argumentError{Path: path, Message: fmt.Sprintf("column %q is already in the result", column)})
+			continue
+		}
+
+		t.columns = append(t.columns, column)
+		t.types = append(t.types, "number")
+		t.rawColumns = append(t.rawColumns, map[string]any{"name": column, "type": "number"})
+		for r, row := range t.rows {
+			for len(row) < len(t.columns)-1 {
+				row = append(row, nil)
+			}
+			t.rows[r] = append(row, values[r])
+		}
+		echo = append(echo, specEcho)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	resp := t.fields
+	resp["columns"] = t.rawColumns
+	resp["rows"] = t.rows
+	resp["derived"] = echo
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return rawToolResult(data), nil
+}
+
+// deriveColumn — имя и значения (по строке на строку ответа) одной производной колонки и её эхо
+// с подставленными по умолчанию мерой и over.
+func deriveColumn(t tableReport, spec derivedSpec, path string) (string, []any, map[string]any, argumentErrors) {
+	dims, measures := splitColumns(t)
+	dimNames := make([]string, len(dims))
+	for i, d := range dims {
+		dimNames[i] = t.columns[d]
+	}
+	measureNames := make([]string, len(measures))
+	for i, m := range measures {
+		measureNames[i] = t.columns[m]
+	}
+	index := func(name string) int {
+		for i, c := range t.columns {
+			if c == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	measure := spec.Measure
+	if measure == "" && len(measureNames) > 0 {
+		measure = measureNames[0]
+	}
+	timeDim := spec.Type == derivedRunning || spec.Type == derivedPctChange
+
+	var errs argumentErrors
+	valCol := index(measure)
+	switch {
+	case valCol < 0 || t.types[valCol] != "number":
+		errs = append(errs, argumentError{Path: path + ".measure", Message: fmt.Sprintf("%q is not a measure of the result", measure), Allowed: measureNames})
+	case (spec.Type == derivedShare || spec.Type == derivedRunning) && nonAdditive(measure):
+		errs = append(errs, argumentError{Path: path + ".measure", Message: fmt.Sprintf("%s cannot be summed, so %s is meaningless for it", measure, spec.Type)})
+	}
+
+	overCol := -1
+	switch {
+	case timeDim && spec.Over == "":
+		for _, d := range dims {
+			if t.types[d] == "date" {
+				overCol = d
+				break
+			}
+		}
+		if overCol < 0 {
+			errs = append(errs, argumentError{Path: path + ".over", Message: spec.Type + " needs a date dimension; add day, week or month to group_by"})
+		}
+	case timeDim:
+		if overCol = index(spec.Over); overCol < 0 || t.types[overCol] == "number" {
+			errs = append(errs, argumentError{Path: path + ".over", Message: fmt.Sprintf("%q is not a dimension of the result; add it to group_by", spec.Over), Allowed: dimNames})
+		}
+	case spec.Over != "":
+		errs = append(errs, argumentError{Path: path + ".over", Message: "applies to running_total and pct_change only"})
+	}
+
+	withinCol := -1
+	switch {
+	case spec.Within == "":
+	case timeDim:
+		errs = append(errs, argumentError{Path: path + ".within", Message: "applies to share_of_total and rank only; " + spec.Type + " already runs within the other dimensions"})
+	default:
+		if withinCol = index(spec.Within); withinCol < 0 || t.types[withinCol] == "number" {
+			errs = append(errs, argumentError{Path: path + ".within", Message: fmt.Sprintf("%q is not a dimension of the result; add it to group_by", spec.Within), Allowed: dimNames})
+		}
+	}
+	if len(errs) > 0 {
+		return "", nil, nil, errs
+	}
+
+	column := measure + derivedSuffix[spec.Type]
+	echo := map[string]any{"column": column, "type": spec.Type, "measure": measure}
+	if overCol >= 0 {
+		echo["over"] = t.columns[overCol]
+	}
+	if withinCol >= 0 {
+		echo["within"] = spec.Within
+	}
+
+	values := make([]float64, len(t.rows))
+	for r, row := range t.rows {
+		values[r] = numberValue(cellAt(row, valCol))
+	}
+	out := make([]any, len(t.rows))
+
+	switch spec.Type {
+	case derivedShare:
+		denominators := map[string]float64{}
+		if withinCol >= 0 {
+			for r, row := range t.rows {
+				denominators[rowKey(row, []int{withinCol})] += values[r]
+			}
+		}
+		grand := reportTotal(t, measure)
+		for r, row := range t.rows {
+			total := grand
+			if withinCol >= 0 {
+				total = denominators[rowKey(row, []int{withinCol})]
+			}
+			if total != 0 {
+				out[r] = roundTo(values[r]/total*100, 2)
+			}
+		}
+
+	case derivedRank:
+		var group []int
+		if withinCol >= 0 {
+			group = []int{withinCol}
+		}
+		for _, part := range partitions(t, group) {
+			sort.SliceStable(part, func(i, j int) bool { return values[part[i]] > values[part[j]] })
+			for i, r := range part {
+				if i > 0 && values[r] == values[part[i-1]] {
+					out[r] = out[part[i-1]]
+					continue
+				}
+				out[r] = i + 1
+			}
+		}
+
+	case derivedRunning, derivedPctChange:
+		var others []int
+		for _, d := range dims {
+			if d != overCol {
+				others = append(others, d)
+			}
+		}
+		for _, part := range partitions(t, others) {
+			sort.SliceStable(part, func(i, j int) bool {
+				return cellText(cellAt(t.rows[part[i]], overCol)) < cellText(cellAt(t.rows[part[j]], overCol))
+			})
+			var sum float64
+			for i, r := range part {
+				if spec.Type == derivedRunning {
+					sum += values[r]
+					out[r] = jsonNumber(sum)
+					continue
+				}
+				if i > 0 && values[part[i-1]] != 0 {
+					prev := values[part[i-1]]
+					out[r] = roundTo((values[r]-prev)/math.Abs(prev)*100, 1)
+				}
+			}
+		}
+	}
+	return column, out, echo, nil
+}
+
+// partitions — индексы строк, сгруппированные по значениям колонок dims, в порядке появления.
+func partitions(t tableReport, dims []int) [][]int {
+	var order []string
+	groups := map[string][]int{}
+	for r, row := range t.rows {
+		key := rowKey(row, dims)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+	out := make([][]int, 0, len(order))
+	for _, key := range order {
+		out = append(out, groups[key])
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// top отрезал третий товар: totals 1С несут полную сумму, доли — от неё, а не от видимых строк.
+const derivedSales = `{"columns":[{"name":"product","type":"ref"},{"name":"month","type":"date"},{"name":"amount","type":"number"}],` +
+	`"rows":[[{"id":"p1","label":"Tea"},"2026-02-01",30],[{"id":"p1","label":"Tea"},"2026-01-01",20],[{"id":"p2","label":"Coffee"},"2026-01-01",30],[{"id":"p2","label":"Coffee"},"2026-02-01",20]],` +
+	`"totals":{"amount":200},"period":{"from":"2026-01-01","to":"2026-02-28"}}`
+
+func TestDerivedMeasures(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = derivedSales
+
+	res := callTool(t, h, ToolSalesReport, map[string]any{
+		"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+		"group_by": []any{"product", "month"},
+		"top":      4,
+		"derived": []any{
+			map[string]any{"type": "share_of_total", "measure": "amount"},
+			map[string]any{"type": "running_total"},
+			map[string]any{"type": "pct_change", "over": "month"},
+			map[string]any{"type": "rank", "within": "month"},
+		},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+	if _, leaked := fake.recorded(t, 0).body["derived"]; leaked {
+		t.Error("derived reached 1C")
+	}
+
+	var got struct {
+		Columns []struct{ Name, Type string }
+		Rows    [][]any
+		Derived []map[string]any
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range got.Columns {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "product,month,amount,amount_share_pct,amount_running_total,amount_pct_change,amount_rank" {
+		t.Errorf("columns = %v", names)
+	}
+
+	// Порядок строк — как у 1С; нарастающий итог и изменение идут по месяцам внутри товара,
+	// место — внутри месяца, равные делят место.
+	rows, _ := json.Marshal(got.Rows)
+	want := `[[{"id":"p1","label":"Tea"},"2026-02-01",30,15,50,50,1],` +
+		`[{"id":"p1","label":"Tea"},"2026-01-01",20,10,20,null,2],` +
+		`[{"id":"p2","label":"Coffee"},"2026-01-01",30,15,30,null,1],` +
+		`[{"id":"p2","label":"Coffee"},"2026-02-01",20,10,50,-33.3,2]]`
+	if string(rows) != want {
+		t.Errorf("rows = %s", rows)
+	}
+	if len(got.Derived) != 4 || got.Derived[1]["over"] != "month" || got.Derived[1]["measure"] != "amount" {
+		t.Errorf("derived = %v", got.Derived)
+	}
+}
+
+// Производную колонку можно развернуть; running_total по ней суммировать нельзя — итоги null.
+func TestDerivedThenPivot(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = derivedSales
+
+	res := callTool(t, h, ToolSalesReport, map[string]any{
+		"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+		"group_by": []any{"product", "month"},
+		"derived":  []any{map[string]any{"type": "running_total", "measure": "amount"}},
+		"pivot":    map[string]any{"rows": "product", "columns": "month", "measure": "amount_running_total"},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+	var got struct {
+		Rows   [][]any
+		Totals map[string]any
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := json.Marshal(got.Rows)
+	if string(rows) != `[[{"id":"p1","label":"Tea"},20,50,null],[{"id":"p2","label":"Coffee"},30,50,null]]` {
+		t.Errorf("rows = %s", rows)
+	}
+	if got.Totals["total"] != nil {
+		t.Errorf("totals = %v", got.Totals)
+	}
+}
+
+func TestDerivedRejects(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = derivedSales
+
+	cases := []struct {
+		spec map[string]any
+		want string
+	}{
+		{map[string]any{"type": "share_of_total", "measure": "qty"}, `derived[0].measure: "qty" is not a measure`},
+		{map[string]any{"type": "rank", "within": "warehouse"}, `derived[0].within: "warehouse" is not a dimension`},
+		{map[string]any{"type": "running_total", "over": "product", "within": "month"}, "derived[0].within: applies to share_of_total and rank only"},
+		{map[string]any{"type": "rank", "over": "month"}, "derived[0].over: applies to running_total and pct_change only"},
+	}
+	for _, c := range cases {
+		res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+			"name": ToolSalesReport,
+			"arguments": map[string]any{
+				"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+				"group_by": []any{"product", "month"},
+				"derived":  []any{c.spec},
+			},
+		})
+		if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, c.want) {
+			t.Errorf("%v: error = %+v", c.spec, res.Error)
+		}
+	}
+
+	// Без измерения-даты нарастающему итогу не по чему идти.
+	fake.response = `{"columns":[{"name":"product","type":"ref"},{"name":"amount","type":"number"}],"rows":[],"totals":{"amount":0}}`
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name": ToolSalesReport,
+		"arguments": map[string]any{
+			"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+			"group_by": []any{"product"},
+			"derived":  []any{map[string]any{"type": "pct_change"}},
+		},
+	})
+	if res.Error == nil || !strings.Contains(res.Error.Message, "derived[0].over: pct_change needs a date dimension") {
+		t.Errorf("error = %+v", res.Error)
+	}
+}
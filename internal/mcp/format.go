@@ -0,0 +1,298 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Форматы ответа табличных отчётов. JSON с {id,label} в каждой ссылочной ячейке дорог в токенах:
+// на остатках в 2000 строк один и тот же склад повторяется тысячу раз вместе с UUID. Гейт
+// перерисовывает уже готовый ответ (после декодирования и нарезки на страницы), 1С о формате
+// не знает — output_format в запрос к ней не уходит и в хэш страницы не входит.
+//
+//	json     — как есть (по умолчанию);
+//	markdown — таблица с наименованиями вместо ссылок, остальные поля строками ниже;
+//	csv      — то же в CSV, остальные поля строками-комментариями «# ...»;
+//	compact  — JSON, где ссылочная ячейка — только id, а наименования собраны в словарь refs.
+//
+// structuredContent у markdown/csv остаётся JSON-объектом: outputSchema объявлена, и клиент
+// ревизии 2025-06-18 вправе на него рассчитывать. Сжимается текстовый блок — его и читает модель.
+const (
+	formatJSON     = "json"
+	formatMarkdown = "markdown"
+	formatCSV      = "csv"
+	formatCompact  = "compact"
+)
+
+var outputFormats = []string{formatJSON, formatMarkdown, formatCSV, formatCompact}
+
+// formattedTools — инструменты с ответом {columns, rows, totals}: у всех outputSchema построена
+// на reportOutput.
+var formattedTools = map[string]bool{
+	ToolSalesReport:            true,
+	ToolStockBalance:           true,
+	ToolAvailabilityReport:     true,
+	ToolCashBalance:            true,
+	ToolCashFlow:               true,
+	ToolReceivablesBalance:     true,
+	ToolPayablesBalance:        true,
+	ToolPurchasesReport:        true,
+	ToolGoodsInTransit:         true,
+	ToolProductSpecification:   true,
+	ToolSpecificationCost:      true,
+	ToolSpecificationExplode:   true,
+	ToolSpecificationWhereUsed: true,
+	ToolSpecificationList:      true,
+	ToolProductionOutput:       true,
+	ToolProductionConsumption:  true,
+	ToolComparePeriods:         true,
+	ToolABCXYZAnalysis:         true,
+	ToolReorderSuggestions:     true,
+	ToolDetectAnomalies:        true,
+	ToolRFMSegments:            true,
+}
+
+// outputFormatProp — аргумент output_format табличных отчётов.
+func outputFormatProp() map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": outputFormats,
+		"description": "Response format (default: json). markdown — a table with labels instead of {id,label} cells, other fields listed below it; csv — the same as CSV; " +
+			"compact — JSON where a reference cell is just its id and labels are collected once in a refs dictionary (id → label). Use markdown/csv/compact for large reports to save context.",
+	}
+}
+
+// renderReport перерисовывает ответ отчёта в format. Ответ-ошибка, json и ответ не в форме
+// {columns, rows} возвращаются как есть.
+func renderReport(result *CallToolResult, format string) (*CallToolResult, error) {
+	if format == "" || format == formatJSON || result.IsError || len(result.Content) == 0 {
+		return result, nil
+	}
+
+	data := []byte(result.Content[0].Text)
+	report, ok := decodeReport(data)
+	if !ok {
+		return result, nil
+	}
+
+	switch format {
+	case formatCompact:
+		compact, err := json.Marshal(compactReport(report))
+		if err != nil {
+			return nil, err
+		}
+		return rawToolResult(compact), nil
+	case formatMarkdown:
+		return textWithStructured(markdownReport(report), data), nil
+	case formatCSV:
+		text, err := csvReport(report)
+		if err != nil {
+			return nil, err
+		}
+		return textWithStructured(text, data), nil
+	}
+	return result, nil
+}
+
+// textWithStructured — текстовый блок в формате клиента при JSON в structuredContent.
+func textWithStructured(text string, data []byte) *CallToolResult {
+	result := rawToolResult(data)
+	result.Content = []ContentBlock{TextContent(text)}
+	return result
+}
+
+// tableReport — ответ отчёта, разобранный для перерисовки.
+type tableReport struct {
+	columns    []string // имена колонок
+	types      []string // типы колонок: ref, number, string, date
+	rawColumns []any    // колонки как пришли — для compact
+	rows       [][]any
+	fields     map[string]any // всё, кроме columns и rows
+}
+
+func decodeReport(data []byte) (tableReport, bool) {
+	// UseNumber — числа выводятся ровно так, как их прислала 1С.
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return tableReport{}, false
+	}
+	columns, ok := raw["columns"].([]any)
+	if !ok {
+		return tableReport{}, false
+	}
+	rows, _ := raw["rows"].([]any)
+
+	t := tableReport{rawColumns: columns, fields: raw}
+	for _, c := range columns {
+		var name, typ string
+		if col, ok := c.(map[string]any); ok {
+			name, _ = col["name"].(string)
+			typ, _ = col["type"].(string)
+		}
+		t.columns = append(t.columns, name)
+		t.types = append(t.types, typ)
+	}
+	for _, r := range rows {
+		row, _ := r.([]any)
+		t.rows = append(t.rows, row)
+	}
+	delete(raw, "columns")
+	delete(raw, "rows")
+	return t, true
+}
+
+// refCell — ссылочная ячейка {id,label}.
+func refCell(v any) (id, label string, ok bool) {
+	m, isMap := v.(map[string]any)
+	if !isMap {
+		return "", "", false
+	}
+	id, hasID := m["id"].(string)
+	label, hasLabel := m["label"].(string)
+	return id, label, hasID && hasLabel
+}
+
+// cellText — ячейка для текстовых форматов: ссылка — наименованием, пусто — пустой строкой.
+func cellText(v any) string {
+	if _, label, ok := refCell(v); ok {
+		return label
+	}
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return fmt.Sprint(t)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// rowText — строка отчёта по числу колонок: недостающие ячейки пустые, лишние отбрасываются.
+func rowText(row []any, n int) []string {
+	cells := make([]string, n)
+	for i := range cells {
+		if i < len(row) {
+			cells[i] = cellText(row[i])
+		}
+	}
+	return cells
+}
+
+// fieldLines — поля ответа помимо таблицы (totals, period, applied_filters, next_cursor, …)
+// строками «имя: значение» в порядке имён.
+func fieldLines(fields map[string]any) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+": "+cellText(fields[name]))
+	}
+	return lines
+}
+
+func markdownReport(t tableReport) string {
+	var b strings.Builder
+
+	if len(t.columns) > 0 {
+		writeMarkdownRow(&b, t.columns)
+		sep := make([]string, len(t.columns))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		writeMarkdownRow(&b, sep)
+		for _, row := range t.rows {
+			writeMarkdownRow(&b, rowText(row, len(t.columns)))
+		}
+	}
+	if len(t.rows) == 0 {
+		b.WriteString("(no rows)\n")
+	}
+
+	if lines := fieldLines(t.fields); len(lines) > 0 {
+		b.WriteString("\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+
+func writeMarkdownRow(b *strings.Builder, cells []string) {
+	b.WriteString("|")
+	for _, c := range cells {
+		b.WriteString(" " + markdownCell.Replace(c) + " |")
+	}
+	b.WriteString("\n")
+}
+
+func csvReport(t tableReport) (string, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+
+	if err := w.Write(t.columns); err != nil {
+		return "", err
+	}
+	for _, row := range t.rows {
+		if err := w.Write(rowText(row, len(t.columns))); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+
+	if lines := fieldLines(t.fields); len(lines) > 0 {
+		b.WriteString("\n")
+		for _, line := range lines {
+			b.WriteString("# " + line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// compactReport — JSON-ответ, где ссылочные ячейки заменены их id, а наименования вынесены в refs.
+// Колонки остаются с типами (ref), так что модель знает, какие id искать в словаре.
+func compactReport(t tableReport) map[string]any {
+	refs := map[string]string{}
+	rows := make([][]any, len(t.rows))
+	for i, row := range t.rows {
+		out := make([]any, len(row))
+		for j, v := range row {
+			if id, label, ok := refCell(v); ok {
+				out[j] = id
+				if id != "" {
+					refs[id] = label
+				}
+				continue
+			}
+			out[j] = v
+		}
+		rows[i] = out
+	}
+
+	report := make(map[string]any, len(t.fields)+3)
+	for k, v := range t.fields {
+		report[k] = v
+	}
+	report["columns"] = t.rawColumns
+	report["rows"] = rows
+	report["refs"] = refs
+	return report
+}
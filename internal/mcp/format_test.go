@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const stockFixture = `{"columns":[{"name":"warehouse","type":"ref"},{"name":"product","type":"ref"},{"name":"qty","type":"number"}],` +
+	`"rows":[[{"id":"w-1","label":"Main | central"},{"id":"p-1","label":"Chair"},12],[{"id":"w-1","label":"Main | central"},{"id":"p-2","label":"Desk"},0.5]],` +
+	`"totals":{"qty":12.5},"date":"2026-01-31"}`
+
+func TestOutputFormatMarkdownAndCSV(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = stockFixture
+
+	md := callTool(t, h, ToolStockBalance, map[string]any{"output_format": "markdown"})
+	want := "| warehouse | product | qty |\n" +
+		"| --- | --- | --- |\n" +
+		"| Main \\| central | Chair | 12 |\n" +
+		"| Main \\| central | Desk | 0.5 |\n" +
+		"\n" +
+		"date: 2026-01-31\n" +
+		"totals: {\"qty\":12.5}\n"
+	if got := resultText(t, md); got != want {
+		t.Errorf("markdown:\n%s\nwant:\n%s", got, want)
+	}
+	if md.StructuredContent == nil {
+		t.Error("markdown result lost structuredContent")
+	}
+
+	csv := callTool(t, h, ToolStockBalance, map[string]any{"output_format": "csv"})
+	if got := resultText(t, csv); !strings.HasPrefix(got, "warehouse,product,qty\nMain | central,Chair,12\n") ||
+		!strings.Contains(got, "\n# totals: {\"qty\":12.5}\n") {
+		t.Errorf("csv:\n%s", got)
+	}
+
+	// output_format — указание гейту, в 1С не уходит.
+	if _, sent := fake.recorded(t, 0).body["output_format"]; sent {
+		t.Error("output_format was sent to 1C")
+	}
+}
+
+func TestOutputFormatCompact(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = stockFixture
+
+	res := callTool(t, h, ToolStockBalance, map[string]any{"output_format": "compact"})
+
+	var got struct {
+		Columns []map[string]string `json:"columns"`
+		Rows    [][]any             `json:"rows"`
+		Refs    map[string]string   `json:"refs"`
+		Totals  map[string]any      `json:"totals"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rows[1][0] != "w-1" || got.Rows[1][1] != "p-2" || got.Rows[1][2] != 0.5 {
+		t.Errorf("rows = %v", got.Rows)
+	}
+	if len(got.Refs) != 3 || got.Refs["w-1"] != "Main | central" {
+		t.Errorf("refs = %v", got.Refs)
+	}
+	if got.Columns[0]["type"] != "ref" || got.Totals["qty"] != 12.5 {
+		t.Errorf("columns = %v, totals = %v", got.Columns, got.Totals)
+	}
+}
+
+// Ответ не в форме таблицы и формат json не трогаются.
+func TestOutputFormatLeavesOtherResponsesAlone(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = stockFixture
+
+	res := callTool(t, h, ToolStockBalance, map[string]any{"output_format": "json"})
+	if !strings.Contains(resultText(t, res), `{"id":"w-1","label":"Main | central"}`) {
+		t.Errorf("json format changed the response: %s", resultText(t, res))
+	}
+
+	passthrough := &CallToolResult{Content: []ContentBlock{TextContent(`{"document":{}}`)}}
+	out, err := renderReport(passthrough, formatMarkdown)
+	if err != nil || out != passthrough {
+		t.Errorf("non-table response re-rendered: %+v, %v", out, err)
+	}
+}
@@ -1,8 +1,11 @@
 package mcp
 
 import (
+	"bytes"
+	"context"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -17,113 +20,194 @@ import (
 // Handler обслуживает /{tenant}/mcp одной базы: onecClient уже указывает на нужную 1С,
 // bearerToken — статический токен этой базы (пусто, когда включён OAuth и аутентификацию
 // делает внешний middleware). cfg нужен только ради общих лимитов.
+//
+// sessions — общий на гейт реестр сессий Streamable HTTP (см. Sessions); tenant — слаг базы,
+// которым сессия помечается при initialize, чтобы её нельзя было предъявить другой базе.
 type Handler struct {
+	tenant      string
 	onecClient  *onec.Client
 	cfg         *config.Config
+	sessions    *Sessions
 	logger      *slog.Logger
 	bearerToken string
+
+	// progressEvery — период heartbeat'ов notifications/progress (progressInterval; в тестах короче).
+	progressEvery time.Duration
 }
 
-func NewHandler(onecClient *onec.Client, cfg *config.Config, bearerToken string, logger *slog.Logger) *Handler {
+// NewHandler собирает обработчик базы. sessions == nil — свой приватный реестр: так удобно
+// в тестах, но в гейте реестр передаётся общий, чтобы сессии переживали Registry.Reload.
+func NewHandler(tenant string, onecClient *onec.Client, cfg *config.Config, bearerToken string, sessions *Sessions, logger *slog.Logger) *Handler {
+	if sessions == nil {
+		sessions = NewSessions()
+	}
 	return &Handler{
+		tenant:      tenant,
 		onecClient:  onecClient,
 		cfg:         cfg,
+		sessions:    sessions,
 		logger:      logger,
 		bearerToken: bearerToken,
+
+		progressEvery: progressInterval,
 	}
 }
 
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if h.bearerToken != "" && !h.authenticate(r) {
-		h.writeResponse(w, Unauthorized(nil))
-		return
+func (h *Handler) authenticate(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return false
 	}
-
-	var req Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeResponse(w, ParseError(nil))
-		return
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return false
 	}
+	return subtle.ConstantTimeCompare([]byte(parts[1]), []byte(h.bearerToken)) == 1
+}
 
-	if req.JSONRPC != JSONRPCVersion {
-		h.writeResponse(w, InvalidRequest(req.ID))
-		return
+func (h *Handler) writeResponse(w http.ResponseWriter, msg any) {
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		h.logger.Error("failed to encode response", "error", err)
 	}
+}
 
-	h.logger.Info("mcp request", "method", req.Method, "id", req.ID)
-
-	// Уведомление (JSON-RPC 2.0 §4.1): нет id — ответа быть не должно, даже об ошибке.
-	// Сюда попадает notifications/initialized, который шлёт каждый MCP-клиент после initialize.
-	// Раньше он проваливался в default и получал "Method not found" — формально нарушение спеки.
-	if req.ID == nil {
-		w.WriteHeader(http.StatusAccepted)
-		return
+// dispatch — маршрутизация одного JSON-RPC запроса по методу. Не знает ничего о транспорте:
+// POST с JSON-ответом и POST с SSE-ответом зовут её одинаково.
+func (h *Handler) dispatch(ctx context.Context, req Request) *Response {
+	// Каждый запрос с id регистрируется как выполняющийся: notifications/cancelled отменяет его
+	// контекст, а с ним и HTTP-запрос в 1С (doRequest ходит с этим же ctx).
+	ctx, done := h.sessions.calls.start(ctx, callContextFrom(ctx).scope, req.ID)
+	resp := h.route(ctx, req)
+
+	// Отменённому запросу ответ не отправляется (спецификация MCP, cancellation): клиент
+	// о нём уже забыл. Транспорт отдаёт 202 без тела или закрывает SSE-поток без события.
+	if done() {
+		h.logger.Info("mcp.request.cancelled", "method", req.Method, "id", req.ID)
+		return nil
 	}
+	return resp
+}
 
-	var resp *Response
+func (h *Handler) route(ctx context.Context, req Request) *Response {
 	switch req.Method {
 	case "initialize":
-		resp = h.handleInitialize(req)
+		return h.handleInitialize(req)
 	case "tools/list":
-		resp = h.handleToolsList(r, req)
+		return h.handleToolsList(ctx, req)
 	case "tools/call":
-		resp = h.handleToolsCall(r, req)
+		return h.handleToolsCall(ctx, req)
+	case "resources/list":
+		return h.handleResourcesList(ctx, req)
+	case "resources/templates/list":
+		return h.handleResourceTemplatesList(ctx, req)
+	case "resources/read":
+		return h.handleResourcesRead(ctx, req)
+	case "prompts/list":
+		return h.handlePromptsList(ctx, req)
+	case "prompts/get":
+		return h.handlePromptsGet(ctx, req)
+	case "logging/setLevel":
+		return h.handleSetLevel(ctx, req)
+	case "completion/complete":
+		// В 2024-11-05 completions нет: capability не объявлена, и метода для клиента тоже нет.
+		if !protocolFeaturesFrom(ctx).completions {
+			return MethodNotFound(req.ID, req.Method)
+		}
+		return h.handleComplete(ctx, req)
 	default:
-		resp = MethodNotFound(req.ID, req.Method)
+		return MethodNotFound(req.ID, req.Method)
 	}
-
-	h.writeResponse(w, resp)
 }
 
-func (h *Handler) authenticate(r *http.Request) bool {
-	auth := r.Header.Get("Authorization")
-	if auth == "" {
-		return false
-	}
-	parts := strings.SplitN(auth, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return false
+// handleNotification обрабатывает уведомления клиента. Ответа на уведомление нет ни при
+// каком исходе, поэтому ошибки разбора просто глотаются. Неизвестные уведомления
+// (notifications/initialized и т. п.) игнорируются.
+func (h *Handler) handleNotification(ctx context.Context, req Request) {
+	switch req.Method {
+	case "notifications/cancelled":
+		var params CancelledParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.RequestID == nil {
+			return
+		}
+		if h.sessions.calls.cancel(callContextFrom(ctx).scope, params.RequestID) {
+			h.logger.Info("mcp.request.cancel", "id", params.RequestID, "reason", params.Reason)
+		}
 	}
-	return subtle.ConstantTimeCompare([]byte(parts[1]), []byte(h.bearerToken)) == 1
 }
 
-func (h *Handler) writeResponse(w http.ResponseWriter, resp *Response) {
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		h.logger.Error("failed to encode response", "error", err)
+// handleInitialize отвечает версией протокола (negotiateVersion): поддерживаемую ревизию клиента
+// подтверждаем, на незнакомую — отвечаем новейшей, LatestProtocolVersion. Возможности ответа —
+// по согласованной ревизии (protocolRevisions).
+func (h *Handler) handleInitialize(req Request) *Response {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return InvalidParams(req.ID, "failed to parse params")
+		}
 	}
-}
 
-func (h *Handler) handleInitialize(req Request) *Response {
+	version := negotiateVersion(params.ProtocolVersion)
+
 	result := InitializeResult{
-		ProtocolVersion: ProtocolVersion,
+		ProtocolVersion: version,
 		ServerInfo: ServerInfo{
 			Name:    "mcp-sales-mvp",
 			Version: "1.0.0",
 		},
 		Capabilities: Capabilities{
-			Tools: &ToolsCapability{},
+			Tools:     &ToolsCapability{ListChanged: true},
+			Resources: &ResourcesCapability{},
+			Prompts:   &PromptsCapability{},
+			Logging:   &LoggingCapability{},
 		},
 	}
+	if featuresFor(version).completions {
+		result.Capabilities.Completions = &CompletionsCapability{}
+	}
 	return NewResponse(req.ID, result)
 }
 
 // handleToolsList фильтрует список инструментов по scopes авторизованного пользователя:
 // LLM показывается только то, что разрешено, и не пытается вызывать заведомо запрещённое.
 // Когда OAuth не активен (FromContext возвращает nil) — отдаём всё, как было.
-func (h *Handler) handleToolsList(r *http.Request, req Request) *Response {
-	auth := oauth.FromContext(r.Context())
+//
+// Список режется на страницы по mcp.tools_page_size (см. pageTools).
+func (h *Handler) handleToolsList(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	var params ListToolsParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return InvalidParams(req.ID, "failed to parse params")
+		}
+	}
+
+	tools, next, ok := pageTools(visibleTools(auth), params.Cursor, h.cfg.MCP.ToolsPageSize)
+	if !ok {
+		return InvalidParams(req.ID, "invalid cursor")
+	}
+
+	adaptTools(tools, protocolFeaturesFrom(ctx))
+
+	sub, cid := authIdentity(auth)
+	h.logger.Info("mcp.tool.list", "sub", sub, "client_id", cid, "count", len(tools))
+
+	return NewResponse(req.ID, ListToolsResult{Tools: tools, NextCursor: next})
+}
+
+// visibleTools — инструменты со схемами в том виде, в каком их видит вызывающий: без закрытых
+// его правами и без мер себестоимости в enum, если права на них нет. Общая точка для tools/list
+// и completion/complete — подсказка не должна предлагать то, что список не показывает.
+func visibleTools(auth *oauth.AuthInfo) []Tool {
 	tools := GetTools()
 
 	if auth != nil {
 		filtered := make([]Tool, 0, len(tools))
 		for _, t := range tools {
-			required, mapped := ToolScopes[t.Name]
-			if !mapped {
+			if _, mapped := ToolScopes[t.Name]; !mapped {
 				continue
 			}
-			if auth.HasScope(required) {
+			if _, denied := missingScope(auth, t.Name); !denied {
 				filtered = append(filtered, t)
 			}
 		}
@@ -135,16 +219,38 @@ func (h *Handler) handleToolsList(r *http.Request, req Request) *Response {
 			stripCostMeasures(tools)
 		}
 	}
+	return tools
+}
 
-	sub, cid := authIdentity(auth)
-	h.logger.Info("mcp.tool.list", "sub", sub, "client_id", cid, "count", len(tools))
-
-	return NewResponse(req.ID, ListToolsResult{Tools: tools})
+// missingScope — первое из прав инструмента tool (ToolScopes и toolExtraScopes), которого нет
+// у auth; для инструментов из toolAnyScopes — их список, если нет ни одного. auth == nil (OAuth
+// выключен) — отказа нет.
+func missingScope(auth *oauth.AuthInfo, tool string) (string, bool) {
+	if auth == nil {
+		return "", false
+	}
+	if anyOf, ok := toolAnyScopes[tool]; ok {
+		for _, scope := range anyOf {
+			if auth.HasScope(scope) {
+				return "", false
+			}
+		}
+		return strings.Join(anyOf, " or "), true
+	}
+	if required := ToolScopes[tool]; !auth.HasScope(required) {
+		return required, true
+	}
+	for _, required := range toolExtraScopes[tool] {
+		if !auth.HasScope(required) {
+			return required, true
+		}
+	}
+	return "", false
 }
 
-func (h *Handler) handleToolsCall(r *http.Request, req Request) *Response {
+func (h *Handler) handleToolsCall(ctx context.Context, req Request) *Response {
 	started := time.Now()
-	auth := oauth.FromContext(r.Context())
+	auth := oauth.FromContext(ctx)
 
 	var params CallToolParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
@@ -152,15 +258,14 @@ func (h *Handler) handleToolsCall(r *http.Request, req Request) *Response {
 		return InvalidParams(req.ID, "failed to parse params")
 	}
 
-	required, ok := ToolScopes[params.Name]
-	if !ok {
+	if _, ok := ToolScopes[params.Name]; !ok {
 		h.auditToolCall(auth, params.Name, false, "unknown_tool", started)
 		return InvalidParams(req.ID, "unknown tool: "+params.Name)
 	}
 
-	// Per-tool ACL: проверяем, что в Bearer-токене присутствует нужный scope.
+	// Per-tool ACL: проверяем, что в Bearer-токене присутствуют нужные scope.
 	// При OAuth=off (FromContext nil) проверка пропускается — поведение совместимо с легаси-бирером.
-	if auth != nil && !auth.HasScope(required) {
+	if required, denied := missingScope(auth, params.Name); denied {
 		h.logger.Warn("oauth.scope.denied",
 			"tool", params.Name, "required", required, "sub", auth.Sub, "have", auth.Scope)
 		h.auditToolCall(auth, params.Name, false, "scope_denied", started)
@@ -190,90 +295,212 @@ func (h *Handler) handleToolsCall(r *http.Request, req Request) *Response {
 		}
 	}
 
+	// Аргументы — по схеме инструмента, до похода в 1С (см. validate.go). Схема берётся полная,
+	// без stripCostMeasures: меры себестоимости без права уже отсечены выше своим сообщением.
+	params.Arguments = unstringifyJSON(params.Arguments)
+	args, resolved, err := h.resolvePeriods(params.Arguments)
+	var periodErrs argumentErrors
+	if errors.As(err, &periodErrs) {
+		h.auditToolCall(auth, params.Name, false, "invalid_arguments", started)
+		return NewErrorResponse(req.ID, CodeInvalidParams, periodErrs.Error(), map[string]any{"errors": periodErrs})
+	}
+	params.Arguments = args
+	if errs := validateArguments(toolInputSchema(params.Name), params.Arguments); len(errs) > 0 {
+		h.auditToolCall(auth, params.Name, false, "invalid_arguments", started)
+		return NewErrorResponse(req.ID, CodeInvalidParams, formatArgumentErrors(errs), map[string]any{"errors": errs})
+	}
+
+	// Heartbeat для долгих вызовов: клиент, приславший progressToken, видит, что 1С ещё считает.
+	if params.Meta != nil && params.Meta.ProgressToken != nil && tracksProgress(params.Name) {
+		if notify := callContextFrom(ctx).notify; notify != nil {
+			stop := startProgress(ctx, notify, params.Meta.ProgressToken, h.progressEvery)
+			defer stop()
+		}
+	}
+
+	ctx = withCacheLog(ctx, params.Name)
+
+	// Формат ответа рисует гейт (см. format.go) — в 1С он не уходит.
+	var format string
+	if formattedTools[params.Name] {
+		format, params.Arguments = takeArgument(params.Arguments, "output_format")
+	}
+
+	// Наименования в фильтрах → UUID (см. names.go). До ключа страницы: он считается по UUID.
+	params.Arguments, err = h.resolveNameFilters(ctx, params.Arguments)
+
+	// Табличный отчёт с cursor — следующая страница сохранённого результата, 1С не вызывается.
+	var cursor, pageRequest string
+	if pageableTools[params.Name] {
+		cursor, params.Arguments = takeArgument(params.Arguments, "cursor")
+		sub, _ := authIdentity(auth)
+		pageRequest = pageKey(h.tenant, sub, params.Name, params.Arguments)
+	}
+
+	// Сводную таблицу и производные меры строит гейт (см. pivot.go, derived.go): pivot и derived
+	// входят в ключ страницы, но не в запрос к 1С.
+	var pivot *pivotSpec
+	var derived []derivedSpec
+	if pivotTools[params.Name] {
+		pivot, params.Arguments = takePivot(params.Arguments)
+		derived, params.Arguments = takeDerived(params.Arguments)
+	}
+
 	var result *CallToolResult
-	var err error
+	switch {
+	case err != nil:
+	case cursor != "":
+		if result, err = h.nextPage(pageRequest, cursor); err != nil {
+			h.auditToolCall(auth, params.Name, false, "invalid_cursor", started)
+			return InvalidParams(req.ID, err.Error())
+		}
+	default:
+		result, err = h.dispatchTool(ctx, params.Name, params.Arguments)
+		if errors.Is(err, errUnknownTool) {
+			h.auditToolCall(auth, params.Name, false, "unknown_tool", started)
+			return InvalidParams(req.ID, "unknown tool: "+params.Name)
+		}
+		if err == nil {
+			result = withResolvedPeriods(result, resolved)
+			result, err = deriveReport(result, derived)
+		}
+		if err == nil {
+			result, err = pivotReport(result, pivot)
+		}
+		if err == nil && pageRequest != "" {
+			result, err = h.paginate(pageRequest, result)
+		}
+	}
+	if err == nil {
+		result, err = renderReport(result, format)
+	}
+
+	// Составные инструменты проверяют вложенный отчёт сами (см. composite.go), имена в фильтрах
+	// резолвятся здесь же (names.go) — их отказы отдаются так же, как отказы верхнего уровня.
+	var argErrs argumentErrors
+	if errors.As(err, &argErrs) {
+		h.auditToolCall(auth, params.Name, false, "invalid_arguments", started)
+		return NewErrorResponse(req.ID, CodeInvalidParams, argErrs.Error(), map[string]any{"errors": argErrs})
+	}
+	var denied permissionError
+	if errors.As(err, &denied) {
+		sub, _ := authIdentity(auth)
+		h.logger.Warn("oauth.scope.denied", "tool", params.Name, "sub", sub, "reason", denied.message)
+		h.auditToolCall(auth, params.Name, false, "scope_denied", started)
+		return NewResponse(req.ID, &CallToolResult{
+			Content: []ContentBlock{TextContent(denied.message)},
+			IsError: true,
+		})
+	}
+
+	if err != nil {
+		h.logger.Error("tool call failed", "tool", params.Name, "error", err)
+		logToolError(ctx, params.Name, err)
+		h.auditToolCall(auth, params.Name, false, "tool_error", started)
+		return NewResponse(req.ID, &CallToolResult{
+			Content: []ContentBlock{TextContent(err.Error())},
+			IsError: true,
+		})
+	}
+
+	h.auditToolCall(auth, params.Name, true, "", started)
+	return NewResponse(req.ID, h.structured(ctx, result))
+}
 
-	switch params.Name {
+// structured оставляет structuredContent только клиентам ревизии, где он есть; текстовая копия
+// в content остаётся у всех.
+func (h *Handler) structured(ctx context.Context, result *CallToolResult) *CallToolResult {
+	if !protocolFeaturesFrom(ctx).structuredOutput {
+		result.StructuredContent = nil
+	}
+	return result
+}
+
+// errUnknownTool — имя есть в ToolScopes, но dispatchTool его не знает: инструмент объявлен,
+// а обработчик не подключён.
+var errUnknownTool = errors.New("unknown tool")
+
+// dispatchTool вызывает обработчик инструмента name.
+func (h *Handler) dispatchTool(ctx context.Context, name string, args any) (*CallToolResult, error) {
+	switch name {
 	case ToolResolveCustomer:
-		result, err = h.callResolveCustomer(r, params.Arguments)
+		return h.callResolveCustomer(ctx, args)
 	case ToolResolveWarehouse:
-		result, err = h.callResolveWarehouse(r, params.Arguments)
+		return h.callResolveWarehouse(ctx, args)
 	case ToolResolveProduct:
-		result, err = h.callResolveProduct(r, params.Arguments)
+		return h.callResolveProduct(ctx, args)
 	case ToolResolveMaterial:
-		result, err = h.callResolveMaterial(r, params.Arguments)
+		return h.callResolveMaterial(ctx, args)
 	case ToolResolveSalesChannel:
-		result, err = h.callResolveSalesChannel(r, params.Arguments)
+		return h.callResolveSalesChannel(ctx, args)
 	case ToolResolveCash:
-		result, err = h.callResolveCash(r, params.Arguments)
+		return h.callResolveCash(ctx, args)
 	case ToolResolveCostArticle:
-		result, err = h.callResolveCostArticle(r, params.Arguments)
+		return h.callResolveCostArticle(ctx, args)
 	case ToolResolveOperation:
-		result, err = h.callResolveOperation(r, params.Arguments)
+		return h.callResolveOperation(ctx, args)
 	case ToolCashBalance:
-		result, err = h.callCashBalance(r, params.Arguments)
+		return h.callCashBalance(ctx, args)
 	case ToolCashFlow:
-		result, err = h.callCashFlow(r, params.Arguments)
+		return h.callCashFlow(ctx, args)
 	case ToolReceivablesBalance:
-		result, err = h.callReceivablesBalance(r, params.Arguments)
+		return h.callReceivablesBalance(ctx, args)
 	case ToolPayablesBalance:
-		result, err = h.callPayablesBalance(r, params.Arguments)
+		return h.callPayablesBalance(ctx, args)
 	case ToolPurchasesReport:
-		result, err = h.callPurchasesReport(r, params.Arguments)
+		return h.callPurchasesReport(ctx, args)
 	case ToolGoodsInTransit:
-		result, err = h.callGoodsInTransit(r, params.Arguments)
+		return h.callGoodsInTransit(ctx, args)
 	case ToolSalesReport:
-		result, err = h.callSalesReport(r, params.Arguments)
+		return h.callSalesReport(ctx, args)
 	case ToolStockBalance:
-		result, err = h.callStockBalance(r, params.Arguments)
+		return h.callStockBalance(ctx, args)
 	case ToolAvailabilityReport:
-		result, err = h.callAvailabilityReport(r, params.Arguments)
+		return h.callAvailabilityReport(ctx, args)
 	case ToolProductDetails:
-		result, err = h.callProductDetails(r, params.Arguments)
+		return h.callProductDetails(ctx, args)
 	case ToolTopProducts:
-		result, err = h.callTopProducts(r, params.Arguments)
+		return h.callTopProducts(ctx, args)
 	case ToolCustomerSummary:
-		result, err = h.callCustomerSummary(r, params.Arguments)
+		return h.callCustomerSummary(ctx, args)
 	case ToolEventLog:
-		result, err = h.callEventLog(r, params.Arguments)
+		return h.callEventLog(ctx, args)
 	case ToolObjectHistory:
-		result, err = h.callEventLog(r, params.Arguments)
+		return h.callEventLog(ctx, args)
 	case ToolFindDocument:
-		result, err = h.callFindDocument(r, params.Arguments)
+		return h.callFindDocument(ctx, args)
 	case ToolProductSpecification:
-		result, err = h.callSpecification(r, onec.ReportSpecification, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecification, args)
 	case ToolSpecificationCost:
-		result, err = h.callSpecification(r, onec.ReportSpecificationCost, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecificationCost, args)
 	case ToolSpecificationExplode:
-		result, err = h.callSpecification(r, onec.ReportSpecificationExplode, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecificationExplode, args)
 	case ToolSpecificationWhereUsed:
-		result, err = h.callSpecification(r, onec.ReportSpecificationWhereUsed, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecificationWhereUsed, args)
 	case ToolSpecificationVersions:
-		result, err = h.callSpecification(r, onec.ReportSpecificationVersions, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecificationVersions, args)
 	case ToolSpecificationList:
-		result, err = h.callSpecification(r, onec.ReportSpecificationList, params.Arguments)
+		return h.callSpecification(ctx, onec.ReportSpecificationList, args)
 	case ToolProductionOutput:
-		result, err = h.callProductionReport(r, onec.ReportProductionOutput, params.Arguments)
+		return h.callProductionReport(ctx, onec.ReportProductionOutput, args)
 	case ToolProductionConsumption:
-		result, err = h.callProductionReport(r, onec.ReportProductionConsumption, params.Arguments)
+		return h.callProductionReport(ctx, onec.ReportProductionConsumption, args)
 	case ToolProductionDocumentDetail:
-		result, err = h.callProductionDocument(r, params.Arguments)
-	default:
-		h.auditToolCall(auth, params.Name, false, "unknown_tool", started)
-		return InvalidParams(req.ID, "unknown tool: "+params.Name)
-	}
-
-	if err != nil {
-		h.logger.Error("tool call failed", "tool", params.Name, "error", err)
-		h.auditToolCall(auth, params.Name, false, "tool_error", started)
-		return NewResponse(req.ID, &CallToolResult{
-			Content: []ContentBlock{TextContent(err.Error())},
-			IsError: true,
-		})
-	}
-
-	h.auditToolCall(auth, params.Name, true, "", started)
-	return NewResponse(req.ID, result)
+		return h.callProductionDocument(ctx, args)
+	case ToolComparePeriods:
+		return h.callComparePeriods(ctx, args)
+	case ToolCashConversionCycle:
+		return h.callCashConversionCycle(ctx, args)
+	case ToolABCXYZAnalysis:
+		return h.callABCXYZAnalysis(ctx, args)
+	case ToolReorderSuggestions:
+		return h.callReorderSuggestions(ctx, args)
+	case ToolDetectAnomalies:
+		return h.callDetectAnomalies(ctx, args)
+	case ToolRFMSegments:
+		return h.callRFMSegments(ctx, args)
+	}
+	return nil, errUnknownTool
 }
 
 // auditToolCall пишет audit-запись по факту обработки tools/call.
@@ -307,190 +534,134 @@ type resolveArgs struct {
 	IncludeGroups flexBool `json:"include_groups"`
 }
 
-func (h *Handler) callResolveCustomer(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveCustomer(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveCustomer(r.Context(), a.Query, limit, bool(a.IncludeGroups))
+	resp, err := h.onecClient.ResolveCustomer(ctx, a.Query, limit, bool(a.IncludeGroups))
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveWarehouse(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveWarehouse(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveWarehouse(r.Context(), a.Query, limit)
+	resp, err := h.onecClient.ResolveWarehouse(ctx, a.Query, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveProduct(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveProduct(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
-
-	resp, err := h.onecClient.ResolveProduct(r.Context(), a.Query, limit, bool(a.IncludeGroups))
-	if err != nil {
-		return nil, err
-	}
+	limit := h.clampLimit(ctx, a.Limit)
 
-	data, err := json.Marshal(resp)
+	resp, err := h.onecClient.ResolveProduct(ctx, a.Query, limit, bool(a.IncludeGroups))
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 // callResolveMaterial — сырьё и комплектующие. Отдельный вызов, а не флаг в resolve_product:
 // наборы не пересекаются (пометка ДляПроизводства), и право у них разное.
-func (h *Handler) callResolveMaterial(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveMaterial(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
-
-	resp, err := h.onecClient.ResolveMaterial(r.Context(), a.Query, limit, bool(a.IncludeGroups))
-	if err != nil {
-		return nil, err
-	}
+	limit := h.clampLimit(ctx, a.Limit)
 
-	data, err := json.Marshal(resp)
+	resp, err := h.onecClient.ResolveMaterial(ctx, a.Query, limit, bool(a.IncludeGroups))
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveSalesChannel(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveSalesChannel(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveSalesChannel(r.Context(), a.Query, limit)
+	resp, err := h.onecClient.ResolveSalesChannel(ctx, a.Query, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveCash(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveCash(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveCash(r.Context(), a.Query, limit)
+	resp, err := h.onecClient.ResolveCash(ctx, a.Query, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveCostArticle(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveCostArticle(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveCostArticle(r.Context(), a.Query, limit, bool(a.IncludeGroups))
+	resp, err := h.onecClient.ResolveCostArticle(ctx, a.Query, limit, bool(a.IncludeGroups))
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callResolveOperation(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callResolveOperation(ctx context.Context, args any) (*CallToolResult, error) {
 	var a resolveArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	limit := h.clampLimit(a.Limit)
+	limit := h.clampLimit(ctx, a.Limit)
 
-	resp, err := h.onecClient.ResolveOperation(r.Context(), a.Query, limit)
+	resp, err := h.onecClient.ResolveOperation(ctx, a.Query, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type cashBalanceArgs struct {
@@ -502,13 +673,13 @@ type cashBalanceArgs struct {
 	Sort     []onec.SortSpec         `json:"sort"`
 }
 
-func (h *Handler) callCashBalance(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callCashBalance(ctx context.Context, args any) (*CallToolResult, error) {
 	var a cashBalanceArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.CashBalanceRequest{
 		Date:     a.Date,
@@ -519,19 +690,12 @@ func (h *Handler) callCashBalance(r *http.Request, args any) (*CallToolResult, e
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.CashBalance(r.Context(), req)
+	resp, err := h.onecClient.CashBalance(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type cashFlowArgs struct {
@@ -543,13 +707,13 @@ type cashFlowArgs struct {
 	Sort     []onec.SortSpec  `json:"sort"`
 }
 
-func (h *Handler) callCashFlow(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callCashFlow(ctx context.Context, args any) (*CallToolResult, error) {
 	var a cashFlowArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.CashFlowRequest{
 		Period:   a.Period,
@@ -560,19 +724,12 @@ func (h *Handler) callCashFlow(r *http.Request, args any) (*CallToolResult, erro
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.CashFlow(r.Context(), req)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := json.Marshal(resp)
+	resp, err := h.onecClient.CashFlow(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 // Аргументы ДЗ и КЗ различаются только именем ключа контрагента (customer_ids / supplier_ids):
@@ -595,13 +752,13 @@ type payablesArgs struct {
 	Sort     []onec.SortSpec      `json:"sort"`
 }
 
-func (h *Handler) callReceivablesBalance(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callReceivablesBalance(ctx context.Context, args any) (*CallToolResult, error) {
 	var a receivablesArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.ReceivablesRequest{
 		Date:     a.Date,
@@ -612,28 +769,21 @@ func (h *Handler) callReceivablesBalance(r *http.Request, args any) (*CallToolRe
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.Receivables(r.Context(), req)
+	resp, err := h.onecClient.Receivables(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
-func (h *Handler) callPayablesBalance(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callPayablesBalance(ctx context.Context, args any) (*CallToolResult, error) {
 	var a payablesArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.PayablesRequest{
 		Date:     a.Date,
@@ -644,19 +794,12 @@ func (h *Handler) callPayablesBalance(r *http.Request, args any) (*CallToolResul
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.Payables(r.Context(), req)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := json.Marshal(resp)
+	resp, err := h.onecClient.Payables(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type purchasesArgs struct {
@@ -670,13 +813,13 @@ type purchasesArgs struct {
 	Sort      []onec.SortSpec `json:"sort"`
 }
 
-func (h *Handler) callPurchasesReport(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callPurchasesReport(ctx context.Context, args any) (*CallToolResult, error) {
 	var a purchasesArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.PurchasesRequest{
 		Period:    a.Period,
@@ -688,19 +831,12 @@ func (h *Handler) callPurchasesReport(r *http.Request, args any) (*CallToolResul
 		Sort:      a.Sort,
 	}
 
-	resp, err := h.onecClient.Purchases(r.Context(), req)
+	resp, err := h.onecClient.Purchases(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type goodsInTransitArgs struct {
@@ -714,7 +850,7 @@ type goodsInTransitArgs struct {
 
 // callGoodsInTransit — остатки товаров в пути. Ответ 1С пробрасывается сырым (как у
 // availability_report): состав колонок задаёт 1С.
-func (h *Handler) callGoodsInTransit(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callGoodsInTransit(ctx context.Context, args any) (*CallToolResult, error) {
 	var a goodsInTransitArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -725,18 +861,16 @@ func (h *Handler) callGoodsInTransit(r *http.Request, args any) (*CallToolResult
 		Filters:  a.Filters,
 		GroupBy:  a.GroupBy,
 		Measures: a.Measures,
-		Top:      h.clampTop(a.Top),
+		Top:      h.clampTop(ctx, a.Top),
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.GoodsInTransit(r.Context(), req)
+	resp, err := h.onecClient.GoodsInTransit(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type salesReportArgs struct {
@@ -748,13 +882,13 @@ type salesReportArgs struct {
 	Sort     []onec.SortSpec   `json:"sort"`
 }
 
-func (h *Handler) callSalesReport(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callSalesReport(ctx context.Context, args any) (*CallToolResult, error) {
 	var a salesReportArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.SalesReportRequest{
 		Period:   a.Period,
@@ -765,19 +899,12 @@ func (h *Handler) callSalesReport(r *http.Request, args any) (*CallToolResult, e
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.SalesReport(r.Context(), req)
+	resp, err := h.onecClient.SalesReport(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type stockReportArgs struct {
@@ -789,13 +916,13 @@ type stockReportArgs struct {
 	Sort     []onec.SortSpec   `json:"sort"`
 }
 
-func (h *Handler) callStockBalance(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callStockBalance(ctx context.Context, args any) (*CallToolResult, error) {
 	var a stockReportArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.StockReportRequest{
 		Date:     a.Date,
@@ -806,19 +933,12 @@ func (h *Handler) callStockBalance(r *http.Request, args any) (*CallToolResult,
 		Sort:     a.Sort,
 	}
 
-	resp, err := h.onecClient.StockReport(r.Context(), req)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := json.Marshal(resp)
+	resp, err := h.onecClient.StockReport(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(data))},
-	}, nil
+	return toolResult(resp)
 }
 
 type availabilityReportArgs struct {
@@ -830,13 +950,13 @@ type availabilityReportArgs struct {
 	Sort     []onec.SortSpec          `json:"sort"`
 }
 
-func (h *Handler) callAvailabilityReport(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callAvailabilityReport(ctx context.Context, args any) (*CallToolResult, error) {
 	var a availabilityReportArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTop(a.Top)
+	top := h.clampTop(ctx, a.Top)
 
 	req := &onec.AvailabilityReportRequest{
 		Period:   a.Period,
@@ -848,14 +968,12 @@ func (h *Handler) callAvailabilityReport(r *http.Request, args any) (*CallToolRe
 	}
 
 	// ответ 1С проброс as-is (RawMessage): помимо columns/rows/totals содержит period/days/applied_filters
-	resp, err := h.onecClient.AvailabilityReport(r.Context(), req)
+	resp, err := h.onecClient.AvailabilityReport(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type productDetailsArgs struct {
@@ -863,7 +981,7 @@ type productDetailsArgs struct {
 	Fields     []string `json:"fields"`
 }
 
-func (h *Handler) callProductDetails(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callProductDetails(ctx context.Context, args any) (*CallToolResult, error) {
 	var a productDetailsArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -874,14 +992,12 @@ func (h *Handler) callProductDetails(r *http.Request, args any) (*CallToolResult
 		Fields:     a.Fields,
 	}
 
-	resp, err := h.onecClient.ProductDetails(r.Context(), req)
+	resp, err := h.onecClient.ProductDetails(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type topProductsArgs struct {
@@ -891,13 +1007,13 @@ type topProductsArgs struct {
 	Top     flexInt                 `json:"top"`
 }
 
-func (h *Handler) callTopProducts(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callTopProducts(ctx context.Context, args any) (*CallToolResult, error) {
 	var a topProductsArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
 	}
 
-	top := h.clampTopDefault(a.Top, 10)
+	top := h.clampTopDefault(ctx, "top", a.Top, 10)
 
 	req := &onec.TopProductsRequest{
 		Period:  a.Period,
@@ -906,14 +1022,12 @@ func (h *Handler) callTopProducts(r *http.Request, args any) (*CallToolResult, e
 		Top:     top,
 	}
 
-	resp, err := h.onecClient.TopProducts(r.Context(), req)
+	resp, err := h.onecClient.TopProducts(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type customerSummaryArgs struct {
@@ -922,7 +1036,7 @@ type customerSummaryArgs struct {
 	TopProducts flexInt     `json:"top_products"`
 }
 
-func (h *Handler) callCustomerSummary(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callCustomerSummary(ctx context.Context, args any) (*CallToolResult, error) {
 	var a customerSummaryArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -933,17 +1047,15 @@ func (h *Handler) callCustomerSummary(r *http.Request, args any) (*CallToolResul
 	req := &onec.CustomerSummaryRequest{
 		CustomerID:  a.CustomerID,
 		Period:      a.Period,
-		TopProducts: h.clampTopDefault(a.TopProducts, 5),
+		TopProducts: h.clampTopDefault(ctx, "top_products", a.TopProducts, 5),
 	}
 
-	resp, err := h.onecClient.CustomerSummary(r.Context(), req)
+	resp, err := h.onecClient.CustomerSummary(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 // callEventLog обслуживает event_log и object_history — оба ходят в один админ-эндпоинт
@@ -951,26 +1063,22 @@ func (h *Handler) callCustomerSummary(r *http.Request, args any) (*CallToolResul
 // (1С сама разбирает user/session/level/events/object_type/object_id/period/limit),
 // но через unstringifyJSON: админские инструменты шли мимо него, и двойное кодирование period —
 // то самое, ради которого он написан, — ломало ровно эти три вызова, а не остальные.
-func (h *Handler) callEventLog(r *http.Request, args any) (*CallToolResult, error) {
-	resp, err := h.onecClient.EventLog(r.Context(), unstringifyJSON(args))
+func (h *Handler) callEventLog(ctx context.Context, args any) (*CallToolResult, error) {
+	resp, err := h.onecClient.EventLog(ctx, unstringifyJSON(args))
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
-func (h *Handler) callFindDocument(r *http.Request, args any) (*CallToolResult, error) {
-	resp, err := h.onecClient.FindDocument(r.Context(), unstringifyJSON(args))
+func (h *Handler) callFindDocument(ctx context.Context, args any) (*CallToolResult, error) {
+	resp, err := h.onecClient.FindDocument(ctx, unstringifyJSON(args))
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 // specificationArgs — аргументы шести инструментов по спецификациям. Структура одна на всех:
@@ -1000,7 +1108,7 @@ type specificationArgs struct {
 // specification_list — все шесть ходят в /mcp/reports/{reportType} с одним телом.
 // Ответ 1С пробрасывается as-is (RawMessage): у инструментов разный конверт
 // (таблица columns/rows против дерева версий), и типизировать его в гейте нечего.
-func (h *Handler) callSpecification(r *http.Request, reportType string, args any) (*CallToolResult, error) {
+func (h *Handler) callSpecification(ctx context.Context, reportType string, args any) (*CallToolResult, error) {
 	var a specificationArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -1020,7 +1128,7 @@ func (h *Handler) callSpecification(r *http.Request, reportType string, args any
 		MaxDepth:          clampDepth(a.MaxDepth),
 		WithCost:          bool(a.WithCost),
 		MissingOnly:       bool(a.MissingOnly),
-		Limit:             h.clampRows(a.Limit),
+		Limit:             h.clampRows(ctx, a.Limit),
 	}
 
 	// период нужен только истории версий и режиму missing_only; пустой объект наверх не шлём,
@@ -1030,14 +1138,12 @@ func (h *Handler) callSpecification(r *http.Request, reportType string, args any
 		req.Period = &period
 	}
 
-	resp, err := h.onecClient.ProductionReport(r.Context(), reportType, req)
+	resp, err := h.onecClient.ProductionReport(ctx, reportType, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type productionReportArgs struct {
@@ -1052,7 +1158,7 @@ type productionReportArgs struct {
 
 // callProductionReport обслуживает production_output и production_consumption: секция задана
 // эндпойнтом 1С, тело у них общее.
-func (h *Handler) callProductionReport(r *http.Request, reportType string, args any) (*CallToolResult, error) {
+func (h *Handler) callProductionReport(ctx context.Context, reportType string, args any) (*CallToolResult, error) {
 	var a productionReportArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -1064,25 +1170,23 @@ func (h *Handler) callProductionReport(r *http.Request, reportType string, args
 		Filters:       a.Filters,
 		GroupBy:       a.GroupBy,
 		Measures:      a.Measures,
-		Top:           h.clampTop(a.Top),
+		Top:           h.clampTop(ctx, a.Top),
 		Sort:          a.Sort,
 	}
 
-	resp, err := h.onecClient.ProductionReport(r.Context(), reportType, req)
+	resp, err := h.onecClient.ProductionReport(ctx, reportType, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 type productionDocumentArgs struct {
 	DocumentID string `json:"document_id"`
 }
 
-func (h *Handler) callProductionDocument(r *http.Request, args any) (*CallToolResult, error) {
+func (h *Handler) callProductionDocument(ctx context.Context, args any) (*CallToolResult, error) {
 	var a productionDocumentArgs
 	if err := mapToStruct(args, &a); err != nil {
 		return nil, err
@@ -1090,23 +1194,22 @@ func (h *Handler) callProductionDocument(r *http.Request, args any) (*CallToolRe
 
 	req := &onec.ProductionDocumentRequest{DocumentID: a.DocumentID}
 
-	resp, err := h.onecClient.ProductionReport(r.Context(), onec.ReportProductionDocument, req)
+	resp, err := h.onecClient.ProductionReport(ctx, onec.ReportProductionDocument, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CallToolResult{
-		Content: []ContentBlock{TextContent(string(resp))},
-	}, nil
+	return rawToolResult(resp), nil
 }
 
 // clampRows — необязательный limit инструментов по спецификациям: 0 означает «по умолчанию 1С»
 // и наверх не уходит вовсе, всё остальное режется общим потолком max_rows.
-func (h *Handler) clampRows(limit flexInt) int {
+func (h *Handler) clampRows(ctx context.Context, limit flexInt) int {
 	if limit <= 0 {
 		return 0
 	}
 	if int(limit) > h.cfg.Limits.MaxRows {
+		logClamp(ctx, "limit", int(limit), h.cfg.Limits.MaxRows, "max_rows")
 		return h.cfg.Limits.MaxRows
 	}
 	return int(limit)
@@ -1201,6 +1304,30 @@ func stripCostMeasures(tools []Tool) {
 	}
 }
 
+// toolResult — успешный ответ инструмента: JSON одновременно текстовым блоком (его читают LLM
+// и клиенты без structured output) и structuredContent (по нему клиент строит таблицу и берёт
+// значения, не разбирая строку). Содержимое одно и то же: расходиться им негде.
+func toolResult(v any) (*CallToolResult, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return rawToolResult(data), nil
+}
+
+// rawToolResult — то же для ответов 1С, пробрасываемых как есть. structuredContent по
+// спецификации обязан быть объектом: если 1С вдруг ответила массивом или скаляром, ответ
+// уходит только текстом.
+func rawToolResult(data json.RawMessage) *CallToolResult {
+	result := &CallToolResult{
+		Content: []ContentBlock{TextContent(string(data))},
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		result.StructuredContent = data
+	}
+	return result
+}
+
 func mapToStruct(m any, v any) error {
 	data, err := json.Marshal(unstringifyJSON(m))
 	if err != nil {
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log/slog"
@@ -21,6 +22,12 @@ type fake1C struct {
 	mu       sync.Mutex
 	requests []recorded
 	response string
+	// delay — искусственная задержка ответа: имитирует долгий отчёт 1С.
+	delay time.Duration
+	// status — HTTP-статус ответа; 0 — 200.
+	status int
+	// respond — ответ в зависимости от пути и тела запроса; nil — всегда response.
+	respond func(path string, body map[string]any) string
 }
 
 type recorded struct {
@@ -38,9 +45,23 @@ func (f *fake1C) handler() http.Handler {
 		f.mu.Lock()
 		f.requests = append(f.requests, recorded{path: r.URL.Path, body: body})
 		resp := f.response
+		delay := f.delay
+		status := f.status
+		respond := f.respond
 		f.mu.Unlock()
 
+		if respond != nil {
+			resp = respond(r.URL.Path, body)
+		}
+
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
+		if status != 0 {
+			w.WriteHeader(status)
+		}
 		if resp == "" {
 			resp = `{"columns":[],"rows":[],"totals":{}}`
 		}
@@ -66,6 +87,38 @@ func (f *fake1C) count() int {
 	return len(f.requests)
 }
 
+// reportJSON — ответ табличного отчёта 1С для fake1C: колонки в виде "имя:тип", строки
+// и, если нужны, итоги.
+func reportJSON(columns []string, rows [][]any, totals ...map[string]any) string {
+	cols := make([]map[string]any, len(columns))
+	for i, c := range columns {
+		name, typ, _ := strings.Cut(c, ":")
+		cols[i] = map[string]any{"name": name, "type": typ}
+	}
+	if rows == nil {
+		rows = [][]any{}
+	}
+	resp := map[string]any{"columns": cols, "rows": rows}
+	if len(totals) > 0 {
+		resp["totals"] = totals[0]
+	}
+	data, _ := json.Marshal(resp)
+	return string(data)
+}
+
+// refJSON — ячейка-ссылка ответа 1С.
+func refJSON(id, label string) map[string]any {
+	return map[string]any{"id": id, "label": label}
+}
+
+// pick — a или b по условию: заглушкам нужны разные значения для разных дат и строк.
+func pick(first bool, a, b float64) float64 {
+	if first {
+		return a
+	}
+	return b
+}
+
 // newTestHandler поднимает заглушку 1С и MCP-хендлер поверх неё. Аутентификация выключена
 // (bearerToken пустой), поэтому tools/call проходит без OAuth-контекста.
 func newTestHandler(t *testing.T) (*Handler, *fake1C) {
@@ -86,10 +139,11 @@ func newTestHandler(t *testing.T) (*Handler, *fake1C) {
 	cfg.Limits.MaxRows = 5000
 	cfg.Limits.ResolveLimit = 10
 
-	return NewHandler(client, cfg, "", slog.New(slog.DiscardHandler)), fake
+	return NewHandler("test", client, cfg, "", nil, slog.New(slog.DiscardHandler)), fake
 }
 
 // callTool прогоняет tools/call через ServeHTTP и возвращает распарсенный CallToolResult.
+// Запрос идёт от клиента новейшей ревизии — со всеми возможностями ответа (structuredContent).
 func callTool(t *testing.T, h *Handler, tool string, args map[string]any) CallToolResult {
 	t.Helper()
 
@@ -109,6 +163,7 @@ func callTool(t *testing.T, h *Handler, tool string, args map[string]any) CallTo
 	}
 
 	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader(body))
+	req.Header.Set(ProtocolVersionHeader, LatestProtocolVersion)
 	rec := httptest.NewRecorder()
 	h.ServeHTTP(rec, req)
 
@@ -350,7 +405,7 @@ func TestAdminCallsUseReportTimeout(t *testing.T) {
 	cfg.Limits.MaxRows = 5000
 	cfg.Limits.ResolveLimit = 10
 
-	h := NewHandler(client, cfg, "", slog.New(slog.DiscardHandler))
+	h := NewHandler("test", client, cfg, "", nil, slog.New(slog.DiscardHandler))
 
 	res := callTool(t, h, ToolEventLog, map[string]any{"limit": 10})
 
@@ -394,7 +449,7 @@ func TestClampLimits(t *testing.T) {
 			{0, 10}, {-5, 10}, {3, 3}, {10, 10}, {999, 10},
 		}
 		for _, tc := range cases {
-			if got := h.clampLimit(flexInt(tc.in)); got != tc.want {
+			if got := h.clampLimit(context.Background(), flexInt(tc.in)); got != tc.want {
 				t.Errorf("clampLimit(%d) = %d, want %d", tc.in, got, tc.want)
 			}
 		}
@@ -405,7 +460,7 @@ func TestClampLimits(t *testing.T) {
 			{0, 5000}, {-1, 5000}, {100, 100}, {5000, 5000}, {99999, 5000},
 		}
 		for _, tc := range cases {
-			if got := h.clampTop(flexInt(tc.in)); got != tc.want {
+			if got := h.clampTop(context.Background(), flexInt(tc.in)); got != tc.want {
 				t.Errorf("clampTop(%d) = %d, want %d", tc.in, got, tc.want)
 			}
 		}
@@ -414,14 +469,72 @@ func TestClampLimits(t *testing.T) {
 	// max_rows — потолок и для значения по умолчанию: иначе вызывающий с завышенным дефолтом
 	// протаскивал бы мимо лимита ровно то, ради чего лимит и заведён.
 	t.Run("default is clamped too", func(t *testing.T) {
-		if got := h.clampTopDefault(0, 10); got != 10 {
+		if got := h.clampTopDefault(context.Background(), "top", 0, 10); got != 10 {
 			t.Errorf("clampTopDefault(0, 10) = %d, want 10", got)
 		}
-		if got := h.clampTopDefault(0, 99999); got != 5000 {
+		if got := h.clampTopDefault(context.Background(), "top", 0, 99999); got != 5000 {
 			t.Errorf("clampTopDefault(0, 99999) = %d, want 5000 — дефолт выше max_rows не урезан", got)
 		}
-		if got := h.clampTopDefault(50, 99999); got != 50 {
+		if got := h.clampTopDefault(context.Background(), "top", 50, 99999); got != 50 {
 			t.Errorf("clampTopDefault(50, 99999) = %d, want 50", got)
 		}
 	})
 }
+
+// Ответ инструмента дублируется в structuredContent: тот же объект, что в тексте.
+func TestToolResultCarriesStructuredContent(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = `{"columns":[{"name":"qty","type":"number"}],"rows":[[3]],"totals":{"qty":3},"date":"2026-02-01"}`
+
+	res := callTool(t, h, ToolStockBalance, map[string]any{})
+
+	structured, ok := res.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("structuredContent = %#v, want object", res.StructuredContent)
+	}
+	if structured["date"] != "2026-02-01" || len(structured["rows"].([]any)) != 1 {
+		t.Errorf("structuredContent: %v", structured)
+	}
+
+	var text map[string]any
+	if err := json.Unmarshal([]byte(resultText(t, res)), &text); err != nil {
+		t.Fatalf("text block is no longer JSON: %v", err)
+	}
+
+	// Ответ 1С, пробрасываемый как есть, тоже уходит объектом — но только если он объект.
+	fake.response = `{"products":[{"id":"p1"}]}`
+	if res := callTool(t, h, ToolProductDetails, map[string]any{"product_ids": []string{"p1"}}); res.StructuredContent == nil {
+		t.Error("passthrough response lost structuredContent")
+	}
+	fake.response = `[1,2]`
+	if res := callTool(t, h, ToolProductDetails, map[string]any{"product_ids": []string{"p1"}}); res.StructuredContent != nil {
+		t.Errorf("non-object response must not become structuredContent: %v", res.StructuredContent)
+	}
+}
+
+func TestEveryToolDeclaresOutputSchema(t *testing.T) {
+	for _, tool := range GetTools() {
+		schema, ok := tool.OutputSchema.(map[string]any)
+		if !ok || schema["type"] != "object" {
+			t.Errorf("%s: outputSchema must be an object schema, got %#v", tool.Name, tool.OutputSchema)
+		}
+	}
+}
+
+func TestEveryToolIsAnnotated(t *testing.T) {
+	for _, tool := range GetTools() {
+		if tool.Title == "" {
+			t.Errorf("%s: no title", tool.Name)
+		}
+		a := tool.Annotations
+		if a == nil || !a.ReadOnlyHint || !a.IdempotentHint || a.OpenWorldHint {
+			t.Errorf("%s: annotations %+v", tool.Name, a)
+			continue
+		}
+
+		pii := tool.Name == ToolEventLog || tool.Name == ToolObjectHistory
+		if pii != (a.SensitivityHint == "pii") {
+			t.Errorf("%s: sensitivityHint %q", tool.Name, a.SensitivityHint)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressInterval — период heartbeat'ов notifications/progress. Реальной доли выполненного
+// 1С не сообщает, поэтому progress — секунды ожидания: значение растёт, как того требует
+// спецификация, а клиент видит, что запрос жив и сколько он уже идёт.
+const progressInterval = 5 * time.Second
+
+// notifyFunc доставляет серверное уведомление клиенту. Куда именно — решает транспорт:
+// в SSE-поток ответа на POST, в GET-поток сессии или в stdout stdio-режима.
+type notifyFunc func(method string, params any)
+
+// callContext — то, что транспорт знает о запросе и чего нет в самом JSON-RPC сообщении.
+//
+//	scope  — область уникальности id запросов: клиент нумерует запросы в пределах своей сессии,
+//	         поэтому notifications/cancelled с id=5 отменяет пятый запрос ЭТОЙ сессии, а не чужой;
+//	         пусто — клиента не отличить от других, и его запросы не отменяются;
+//	notify   — канал уведомлений, относящихся к запросу (progress); nil — слать некуда;
+//	protocol — согласованная ревизия MCP (см. protocolRevisions); пусто — неизвестна;
+//	logLevel — порог notifications/message подключения; nil — вне сессии, порог по умолчанию.
+type callContext struct {
+	scope    string
+	notify   notifyFunc
+	protocol string
+	logLevel *clientLogLevel
+}
+
+type callContextKey struct{}
+
+func withCallContext(ctx context.Context, cc callContext) context.Context {
+	return context.WithValue(ctx, callContextKey{}, cc)
+}
+
+func callContextFrom(ctx context.Context) callContext {
+	cc, _ := ctx.Value(callContextKey{}).(callContext)
+	return cc
+}
+
+// withNotifier заменяет канал уведомлений, сохраняя scope: SSE-ответ на POST перехватывает
+// уведомления своего запроса у GET-потока сессии.
+func withNotifier(ctx context.Context, notify notifyFunc) context.Context {
+	cc := callContextFrom(ctx)
+	cc.notify = notify
+	return withCallContext(ctx, cc)
+}
+
+// inflight — выполняющиеся запросы с функциями их отмены. Живёт в Sessions, а не в Handler:
+// notifications/cancelled приходит отдельным POST'ом, и между запросом и его отменой
+// Registry.Reload может успеть пересобрать Handler.
+type inflight struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// start регистрирует запрос и возвращает его контекст. done снимает регистрацию и сообщает,
+// был ли запрос отменён клиентом (а не, скажем, отвалившимся соединением). Запрос без области
+// не регистрируется: отменить его нельзя.
+func (f *inflight) start(ctx context.Context, scope string, id any) (context.Context, func() (cancelled bool)) {
+	ctx, cancel := context.WithCancel(ctx)
+	if scope == "" {
+		return ctx, func() bool {
+			cancel()
+			return false
+		}
+	}
+	key := inflightKey(scope, id)
+	call := &inflightCall{cancel: cancel}
+
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*inflightCall)
+	}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	return ctx, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		// Клиент мог переиспользовать id, пока старый запрос ещё шёл: чужую запись не трогаем.
+		if f.calls[key] == call {
+			delete(f.calls, key)
+		}
+		cancel()
+		return call.cancelled
+	}
+}
+
+// cancel отменяет запрос id в области scope. false — такого запроса нет (уже завершён или
+// не существовал): по спецификации это не ошибка, уведомление просто игнорируется.
+func (f *inflight) cancel(scope string, id any) bool {
+	if scope == "" {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call, ok := f.calls[inflightKey(scope, id)]
+	if !ok {
+		return false
+	}
+	call.cancelled = true
+	call.cancel()
+	return true
+}
+
+// inflightKey — id в JSON-представлении: 5 и "5" — разные запросы.
+func inflightKey(scope string, id any) string {
+	data, _ := json.Marshal(id)
+	return scope + "\x00" + string(data)
+}
+
+// tracksProgress — инструменты, для которых имеет смысл heartbeat: всё, что идёт в 1С через
+// отчётный клиент с длинным таймаутом (отчёты, журнал регистрации, производство). Резолвы
+// отвечают за доли секунды на коротком таймауте — heartbeat там был бы шумом.
+func tracksProgress(tool string) bool {
+	return !strings.HasPrefix(tool, "resolve_")
+}
+
+// startProgress шлёт notifications/progress по token каждые every, пока не вызвана остановка.
+// Остановка синхронная: после неё ни одного уведомления по этому запросу не уйдёт — иначе
+// heartbeat мог бы обогнать в потоке сам ответ.
+func startProgress(ctx context.Context, notify notifyFunc, token any, every time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	started := time.Now()
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				elapsed := time.Since(started).Round(time.Second)
+				notify("notifications/progress", ProgressParams{
+					ProgressToken: token,
+					Progress:      elapsed.Seconds(),
+					Message:       "waiting for 1C: " + elapsed.String(),
+				})
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifications/cancelled обрывает выполняющийся вызов сессии: запрос в 1С отменяется, ответа нет.
+func TestCancelledNotificationAbortsCall(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.delay = time.Second
+	session := map[string]string{SessionHeader: initialize(t, h, context.Background(), ProtocolVersionStreamable).Header().Get(SessionHeader)}
+
+	type outcome struct {
+		code int
+		body string
+	}
+	finished := make(chan outcome, 1)
+	started := time.Now()
+	go func() {
+		rec := postRPC(t, h, context.Background(), map[string]any{
+			"jsonrpc": "2.0",
+			"id":      9,
+			"method":  "tools/call",
+			"params":  map[string]any{"name": ToolStockBalance, "arguments": map[string]any{}},
+		}, session)
+		finished <- outcome{rec.Code, rec.Body.String()}
+	}()
+
+	// Ждём, пока вызов дойдёт до 1С: только тогда он зарегистрирован как выполняющийся.
+	for fake.count() == 0 {
+		if time.Since(started) > 2*time.Second {
+			t.Fatal("call never reached 1C")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rec := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "notifications/cancelled",
+		"params":  map[string]any{"requestId": 9, "reason": "user abort"},
+	}, session)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("cancel notification: HTTP %d", rec.Code)
+	}
+
+	got := <-finished
+	if elapsed := time.Since(started); elapsed >= 900*time.Millisecond {
+		t.Errorf("cancelled call still waited for 1C: %v", elapsed)
+	}
+	if got.code != http.StatusAccepted || got.body != "" {
+		t.Errorf("cancelled call answered: HTTP %d %q", got.code, got.body)
+	}
+}
+
+// Отмена действует только в своей области: чужая сессия не отменит запрос с тем же id.
+func TestCancelIsScoped(t *testing.T) {
+	var calls inflight
+
+	ctx, done := calls.start(context.Background(), "session:a", float64(1))
+	if calls.cancel("session:b", float64(1)) {
+		t.Error("cancelled a request of another session")
+	}
+	if calls.cancel("session:a", "1") {
+		t.Error("string id \"1\" must not match numeric id 1")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("context cancelled by a foreign notification")
+	}
+
+	if !calls.cancel("session:a", float64(1)) || ctx.Err() == nil {
+		t.Error("own cancel did not reach the context")
+	}
+	if !done() {
+		t.Error("done must report client cancellation")
+	}
+	if calls.cancel("session:a", float64(1)) {
+		t.Error("finished request is still registered")
+	}
+
+	// Без области (клиент вне сессии и без пользователя) запрос не отменить никому.
+	ctx, done = calls.start(context.Background(), "", float64(2))
+	if calls.cancel("", float64(2)) || ctx.Err() != nil {
+		t.Error("request without a scope was cancelled")
+	}
+	if done() {
+		t.Error("done reported a cancellation that never happened")
+	}
+}
+
+// С progressToken отчёт присылает heartbeat'ы в SSE-поток ответа, и все они идут до ответа.
+func TestProgressHeartbeats(t *testing.T) {
+	h, fake := newTestHandler(t)
+	h.progressEvery = 20 * time.Millisecond
+	fake.delay = 150 * time.Millisecond
+
+	call := func(tool string) []string {
+		rec := postRPC(t, h, context.Background(), map[string]any{
+			"jsonrpc": "2.0",
+			"id":      3,
+			"method":  "tools/call",
+			"params": map[string]any{
+				"name":      tool,
+				"arguments": map[string]any{"query": "x"},
+				"_meta":     map[string]any{"progressToken": "tok"},
+			},
+		}, map[string]string{"Accept": "application/json, text/event-stream"})
+
+		var events []string
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				events = append(events, data)
+			}
+		}
+		return events
+	}
+
+	events := call(ToolStockBalance)
+	if len(events) < 3 {
+		t.Fatalf("want progress events and a response, got %v", events)
+	}
+	for _, data := range events[:len(events)-1] {
+		var n Notification
+		if err := json.Unmarshal([]byte(data), &n); err != nil || n.Method != "notifications/progress" {
+			t.Errorf("unexpected event before the response: %s", data)
+		}
+		if params, _ := n.Params.(map[string]any); params["progressToken"] != "tok" {
+			t.Errorf("progress without token: %s", data)
+		}
+	}
+	if !strings.Contains(events[len(events)-1], `"result"`) {
+		t.Errorf("last event must be the response: %s", events[len(events)-1])
+	}
+
+	fake.response = `{"candidates":[]}`
+	if events := call(ToolResolveWarehouse); len(events) != 1 {
+		t.Errorf("resolvers send no heartbeats, got %v", events)
+	}
+}
@@ -12,6 +12,12 @@ const (
 	CodeInvalidParams  = -32602
 	CodeInternalError  = -32603
 	CodeUnauthorized   = -32000
+	// CodeSessionNotFound — Mcp-Session-Id неизвестен, протух или принадлежит другому
+	// пользователю/базе. Уходит вместе с HTTP 404: по нему клиент понимает, что нужен initialize.
+	CodeSessionNotFound = -32001
+	// CodeResourceNotFound — resources/read по URI, которого нет (или который вызывающему
+	// не положен по правам — эти случаи намеренно неотличимы). Код из спецификации MCP.
+	CodeResourceNotFound = -32002
 )
 
 type Request struct {
@@ -28,6 +34,14 @@ type Response struct {
 	ID      any    `json:"id,omitempty"`
 }
 
+// Notification — сообщение без id (JSON-RPC 2.0 §4.1): ответа на него не бывает.
+// Сервер шлёт их клиенту по SSE — в GET-поток сессии или в поток ответа на POST.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -42,6 +56,14 @@ func NewResponse(id any, result any) *Response {
 	}
 }
 
+func NewNotification(method string, params any) *Notification {
+	return &Notification{
+		JSONRPC: JSONRPCVersion,
+		Method:  method,
+		Params:  params,
+	}
+}
+
 func NewErrorResponse(id any, code int, message string, data any) *Response {
 	return &Response{
 		JSONRPC: JSONRPCVersion,
@@ -77,3 +99,11 @@ func InternalError(id any, details string) *Response {
 func Unauthorized(id any) *Response {
 	return NewErrorResponse(id, CodeUnauthorized, "Unauthorized", nil)
 }
+
+func SessionNotFound(id any) *Response {
+	return NewErrorResponse(id, CodeSessionNotFound, "Session not found", nil)
+}
+
+func ResourceNotFound(id any, uri string) *Response {
+	return NewErrorResponse(id, CodeResourceNotFound, "Resource not found", uri)
+}
@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// Уровни notifications/message — syslog (RFC 5424), как их перечисляет спецификация MCP.
+const (
+	LogDebug     = "debug"
+	LogInfo      = "info"
+	LogNotice    = "notice"
+	LogWarning   = "warning"
+	LogError     = "error"
+	LogCritical  = "critical"
+	LogAlert     = "alert"
+	LogEmergency = "emergency"
+)
+
+// logSeverity — порядок уровней: сообщение уходит клиенту, если его уровень не ниже заданного.
+var logSeverity = map[string]int{
+	LogDebug:     0,
+	LogInfo:      1,
+	LogNotice:    2,
+	LogWarning:   3,
+	LogError:     4,
+	LogCritical:  5,
+	LogAlert:     6,
+	LogEmergency: 7,
+}
+
+// defaultClientLogLevel — порог до logging/setLevel. Спецификация оставляет его на усмотрение
+// сервера; warning пропускает то, что меняет смысл ответа (урезанный top, ошибка 1С), и
+// не засыпает клиента отладкой вроде попаданий в кэш.
+const defaultClientLogLevel = LogWarning
+
+// Имена источников (поле logger) в notifications/message.
+const (
+	loggerOneC   = "1c"
+	loggerCache  = "cache"
+	loggerLimits = "limits"
+)
+
+// clientLogLevel — порог notifications/message одного подключения: сессии Streamable HTTP или
+// stdio-процесса. Пустое значение — defaultClientLogLevel.
+type clientLogLevel struct {
+	mu    sync.Mutex
+	level string
+}
+
+func (l *clientLogLevel) get() string {
+	if l == nil {
+		return defaultClientLogLevel
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.level == "" {
+		return defaultClientLogLevel
+	}
+	return l.level
+}
+
+func (l *clientLogLevel) set(level string) {
+	l.mu.Lock()
+	l.level = level
+	l.mu.Unlock()
+}
+
+// handleSetLevel — logging/setLevel. Уровень запоминается за подключением, поэтому вне сессии
+// (HTTP без Mcp-Session-Id) он принимается, но действует только на этот запрос — то есть ни на что:
+// уведомлений вне сессии слать всё равно некуда.
+func (h *Handler) handleSetLevel(ctx context.Context, req Request) *Response {
+	var params SetLevelParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return InvalidParams(req.ID, "failed to parse params")
+	}
+	if _, ok := logSeverity[params.Level]; !ok {
+		return InvalidParams(req.ID, "unknown log level: "+params.Level)
+	}
+
+	if l := callContextFrom(ctx).logLevel; l != nil {
+		l.set(params.Level)
+	}
+	return NewResponse(req.ID, struct{}{})
+}
+
+// clientLog отправляет клиенту notifications/message, если у запроса есть канал уведомлений
+// (SSE-ответ, GET-поток сессии, stdout stdio) и уровень не ниже порога подключения. В обычный
+// JSON-ответ такие сообщения не попадают: вложить их в результат спецификация не позволяет.
+func clientLog(ctx context.Context, level, logger string, data any) {
+	cc := callContextFrom(ctx)
+	if cc.notify == nil {
+		return
+	}
+	if logSeverity[level] < logSeverity[cc.logLevel.get()] {
+		return
+	}
+	cc.notify("notifications/message", LoggingMessageParams{
+		Level:  level,
+		Logger: logger,
+		Data:   data,
+	})
+}
+
+// logClamp сообщает, что запрошенное значение аргумента урезано лимитом гейта: иначе модель
+// не узнает, что отчёт вернул меньше строк, чем она просила, не потому что их меньше.
+func logClamp(ctx context.Context, argument string, requested, applied int, limit string) {
+	clientLog(ctx, LogWarning, loggerLimits, map[string]any{
+		"message":   argument + " exceeds " + limit + " and was clamped",
+		"argument":  argument,
+		"requested": requested,
+		"applied":   applied,
+		"limit":     limit,
+	})
+}
+
+// logToolError пересылает клиенту подробности структурированной ошибки 1С (код, HTTP-статус):
+// в результат инструмента попадает только текст err.Error().
+func logToolError(ctx context.Context, tool string, err error) {
+	var apiErr *onec.APIError
+	if !errors.As(err, &apiErr) {
+		return
+	}
+	clientLog(ctx, LogError, loggerOneC, map[string]any{
+		"message": apiErr.Message,
+		"tool":    tool,
+		"status":  apiErr.StatusCode,
+		"error":   apiErr.Code,
+	})
+}
+
+// withCacheLog подключает к ctx наблюдателя кэша резолвов onec: попадание — debug-сообщение
+// клиенту (ответ мог устареть на величину TTL кэша).
+func withCacheLog(ctx context.Context, tool string) context.Context {
+	return onec.WithCacheHitHook(ctx, func(entity, query string) {
+		clientLog(ctx, LogDebug, loggerCache, map[string]any{
+			"message": "served from resolve cache",
+			"tool":    tool,
+			"entity":  entity,
+			"query":   query,
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// sseMessages — notifications/message из SSE-ответа на tools/call в сессии sid.
+func sseMessages(t *testing.T, h *Handler, sid, tool string, args map[string]any) []LoggingMessageParams {
+	t.Helper()
+
+	rec := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0",
+		"id":      7,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": tool, "arguments": args},
+	}, map[string]string{"Accept": "application/json, text/event-stream", SessionHeader: sid})
+
+	var out []LoggingMessageParams
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var n struct {
+			Method string               `json:"method"`
+			Params LoggingMessageParams `json:"params"`
+		}
+		if json.Unmarshal([]byte(data), &n) == nil && n.Method == "notifications/message" {
+			out = append(out, n.Params)
+		}
+	}
+	return out
+}
+
+func setLevel(t *testing.T, h *Handler, sid, level string) rpcResult {
+	t.Helper()
+
+	rec := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0", "id": 5, "method": "logging/setLevel",
+		"params": map[string]any{"level": level},
+	}, map[string]string{SessionHeader: sid})
+
+	var res rpcResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unmarshal %s: %v", rec.Body.String(), err)
+	}
+	return res
+}
+
+// Урезанный top и ошибка 1С видны клиенту на уровне по умолчанию, попадание в кэш — только
+// после logging/setLevel debug.
+func TestClientLogMessages(t *testing.T) {
+	h, fake := newTestHandler(t)
+	sid := initialize(t, h, context.Background(), LatestProtocolVersion).Header().Get(SessionHeader)
+
+	msgs := sseMessages(t, h, sid, ToolStockBalance, map[string]any{"top": 99999})
+	if len(msgs) != 1 || msgs[0].Level != LogWarning || msgs[0].Logger != loggerLimits {
+		t.Fatalf("clamp messages = %+v", msgs)
+	}
+	if data, _ := msgs[0].Data.(map[string]any); data["requested"] != float64(99999) || data["applied"] != float64(5000) {
+		t.Errorf("clamp data = %v", msgs[0].Data)
+	}
+
+	fake.response = `{"candidates":[]}`
+	sseMessages(t, h, sid, ToolResolveWarehouse, map[string]any{"query": "main"})
+	if msgs := sseMessages(t, h, sid, ToolResolveWarehouse, map[string]any{"query": "main"}); len(msgs) != 0 {
+		t.Errorf("cache hit reported above the default level: %+v", msgs)
+	}
+
+	if res := setLevel(t, h, sid, "verbose"); res.Error == nil || res.Error.Code != CodeInvalidParams {
+		t.Errorf("unknown level accepted: %+v", res)
+	}
+	if res := setLevel(t, h, sid, LogDebug); res.Error != nil {
+		t.Fatalf("setLevel debug: %+v", res.Error)
+	}
+	msgs = sseMessages(t, h, sid, ToolResolveWarehouse, map[string]any{"query": "main"})
+	if len(msgs) != 1 || msgs[0].Logger != loggerCache || msgs[0].Level != LogDebug {
+		t.Errorf("cache hit messages = %+v", msgs)
+	}
+
+	fake.status = http.StatusBadRequest
+	fake.response = `{"error":"bad_period","message":"period.from is after period.to"}`
+	msgs = sseMessages(t, h, sid, ToolStockBalance, map[string]any{})
+	if len(msgs) != 1 || msgs[0].Level != LogError || msgs[0].Logger != loggerOneC {
+		t.Fatalf("1C error messages = %+v", msgs)
+	}
+	if data, _ := msgs[0].Data.(map[string]any); data["error"] != "bad_period" || data["status"] != float64(400) {
+		t.Errorf("1C error data = %v", msgs[0].Data)
+	}
+}
@@ -0,0 +1,300 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// Фильтры по наименованиям. Отчёты принимают только UUID, и каждый вопрос стоил модели хотя бы
+// одного лишнего resolve_*. Теперь рядом с customer_ids можно передать customers: ["ТОВ Ромашка"]:
+// гейт сам вызывает тот же резолвер (с его кэшем), подставляет единственное совпадение в *_ids,
+// а на неоднозначное или пустое отвечает ошибкой аргумента со списком кандидатов.
+//
+// Имена резолвятся после проверки схемы и до похода в отчёт — так что в 1С и в хэш страницы
+// уходят уже UUID. Право на имена — право соответствующего resolve_*: имя, которое пользователь
+// не смог бы найти сам, гейт за него тоже не найдёт.
+
+// nameFilter — фильтр по наименованиям: ключ с UUID, который он заполняет, и резолвер.
+type nameFilter struct {
+	ids      string // ключ фильтра с UUID
+	noun     string // что ищется — для текста ошибки
+	resolver string // resolve_*-инструмент, чей scope нужен
+	resolve  func(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error)
+}
+
+// nameCandidate — кандидат резолвера в общем виде.
+type nameCandidate struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+// nameFilters — ключ с наименованиями → фильтр. Группы каталогов (контрагенты, товары, статьи
+// затрат) ищутся тоже: отчёты применяют эти фильтры IN HIERARCHY.
+var nameFilters = map[string]nameFilter{
+	"customers":      {"customer_ids", "customers", ToolResolveCustomer, resolveCustomerNames},
+	"suppliers":      {"supplier_ids", "suppliers", ToolResolveCustomer, resolveCustomerNames},
+	"warehouses":     {"warehouse_ids", "warehouses", ToolResolveWarehouse, resolveWarehouseNames},
+	"products":       {"product_ids", "products", ToolResolveProduct, resolveProductNames},
+	"sales_channels": {"sales_channel_ids", "sales channels", ToolResolveSalesChannel, resolveSalesChannelNames},
+	"cashes":         {"cash_ids", "cash desks", ToolResolveCash, resolveCashNames},
+	"cost_articles":  {"cost_article_ids", "cost articles", ToolResolveCostArticle, resolveCostArticleNames},
+	"operations":     {"operation_ids", "operations", ToolResolveOperation, resolveOperationNames},
+}
+
+// withNameFilters добавляет ключи с наименованиями в каждый filters, где есть парный *_ids.
+// Так схемы не дублируют восемь одинаковых описаний по двум десяткам инструментов.
+func withNameFilters(tools []Tool) []Tool {
+	for _, t := range tools {
+		schema, _ := t.InputSchema.(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		filters, _ := props["filters"].(map[string]any)
+		fprops, _ := filters["properties"].(map[string]any)
+		for key, f := range nameFilters {
+			if _, ok := fprops[f.ids]; !ok {
+				continue
+			}
+			fprops[key] = map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+				"description": fmt.Sprintf("Names instead of %s: the gateway resolves each one like %s and adds the id "+
+					"to %s. An exact or single match is used; otherwise the call fails with the candidates.",
+					f.ids, f.resolver, f.ids),
+			}
+		}
+	}
+	return tools
+}
+
+// resolveNameFilters заменяет наименования в filters (и в arguments.filters составных
+// инструментов) идентификаторами.
+func (h *Handler) resolveNameFilters(ctx context.Context, args any) (any, error) {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return args, nil
+	}
+
+	out := m
+	var (
+		errs     argumentErrors
+		firstErr error
+	)
+	replace := func(path string, filters map[string]any) map[string]any {
+		resolved, fieldErrs, err := h.resolveFilterNames(ctx, path, filters)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return filters
+		}
+		errs = append(errs, fieldErrs...)
+		return resolved
+	}
+
+	if filters, ok := m["filters"].(map[string]any); ok {
+		out = cloneArgs(out)
+		out["filters"] = replace("filters", filters)
+	}
+	if inner, ok := m["arguments"].(map[string]any); ok {
+		if filters, ok := inner["filters"].(map[string]any); ok {
+			inner = cloneArgs(inner)
+			inner["filters"] = replace("arguments.filters", filters)
+			out = cloneArgs(out)
+			out["arguments"] = inner
+		}
+	}
+
+	if firstErr != nil {
+		return args, firstErr
+	}
+	if len(errs) > 0 {
+		return args, errs
+	}
+	return out, nil
+}
+
+// resolveFilterNames — один объект filters. Ошибки по отдельным именам собираются все сразу,
+// чтобы модель исправила их одним повторным вызовом.
+func (h *Handler) resolveFilterNames(ctx context.Context, path string, filters map[string]any) (map[string]any, argumentErrors, error) {
+	keys := make([]string, 0, len(nameFilters))
+	for key := range nameFilters {
+		if _, ok := filters[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return filters, nil, nil
+	}
+	sort.Strings(keys)
+
+	out := cloneArgs(filters)
+	var errs argumentErrors
+	for _, key := range keys {
+		f := nameFilters[key]
+		names, _ := out[key].([]any)
+		delete(out, key)
+
+		if required, denied := missingScope(oauth.FromContext(ctx), f.resolver); denied {
+			return filters, nil, permissionError{fmt.Sprintf("permission denied: %s.%s requires scope %q (pass %s instead)", path, key, required, f.ids)}
+		}
+
+		ids, _ := out[f.ids].([]any)
+		for i, raw := range names {
+			name, _ := raw.(string)
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			fieldPath := fmt.Sprintf("%s.%s[%d]", path, key, i)
+
+			candidates, err := f.resolve(h, ctx, name, h.cfg.Limits.ResolveLimit)
+			if err != nil {
+				return filters, nil, err
+			}
+			match, ok := pickCandidate(name, candidates)
+			if !ok {
+				errs = append(errs, nameError(fieldPath, name, f, candidates))
+				continue
+			}
+			if !slices.Contains(ids, any(match.ID)) {
+				ids = append(ids, match.ID)
+			}
+		}
+		if len(ids) > 0 {
+			out[f.ids] = ids
+		}
+	}
+	return out, errs, nil
+}
+
+// pickCandidate — единственное совпадение: точное наименование (без учёта регистра), а если
+// точных нет — единственный кандидат. Два точных (тёзки в разных группах) — неоднозначность.
+func pickCandidate(name string, candidates []nameCandidate) (nameCandidate, bool) {
+	var exact []nameCandidate
+	for _, c := range candidates {
+		if strings.EqualFold(strings.TrimSpace(c.Label), name) {
+			exact = append(exact, c)
+		}
+	}
+	switch {
+	case len(exact) == 1:
+		return exact[0], true
+	case len(exact) == 0 && len(candidates) == 1:
+		return candidates[0], true
+	}
+	return nameCandidate{}, false
+}
+
+func nameError(path, name string, f nameFilter, candidates []nameCandidate) argumentError {
+	if len(candidates) == 0 {
+		return argumentError{Path: path, Message: fmt.Sprintf("no %s match %q; try %s with a shorter query", f.noun, name, f.resolver)}
+	}
+	allowed := make([]string, len(candidates))
+	for i, c := range candidates {
+		allowed[i] = fmt.Sprintf("%s (%s)", c.Label, c.ID)
+	}
+	return argumentError{
+		Path:       path,
+		Message:    fmt.Sprintf("%q matches %d %s; pass the id in %s or a more exact name", name, len(candidates), f.noun, f.ids),
+		Allowed:    allowed,
+		Candidates: candidates,
+	}
+}
+
+// cloneArgs — поверхностная копия: аргументы вызова не мутируются на месте.
+func cloneArgs(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func resolveCustomerNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveCustomer(ctx, name, limit, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveWarehouseNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveWarehouse(ctx, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveProductNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveProduct(ctx, name, limit, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveSalesChannelNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveSalesChannel(ctx, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveCashNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveCash(ctx, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveCostArticleNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveCostArticle(ctx, name, limit, true)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
+
+func resolveOperationNames(h *Handler, ctx context.Context, name string, limit int) ([]nameCandidate, error) {
+	resp, err := h.onecClient.ResolveOperation(ctx, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nameCandidate, len(resp.Candidates))
+	for i, c := range resp.Candidates {
+		out[i] = nameCandidate{c.ID, c.Label, c.Archived}
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// namesFake — резолвер складов с двумя «киевскими» и отчёт продаж.
+func namesFake(path string, body map[string]any) string {
+	if path != "/mcp/resolve/warehouse" {
+		return `{"columns":[{"name":"amount","type":"number"}],"rows":[]}`
+	}
+	switch body["query"] {
+	case "kyiv main", "Kyiv":
+		return `{"candidates":[{"id":"w-1","label":"Kyiv main"},{"id":"w-2","label":"Kyiv main 2"}]}`
+	case "Lviv":
+		return `{"candidates":[{"id":"w-3","label":"Lviv central"}]}`
+	}
+	return `{"candidates":[]}`
+}
+
+// Точное наименование и единственный кандидат подставляются в warehouse_ids; имён 1С не видит.
+func TestNameFiltersResolved(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = namesFake
+
+	res := callTool(t, h, ToolSalesReport, map[string]any{
+		"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"},
+		"filters": map[string]any{
+			"warehouses":    []any{"kyiv main", "Lviv"},
+			"warehouse_ids": []any{"w-9"},
+		},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	if fake.count() != 3 {
+		t.Fatalf("1C calls = %d, want 2 resolves + report", fake.count())
+	}
+	filters, _ := fake.recorded(t, 2).body["filters"].(map[string]any)
+	ids, _ := json.Marshal(filters["warehouse_ids"])
+	if string(ids) != `["w-9","w-1","w-3"]` {
+		t.Errorf("warehouse_ids = %s", ids)
+	}
+	if _, leaked := filters["warehouses"]; leaked {
+		t.Error("names reached 1C")
+	}
+}
+
+// Неоднозначное и пустое совпадение — -32602 со всеми кандидатами, до отчёта дело не доходит.
+func TestNameFiltersAmbiguous(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = namesFake
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name": ToolSalesReport,
+		"arguments": map[string]any{
+			"period":  map[string]any{"from": "2026-01-01", "to": "2026-01-31"},
+			"filters": map[string]any{"warehouses": []any{"Kyiv", "Odesa"}},
+		},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams {
+		t.Fatalf("error = %+v", res.Error)
+	}
+	if !strings.Contains(res.Error.Message, `filters.warehouses[0]: "Kyiv" matches 2 warehouses`) ||
+		!strings.Contains(res.Error.Message, `filters.warehouses[1]: no warehouses match "Odesa"`) {
+		t.Errorf("message = %s", res.Error.Message)
+	}
+
+	data, _ := json.Marshal(res.Error.Data)
+	var got struct {
+		Errors []argumentError `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil || len(got.Errors) != 2 || len(got.Errors[0].Candidates) != 2 {
+		t.Errorf("data = %s", data)
+	}
+
+	for i := range fake.count() {
+		if r := fake.recorded(t, i); r.path != "/mcp/resolve/warehouse" {
+			t.Errorf("unexpected 1C call %s", r.path)
+		}
+	}
+}
+
+// Имена — через право резолвера: без mcp:resolve остаются только UUID.
+func TestNameFiltersNeedResolveScope(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = namesFake
+
+	res := rpc(t, h, withScopes("mcp:report:sales"), "tools/call", map[string]any{
+		"name": ToolSalesReport,
+		"arguments": map[string]any{
+			"period":  map[string]any{"from": "2026-01-01", "to": "2026-01-31"},
+			"filters": map[string]any{"warehouses": []any{"Lviv"}},
+		},
+	})
+	if !strings.Contains(string(res.Result), `filters.warehouses requires scope \"mcp:resolve\"`) || fake.count() != 0 {
+		t.Errorf("result = %s, 1C calls = %d", res.Result, fake.count())
+	}
+}
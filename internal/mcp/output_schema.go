@@ -0,0 +1,227 @@
+package mcp
+
+// Схемы structuredContent (Tool.OutputSchema). Собираются функциями, а не общими переменными:
+// схемы — изменяемые map, и один общий экземпляр на несколько инструментов превратил бы
+// правку одной схемы (как stripCostMeasures для входных) в правку всех сразу.
+//
+// Точность схем разная по происхождению ответа. Resolve-инструменты и пять «типизированных»
+// отчётов декодируются гейтом в структуры onec.*, поэтому их форма гарантирована и поля
+// объявлены обязательными. Остальные ответы 1С пробрасываются как есть (json.RawMessage):
+// для них схема описывает известные поля, но ничего не требует — состав задаёт 1С, и новое поле
+// на её стороне не должно превращать ответ в «не соответствующий схеме».
+
+func stringProp() map[string]any { return map[string]any{"type": "string"} }
+
+func boolProp() map[string]any { return map[string]any{"type": "boolean"} }
+
+func objectProp() map[string]any { return map[string]any{"type": "object"} }
+
+func arrayOf(items map[string]any) map[string]any {
+	return map[string]any{"type": "array", "items": items}
+}
+
+// codeLabelProp — onec.CodeLabel: стабильный код плюс метка для показа.
+func codeLabelProp() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":  stringProp(),
+			"label": stringProp(),
+		},
+		"required": []string{"code", "label"},
+	}
+}
+
+// candidatesOutput — ответ resolve-инструмента {candidates:[...]}. У каждого кандидата есть
+// id, label и archived; extra — поля конкретного справочника.
+func candidatesOutput(extra map[string]any) map[string]any {
+	props := map[string]any{
+		"id":       stringProp(),
+		"label":    stringProp(),
+		"archived": boolProp(),
+	}
+	for k, v := range extra {
+		props[k] = v
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"candidates": arrayOf(map[string]any{
+				"type":       "object",
+				"properties": props,
+				"required":   []string{"id", "label"},
+			}),
+		},
+		"required": []string{"candidates"},
+	}
+}
+
+// reportOutput — табличный отчёт {columns, rows, totals} с эхом запроса (onec.ReportEcho).
+// Ячейки rows — скаляры или ссылки {id,label}, поэтому тип элементов строки не фиксируется.
+// total_rows, offset и next_cursor есть у ответа, порезанного на страницы (см. pages.go);
+// refs — словарь наименований ответа в формате compact (см. format.go); resolved_period — эхо
+// относительного периода (см. period.go); pivot — эхо сводной таблицы (см. pivot.go); derived —
+// эхо производных мер (см. derived.go).
+// typed — ответ декодирован гейтом и columns/rows в нём есть всегда.
+func reportOutput(typed bool) map[string]any {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"columns": arrayOf(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": stringProp(),
+					"type": stringProp(),
+				},
+				"required": []string{"name", "type"},
+			}),
+			"rows":   arrayOf(map[string]any{"type": "array"}),
+			"totals": objectProp(),
+			"period": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"from": stringProp(),
+					"to":   stringProp(),
+				},
+			},
+			"date":            stringProp(),
+			"role":            stringProp(),
+			"applied_filters": objectProp(),
+			"total_rows":      map[string]any{"type": "integer"},
+			"offset":          map[string]any{"type": "integer"},
+			"next_cursor":     stringProp(),
+			"refs":            objectProp(),
+			"resolved_period": resolvedPeriodProp(),
+			"pivot":           objectProp(),
+			"derived":         arrayOf(objectProp()),
+		},
+	}
+	if typed {
+		schema["required"] = []string{"columns", "rows"}
+	}
+	return schema
+}
+
+// compareOutput — ответ compare_periods: таблица со сведёнными мерами плюс оба периода и имя отчёта.
+func compareOutput() map[string]any {
+	schema := reportOutput(true)
+	props := schema["properties"].(map[string]any)
+	props["report"] = stringProp()
+	props["base_period"] = props["period"]
+	props["resolved_base_period"] = resolvedPeriodProp()
+	return schema
+}
+
+// abcXYZOutput — ответ abc_xyz_analysis: таблица классов плюс матрица summary и пороги.
+func abcXYZOutput() map[string]any {
+	schema := reportOutput(true)
+	props := schema["properties"].(map[string]any)
+	props["summary"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"columns": props["columns"],
+			"rows":    props["rows"],
+		},
+	}
+	props["months"] = map[string]any{"type": "integer"}
+	props["measure"] = stringProp()
+	props["level"] = stringProp()
+	props["abc_thresholds"] = objectProp()
+	props["xyz_thresholds"] = objectProp()
+	props["truncated"] = map[string]any{"type": "boolean"}
+	return schema
+}
+
+// reorderOutput — ответ reorder_suggestions: таблица рекомендаций плюс окно продаж и горизонт.
+func reorderOutput() map[string]any {
+	schema := reportOutput(true)
+	props := schema["properties"].(map[string]any)
+	props["as_of"] = stringProp()
+	props["lookback_days"] = map[string]any{"type": "integer"}
+	props["horizon_days"] = map[string]any{"type": "integer"}
+	props["horizon_end"] = stringProp()
+	props["truncated"] = map[string]any{"type": "boolean"}
+	return schema
+}
+
+// anomaliesOutput — ответ detect_anomalies: отмеченные дни плюс окно базы и проверяемые дни.
+func anomaliesOutput() map[string]any {
+	schema := reportOutput(true)
+	props := schema["properties"].(map[string]any)
+	props["report"] = stringProp()
+	props["measure"] = stringProp()
+	props["dimension"] = stringProp()
+	props["checked"] = props["period"]
+	props["lookback_days"] = map[string]any{"type": "integer"}
+	props["threshold"] = map[string]any{"type": "number"}
+	props["series"] = map[string]any{"type": "integer"}
+	props["truncated"] = map[string]any{"type": "boolean"}
+	return schema
+}
+
+// rfmOutput — ответ rfm_segments: клиенты с баллами плюс сводка по сегментам.
+func rfmOutput() map[string]any {
+	schema := reportOutput(true)
+	props := schema["properties"].(map[string]any)
+	props["summary"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"columns": props["columns"],
+			"rows":    props["rows"],
+		},
+	}
+	props["as_of"] = stringProp()
+	props["truncated"] = map[string]any{"type": "boolean"}
+	return schema
+}
+
+// resolvedPeriodProp — onec.ResolvedPeriod: выражение периода и даты, в которые его развернул гейт.
+func resolvedPeriodProp() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"expression": stringProp(),
+			"from":       stringProp(),
+			"to":         stringProp(),
+			"timezone":   stringProp(),
+		},
+		"required": []string{"expression", "from", "to"},
+	}
+}
+
+// cccOutput — ответ cash_conversion_cycle: три составляющие с числителем и знаменателем и сам цикл.
+// days и ccc — null, когда знаменатель нулевой.
+func cccOutput() map[string]any {
+	component := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"days": map[string]any{"type": []string{"number", "null"}},
+		},
+		"required": []string{"days"},
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"period":          map[string]any{"type": "object", "properties": map[string]any{"from": stringProp(), "to": stringProp()}},
+			"period_days":     map[string]any{"type": "integer"},
+			"dio":             component,
+			"dso":             component,
+			"dpo":             component,
+			"ccc":             map[string]any{"type": []string{"number", "null"}},
+			"applied_filters": objectProp(),
+			"resolved_period": resolvedPeriodProp(),
+		},
+		"required": []string{"dio", "dso", "dpo", "ccc"},
+	}
+}
+
+// passthroughOutput — нетабличный ответ 1С, пробрасываемый как есть: известные поля без
+// обязательных.
+func passthroughOutput(props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object"}
+	if len(props) > 0 {
+		schema["properties"] = props
+	}
+	return schema
+}
@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Постраничная выдача табличных отчётов. Отчёт ограничен top и limits.max_rows, но и в этих
+// пределах тысячи строк одним ответом модели не нужны: контекст забивается, а хвост всё равно
+// не читается. Гейт берёт у 1С весь результат (до max_rows), отдаёт первые limits.page_rows
+// строк и next_cursor; повтор того же вызова с cursor отдаёт следующую страницу из памяти,
+// без второго запроса в 1С. Страницы включаются явно (page_rows > 0): по умолчанию отчёт
+// отдаётся целиком, как и до них.
+//
+// Результат хранится по ключу «база + sub + хэш запроса», и курсор привязан к этому ключу:
+// страницу получит только тот же пользователь той же базы, повторивший тот же вызов. Отдельного
+// инструмента fetch_page нет сознательно — cursor живёт в аргументах самого отчёта, и доступ к
+// странице проверяется тем же scope, что и к отчёту.
+
+// pageCacheMaxEntries — потолок числа сохранённых результатов. Каждый — до max_rows строк,
+// поэтому при переполнении вытесняется тот, чей срок истекает раньше всех.
+const pageCacheMaxEntries = 256
+
+// pageableTools — отчёты, результат которых режется на страницы: те, что отдают rows.
+var pageableTools = map[string]bool{
+	ToolSalesReport:           true,
+	ToolStockBalance:          true,
+	ToolPurchasesReport:       true,
+	ToolGoodsInTransit:        true,
+	ToolProductionOutput:      true,
+	ToolProductionConsumption: true,
+}
+
+var (
+	errCursorMismatch = errors.New("cursor does not belong to this request: repeat the call with the same arguments plus cursor")
+	errCursorExpired  = errors.New("cursor expired: repeat the call without cursor to rebuild the report")
+)
+
+// cursorProp — аргумент cursor постраничных отчётов.
+func cursorProp() map[string]any {
+	return map[string]any{
+		"type":        "string",
+		"description": "Continuation cursor: next_cursor from a previous response of this report. Repeat the call with the same arguments plus cursor to get the next page — it is served from the gateway without re-running the report.",
+	}
+}
+
+// pageEntry — полный результат отчёта: всё, кроме rows, и сами строки отдельно.
+type pageEntry struct {
+	report  map[string]any
+	rows    []any
+	expires time.Time
+}
+
+// pageCache — сохранённые результаты. Общий на гейт (живёт в Sessions), чтобы курсор
+// переживал Registry.Reload так же, как сессии.
+type pageCache struct {
+	mu      sync.Mutex
+	entries map[string]pageEntry
+}
+
+func (c *pageCache) put(key string, e pageEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]pageEntry)
+	}
+	now := time.Now()
+	for k, old := range c.entries {
+		if now.After(old.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if _, replace := c.entries[key]; !replace && len(c.entries) >= pageCacheMaxEntries {
+		var oldest string
+		for k, old := range c.entries {
+			if oldest == "" || old.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = e
+}
+
+func (c *pageCache) get(key string) (pageEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return pageEntry{}, false
+	}
+	return e, true
+}
+
+// takeArgument отделяет аргумент name (cursor, output_format) от остальных: это указания гейту,
+// а не часть запроса к 1С, и в хэш запроса они не входят.
+func takeArgument(args any, name string) (string, any) {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return "", args
+	}
+	v, present := m[name]
+	if !present {
+		return "", args
+	}
+	value, _ := v.(string)
+	rest := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != name {
+			rest[k] = v
+		}
+	}
+	return strings.TrimSpace(value), rest
+}
+
+// pageKey — ключ результата: база, пользователь, инструмент и аргументы. json.Marshal
+// сортирует ключи map, так что одинаковые аргументы дают одинаковый хэш независимо от порядка.
+func pageKey(tenant, sub, tool string, args any) string {
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, _ := json.Marshal(args)
+
+	sum := sha256.New()
+	for _, part := range []string{tenant, sub, tool} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	sum.Write(data)
+	return hex.EncodeToString(sum.Sum(nil)[:16])
+}
+
+func encodePageCursor(key string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + ":" + strconv.Itoa(offset)))
+}
+
+func decodePageCursor(cursor string) (key string, offset int, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, false
+	}
+	key, n, found := strings.Cut(string(raw), ":")
+	if !found {
+		return "", 0, false
+	}
+	offset, err = strconv.Atoi(n)
+	if err != nil || offset < 0 {
+		return "", 0, false
+	}
+	return key, offset, true
+}
+
+// paginate режет ответ отчёта на страницы, если строк больше limits.page_rows: полный результат
+// уходит в pageCache, клиент получает первую страницу. Ответ без rows (или не объект) и короткий
+// отчёт возвращаются как есть.
+func (h *Handler) paginate(key string, result *CallToolResult) (*CallToolResult, error) {
+	// Без срока хранения следующие страницы отдать не из чего — тогда отчёт уходит целиком.
+	size := h.cfg.Limits.PageRows
+	if size <= 0 || h.cfg.Limits.PageTTL <= 0 || len(result.Content) == 0 {
+		return result, nil
+	}
+
+	// UseNumber — числа 1С уходят клиенту в том же виде, без круга через float64.
+	var report map[string]any
+	dec := json.NewDecoder(strings.NewReader(result.Content[0].Text))
+	dec.UseNumber()
+	if err := dec.Decode(&report); err != nil {
+		return result, nil
+	}
+	rows, _ := report["rows"].([]any)
+	if len(rows) <= size {
+		return result, nil
+	}
+
+	delete(report, "rows")
+	entry := pageEntry{report: report, rows: rows, expires: time.Now().Add(h.cfg.Limits.PageTTL)}
+	h.sessions.pages.put(key, entry)
+	return h.page(key, entry, 0)
+}
+
+// nextPage отдаёт страницу по курсору из сохранённого результата.
+func (h *Handler) nextPage(key, cursor string) (*CallToolResult, error) {
+	cursorKey, offset, ok := decodePageCursor(cursor)
+	if !ok || cursorKey != key {
+		return nil, errCursorMismatch
+	}
+	entry, ok := h.sessions.pages.get(key)
+	if !ok || offset >= len(entry.rows) {
+		return nil, errCursorExpired
+	}
+	return h.page(key, entry, offset)
+}
+
+// page собирает ответ: поля отчёта (columns, totals по всему результату, period, applied_filters)
+// плюс срез rows, total_rows, offset и next_cursor, если дальше есть строки.
+func (h *Handler) page(key string, entry pageEntry, offset int) (*CallToolResult, error) {
+	// page_rows мог смениться перезагрузкой конфига; 0 — отдать остаток целиком.
+	size := h.cfg.Limits.PageRows
+	if size <= 0 {
+		size = len(entry.rows)
+	}
+	end := min(offset+size, len(entry.rows))
+
+	out := make(map[string]any, len(entry.report)+4)
+	for k, v := range entry.report {
+		out[k] = v
+	}
+	out["rows"] = entry.rows[offset:end]
+	out["total_rows"] = len(entry.rows)
+	out["offset"] = offset
+	if end < len(entry.rows) {
+		out["next_cursor"] = encodePageCursor(key, end)
+	}
+	return toolResult(out)
+}
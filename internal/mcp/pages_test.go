@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+type reportPage struct {
+	Rows       [][]any        `json:"rows"`
+	Totals     map[string]any `json:"totals"`
+	TotalRows  int            `json:"total_rows"`
+	Offset     int            `json:"offset"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+func callReport(t *testing.T, h *Handler, ctx context.Context, args map[string]any) (reportPage, *Error) {
+	t.Helper()
+
+	res := rpc(t, h, ctx, "tools/call", map[string]any{"name": ToolSalesReport, "arguments": args})
+	if res.Error != nil {
+		return reportPage{}, res.Error
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(res.Result, &result); err != nil {
+		t.Fatalf("unmarshal %s: %v", res.Result, err)
+	}
+	if result.IsError {
+		t.Fatalf("tool error: %s", resultText(t, result))
+	}
+	var page reportPage
+	if err := json.Unmarshal([]byte(resultText(t, result)), &page); err != nil {
+		t.Fatalf("unmarshal page: %v", err)
+	}
+	return page, nil
+}
+
+// Отчёт длиннее page_rows отдаётся страницами; следующие страницы идут из памяти, без 1С.
+func TestReportPagesServedFromCache(t *testing.T) {
+	h, fake := newTestHandler(t)
+	h.cfg.Limits.PageRows = 2
+	h.cfg.Limits.PageTTL = time.Minute
+	fake.response = `{"columns":[{"name":"product","type":"string"},{"name":"amount","type":"number"}],` +
+		`"rows":[["a",1],["b",2],["c",3],["d",4],["e",5.25]],"totals":{"amount":15.25}}`
+
+	args := map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}}
+	ctx := withScopes("mcp:report:sales")
+
+	var got [][]any
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		call := map[string]any{"period": args["period"]}
+		if cursor != "" {
+			call["cursor"] = cursor
+		}
+		page, rpcErr := callReport(t, h, ctx, call)
+		if rpcErr != nil {
+			t.Fatalf("page %d: %+v", pages, rpcErr)
+		}
+		if page.TotalRows != 5 || page.Offset != len(got) || page.Totals["amount"] != 15.25 {
+			t.Errorf("page %d: total_rows=%d offset=%d totals=%v", pages, page.TotalRows, page.Offset, page.Totals)
+		}
+		got = append(got, page.Rows...)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	if len(got) != 5 || got[4][0] != "e" || got[4][1] != 5.25 {
+		t.Errorf("rows = %v", got)
+	}
+	if fake.count() != 1 {
+		t.Errorf("1C called %d times, want 1", fake.count())
+	}
+}
+
+// Курсор годится только для того же запроса того же пользователя.
+func TestReportCursorBoundToRequest(t *testing.T) {
+	h, fake := newTestHandler(t)
+	h.cfg.Limits.PageRows = 1
+	h.cfg.Limits.PageTTL = time.Minute
+	fake.response = `{"columns":[],"rows":[["a"],["b"]],"totals":{}}`
+
+	period := map[string]any{"from": "2026-01-01", "to": "2026-01-31"}
+	first, rpcErr := callReport(t, h, withScopes("mcp:report:sales"), map[string]any{"period": period})
+	if rpcErr != nil || first.NextCursor == "" {
+		t.Fatalf("first page: %+v, %+v", first, rpcErr)
+	}
+
+	cases := map[string]struct {
+		ctx  context.Context
+		args map[string]any
+	}{
+		"other arguments": {withScopes("mcp:report:sales"), map[string]any{"period": period, "top": 1.0}},
+		"other user": {
+			oauth.ContextWithAuth(context.Background(), &oauth.AuthInfo{Sub: "v", Scopes: []string{"mcp:report:sales"}}),
+			map[string]any{"period": period},
+		},
+		"garbage cursor": {withScopes("mcp:report:sales"), map[string]any{"period": period, "cursor": "not-a-cursor"}},
+	}
+	for name, tc := range cases {
+		if _, ok := tc.args["cursor"]; !ok {
+			tc.args["cursor"] = first.NextCursor
+		}
+		if _, rpcErr := callReport(t, h, tc.ctx, tc.args); rpcErr == nil || rpcErr.Code != CodeInvalidParams {
+			t.Errorf("%s: %+v, want -32602", name, rpcErr)
+		}
+	}
+	if fake.count() != 1 {
+		t.Errorf("1C called %d times, want 1", fake.count())
+	}
+}
+
+// Короткий отчёт уходит как есть, без полей страницы.
+func TestShortReportNotPaged(t *testing.T) {
+	h, fake := newTestHandler(t)
+	h.cfg.Limits.PageRows = 10
+	h.cfg.Limits.PageTTL = time.Minute
+	fake.response = `{"columns":[],"rows":[["a"]],"totals":{}}`
+
+	page, rpcErr := callReport(t, h, context.Background(), map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}})
+	if rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if page.NextCursor != "" || page.TotalRows != 0 || len(page.Rows) != 1 {
+		t.Errorf("page = %+v", page)
+	}
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// Относительные периоды (last_month, 2025-Q3, …) разворачиваются в даты до проверки схемы:
+// дальше — в валидатор, в хэш страницы, в составные инструменты и в 1С — уходят уже обычные
+// {from, to}. Часы — гейта, часовой пояс — базы (onec.Client.Now). Развёрнутые даты
+// возвращаются в ответе рядом с period, чтобы модель видела, за что именно посчитан отчёт.
+
+// periodArguments — аргументы-периоды, которые могут прийти выражением, и поля эха для них.
+var periodArguments = map[string]string{
+	"period":      "resolved_period",
+	"base_period": "resolved_base_period",
+}
+
+// resolvePeriods заменяет выражения в периодах args датами. resolved — эхо по имени поля ответа;
+// нераспознанное выражение — ошибка аргумента с путём к нему.
+func (h *Handler) resolvePeriods(args any) (any, map[string]onec.ResolvedPeriod, error) {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return args, nil, nil
+	}
+
+	var (
+		out      map[string]any
+		resolved map[string]onec.ResolvedPeriod
+		errs     argumentErrors
+	)
+	for name, echo := range periodArguments {
+		s, ok := m[name].(string)
+		if !ok {
+			continue
+		}
+		// Строку разбирает onec.Period: выражение от пустой строки и объекта-в-строке
+		// отличает он же.
+		data, _ := json.Marshal(s)
+		var p onec.Period
+		if err := json.Unmarshal(data, &p); err != nil || p.Expr == "" {
+			continue
+		}
+
+		now := h.onecClient.Now()
+		dates, err := p.Resolve(now)
+		if err != nil {
+			errs = append(errs, argumentError{Path: name, Message: err.Error()})
+			continue
+		}
+
+		if out == nil {
+			out = make(map[string]any, len(m))
+			for k, v := range m {
+				out[k] = v
+			}
+			resolved = map[string]onec.ResolvedPeriod{}
+		}
+		out[name] = map[string]any{"from": dates.From, "to": dates.To}
+		resolved[echo] = onec.ResolvedPeriod{
+			Expression: p.Expr,
+			From:       dates.From,
+			To:         dates.To,
+			Timezone:   now.Location().String(),
+		}
+	}
+
+	if len(errs) > 0 {
+		return args, nil, errs
+	}
+	if out == nil {
+		return args, nil, nil
+	}
+	return out, resolved, nil
+}
+
+// withResolvedPeriods добавляет эхо развёрнутых периодов в ответ-объект. Ответ не объект
+// (1С вернула массив) — остаётся как есть.
+func withResolvedPeriods(result *CallToolResult, resolved map[string]onec.ResolvedPeriod) *CallToolResult {
+	if len(resolved) == 0 || result == nil || result.IsError || len(result.Content) == 0 {
+		return result
+	}
+
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader([]byte(result.Content[0].Text)))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return result
+	}
+	for name, echo := range resolved {
+		obj[name] = echo
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return result
+	}
+	return rawToolResult(data)
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Выражение разворачивается до 1С: в запрос уходят даты, в ответ — эхо с выражением.
+func TestRelativePeriodResolvedBeforeOneC(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = `{"columns":[{"name":"amount","type":"number"}],"rows":[[10]],"period":{"from":"2025-07-01","to":"2025-09-30"}}`
+
+	res := callTool(t, h, ToolSalesReport, map[string]any{"period": "2025-Q3"})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	period, _ := fake.recorded(t, 0).body["period"].(map[string]any)
+	if period["from"] != "2025-07-01" || period["to"] != "2025-09-30" {
+		t.Errorf("1C got period %v", fake.recorded(t, 0).body["period"])
+	}
+
+	var got struct {
+		Resolved map[string]string `json:"resolved_period"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Resolved["expression"] != "2025-Q3" || got.Resolved["from"] != "2025-07-01" || got.Resolved["to"] != "2025-09-30" {
+		t.Errorf("resolved_period = %v", got.Resolved)
+	}
+}
+
+func TestRelativePeriodErrors(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	call := func(args map[string]any) rpcResult {
+		return rpc(t, h, context.Background(), "tools/call", map[string]any{"name": ToolSalesReport, "arguments": args})
+	}
+
+	res := call(map[string]any{"period": "next_month"})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, `period: unknown period "next_month"`) {
+		t.Errorf("unknown expression: %+v", res.Error)
+	}
+
+	// Объектная форма по-прежнему объясняет, чего не хватает, а не «не подходит ни под одну форму».
+	res = call(map[string]any{"period": map[string]any{"from": "2025-07-01"}})
+	if res.Error == nil || !strings.Contains(res.Error.Message, "period.to: is required") {
+		t.Errorf("half period: %+v", res.Error)
+	}
+
+	res = call(map[string]any{"period": ""})
+	if res.Error == nil || !strings.Contains(res.Error.Message, "period: is required") {
+		t.Errorf("blank period: %+v", res.Error)
+	}
+
+	if fake.count() != 0 {
+		t.Errorf("rejected calls reached 1C %d times", fake.count())
+	}
+}
@@ -0,0 +1,272 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Сводная таблица (pivot). Самый частый запрос — «помесячно»: group_by=[product, month] отдаёт
+// длинную таблицу, где каждый товар повторяется по строке на месяц, и модель тратит токены на
+// повторы, а потом путает столбцы. pivot перекладывает тот же ответ в матрицу: строки — значения
+// одного измерения, столбцы — другого, в ячейках — мера, плюс итоги по строкам и столбцам.
+//
+// Перекладывает гейт, по уже полученному полному ответу (до нарезки на страницы — страницы режут
+// уже матрицу): одинаково для типизированных ответов (SalesReportResponse и др.) и для сырых
+// {columns, rows} — и те и другие уходят клиенту JSON-объектом с columns/rows. pivot в 1С не
+// уходит, но в ключ страницы входит: страницы матрицы и длинного ответа — разные результаты.
+
+// pivotTools — отчёты с group_by: их ответ можно развернуть и дополнить производными мерами
+// (derived.go).
+var pivotTools = map[string]bool{
+	ToolSalesReport:           true,
+	ToolStockBalance:          true,
+	ToolAvailabilityReport:    true,
+	ToolCashBalance:           true,
+	ToolCashFlow:              true,
+	ToolReceivablesBalance:    true,
+	ToolPayablesBalance:       true,
+	ToolPurchasesReport:       true,
+	ToolGoodsInTransit:        true,
+	ToolProductionOutput:      true,
+	ToolProductionConsumption: true,
+}
+
+// nonAdditiveMeasures — меры-отношения и средние: их сумма ничего не значит. Для них итоги
+// матрицы не считаются, а строки с одинаковыми (rows, columns) не складываются — это ошибка.
+var nonAdditiveMeasures = map[string]bool{
+	"margin":           true,
+	"avg_check":        true,
+	"customers":        true,
+	"availability_pct": true,
+	"avg_qty":          true,
+	"days":             true,
+}
+
+// pivotSpec — аргумент pivot.
+type pivotSpec struct {
+	Rows    string `json:"rows"`
+	Columns string `json:"columns"`
+	Measure string `json:"measure"`
+}
+
+// pivotProp — аргумент pivot табличных отчётов.
+func pivotProp() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"description": "Return a matrix instead of the long table: one row per value of `rows`, one column per value of `columns`, the `measure` in the cells, plus a total column and column totals in `totals`. " +
+			"Both must be dimensions in group_by (e.g. group_by=[\"product\",\"month\"], pivot={rows:\"product\", columns:\"month\"}); other dimensions in group_by are summed up. " +
+			"Cells with no data are null. For ratios and averages (margin, avg_check, customers, availability_pct, avg_qty) totals are null and extra dimensions are not allowed.",
+		"properties": map[string]any{
+			"rows":    map[string]any{"type": "string", "description": "Dimension that becomes the rows."},
+			"columns": map[string]any{"type": "string", "description": "Dimension whose values become the columns (e.g. month)."},
+			"measure": map[string]any{"type": "string", "description": "Measure in the cells (default: the first measure of the report)."},
+		},
+		"required": []string{"rows", "columns"},
+	}
+}
+
+// takePivot отделяет аргумент pivot. Схему он уже прошёл, поэтому разбор не падает.
+func takePivot(args any) (*pivotSpec, any) {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return nil, args
+	}
+	raw, present := m["pivot"]
+	if !present {
+		return nil, args
+	}
+	rest := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != "pivot" {
+			rest[k] = v
+		}
+	}
+	var spec pivotSpec
+	if err := mapToStruct(raw, &spec); err != nil || spec.Rows == "" {
+		return nil, rest
+	}
+	return &spec, rest
+}
+
+// pivotReport разворачивает ответ отчёта в матрицу по spec. Ответ-ошибка и ответ не в форме
+// {columns, rows} возвращаются как есть; измерение или мера, которых нет в ответе, — ошибка
+// аргумента.
+func pivotReport(result *CallToolResult, spec *pivotSpec) (*CallToolResult, error) {
+	if spec == nil || result == nil || result.IsError || len(result.Content) == 0 {
+		return result, nil
+	}
+	t, ok := decodeReport([]byte(result.Content[0].Text))
+	if !ok {
+		return result, nil
+	}
+
+	index := func(name string) int {
+		for i, c := range t.columns {
+			if c == name {
+				return i
+			}
+		}
+		return -1
+	}
+	dims, measures := splitColumns(t)
+	dimNames := make([]string, len(dims))
+	for i, d := range dims {
+		dimNames[i] = t.columns[d]
+	}
+	measureNames := make([]string, len(measures))
+	for i, m := range measures {
+		measureNames[i] = t.columns[m]
+	}
+
+	measure := spec.Measure
+	if measure == "" && len(measureNames) > 0 {
+		measure = measureNames[0]
+	}
+	rowCol, colCol, valCol := index(spec.Rows), index(spec.Columns), index(measure)
+
+	var errs argumentErrors
+	if rowCol < 0 || t.types[rowCol] == "number" {
+		errs = append(errs, argumentError{Path: "pivot.rows", Message: fmt.Sprintf("%q is not a dimension of the result; add it to group_by", spec.Rows), Allowed: dimNames})
+	}
+	if colCol < 0 || t.types[colCol] == "number" || spec.Columns == spec.Rows {
+		errs = append(errs, argumentError{Path: "pivot.columns", Message: fmt.Sprintf("%q is not another dimension of the result; add it to group_by", spec.Columns), Allowed: dimNames})
+	}
+	if valCol < 0 || t.types[valCol] != "number" {
+		errs = append(errs, argumentError{Path: "pivot.measure", Message: fmt.Sprintf("%q is not a measure of the result", measure), Allowed: measureNames})
+	}
+	additive := !nonAdditive(measure)
+	if !additive && len(dims) > 2 {
+		errs = append(errs, argumentError{Path: "pivot", Message: fmt.Sprintf("%s cannot be summed over the other group_by dimensions; group by %s and %s only", measure, spec.Rows, spec.Columns)})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// Значения измерения-столбца: дата и строки — по возрастанию (месяцы по порядку), ссылки —
+	// в порядке появления (1С уже отсортировала их).
+	type column struct {
+		cell  any
+		name  string
+		total float64
+		seen  bool
+	}
+	var colOrder []string
+	cols := map[string]*column{}
+	type pivotRow struct {
+		cell   any
+		values map[string]float64
+	}
+	var rowOrder []string
+	rows := map[string]*pivotRow{}
+
+	for _, row := range t.rows {
+		rk, ck := rowKey(row, []int{rowCol}), rowKey(row, []int{colCol})
+		r, ok := rows[rk]
+		if !ok {
+			r = &pivotRow{cell: cellAt(row, rowCol), values: map[string]float64{}}
+			rows[rk] = r
+			rowOrder = append(rowOrder, rk)
+		}
+		c, ok := cols[ck]
+		if !ok {
+			c = &column{cell: cellAt(row, colCol), name: cellText(cellAt(row, colCol))}
+			cols[ck] = c
+			colOrder = append(colOrder, ck)
+		}
+		v := numberValue(cellAt(row, valCol))
+		r.values[ck] += v
+		c.total += v
+	}
+	if t.types[colCol] != "ref" {
+		sort.SliceStable(colOrder, func(i, j int) bool { return cols[colOrder[i]].name < cols[colOrder[j]].name })
+	}
+	// Имя столбца — наименование значения; тёзки (и пустое значение) различаются по id. Имена
+	// колонки строк и total заняты: значение «total» иначе затёрло бы итог строки, а в totals —
+	// общий итог. Что и после id совпадает (не ссылки, пустые), получает номер: «total (2)».
+	names := map[string]int{spec.Rows: 1, "total": 1}
+	for _, ck := range colOrder {
+		names[cols[ck].name]++
+	}
+	used := map[string]bool{spec.Rows: true, "total": true}
+	for _, ck := range colOrder {
+		c := cols[ck]
+		if id, _, ok := refCell(c.cell); ok && names[c.name] > 1 {
+			c.name = fmt.Sprintf("%s (%s)", c.name, id)
+		}
+		if c.name == "" {
+			c.name = "(empty)"
+		}
+		for base, n := c.name, 2; used[c.name]; n++ {
+			c.name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		used[c.name] = true
+	}
+
+	columns := []any{map[string]any{"name": spec.Rows, "type": t.types[rowCol]}}
+	for _, ck := range colOrder {
+		columns = append(columns, map[string]any{"name": cols[ck].name, "type": "number"})
+	}
+	columns = append(columns, map[string]any{"name": "total", "type": "number"})
+
+	var grand float64
+	out := make([]any, 0, len(rowOrder))
+	for _, rk := range rowOrder {
+		r := rows[rk]
+		cells := []any{r.cell}
+		var total float64
+		for _, ck := range colOrder {
+			v, ok := r.values[ck]
+			if !ok {
+				cells = append(cells, nil)
+				continue
+			}
+			cells = append(cells, jsonNumber(v))
+			total += v
+		}
+		grand += total
+		if additive {
+			cells = append(cells, jsonNumber(total))
+		} else {
+			cells = append(cells, nil)
+		}
+		out = append(out, cells)
+	}
+
+	totals := map[string]any{}
+	for _, ck := range colOrder {
+		totals[cols[ck].name] = nil
+		if additive {
+			totals[cols[ck].name] = jsonNumber(cols[ck].total)
+		}
+	}
+	totals["total"] = nil
+	if additive {
+		totals["total"] = jsonNumber(grand)
+	}
+
+	resp := t.fields
+	resp["columns"] = columns
+	resp["rows"] = out
+	resp["totals"] = totals
+	resp["pivot"] = map[string]any{"rows": spec.Rows, "columns": spec.Columns, "measure": measure}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return rawToolResult(data), nil
+}
+
+func cellAt(row []any, i int) any {
+	if i < len(row) {
+		return row[i]
+	}
+	return nil
+}
+
+// jsonNumber — сумма без хвоста двоичной арифметики (0.1+0.2): отчёты 1С считают до копеек,
+// шести знаков хватает с запасом.
+func jsonNumber(v float64) json.Number {
+	return json.Number(strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.6f", v), "0"), "."))
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const pivotSales = `{"columns":[{"name":"product","type":"ref"},{"name":"month","type":"date"},{"name":"amount","type":"number"},{"name":"qty","type":"number"}],` +
+	`"rows":[[{"id":"p1","label":"Tea"},"2026-02-01",20.1,2],[{"id":"p1","label":"Tea"},"2026-01-01",10.2,1],[{"id":"p2","label":"Coffee"},"2026-02-01",5,1]],` +
+	`"totals":{"amount":35.3,"qty":4},"period":{"from":"2026-01-01","to":"2026-02-28"}}`
+
+func TestPivotSalesReport(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = pivotSales
+
+	res := callTool(t, h, ToolSalesReport, map[string]any{
+		"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+		"group_by": []any{"product", "month"},
+		"pivot":    map[string]any{"rows": "product", "columns": "month"},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+	if _, leaked := fake.recorded(t, 0).body["pivot"]; leaked {
+		t.Error("pivot reached 1C")
+	}
+
+	var got struct {
+		Columns []struct{ Name string }
+		Rows    [][]any
+		Totals  map[string]any
+		Period  map[string]string
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Месяцы по порядку, пустая ячейка — null, итоги без хвостов float.
+	var names []string
+	for _, c := range got.Columns {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "product,2026-01-01,2026-02-01,total" {
+		t.Errorf("columns = %v", names)
+	}
+	rows, _ := json.Marshal(got.Rows)
+	if string(rows) != `[[{"id":"p1","label":"Tea"},10.2,20.1,30.3],[{"id":"p2","label":"Coffee"},null,5,5]]` {
+		t.Errorf("rows = %s", rows)
+	}
+	if got.Totals["2026-01-01"] != 10.2 || got.Totals["2026-02-01"] != 25.1 || got.Totals["total"] != 35.3 {
+		t.Errorf("totals = %v", got.Totals)
+	}
+	if got.Period["from"] != "2026-01-01" {
+		t.Errorf("other fields lost: %s", resultText(t, res))
+	}
+}
+
+// Измерения нет в ответе — ошибка аргумента со списком тех, что есть.
+func TestPivotUnknownDimension(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = pivotSales
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name": ToolSalesReport,
+		"arguments": map[string]any{
+			"period":   map[string]any{"from": "2026-01-01", "to": "2026-02-28"},
+			"group_by": []any{"product", "month"},
+			"pivot":    map[string]any{"rows": "warehouse", "columns": "month", "measure": "qty"},
+		},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, `pivot.rows: "warehouse" is not a dimension`) {
+		t.Errorf("error = %+v", res.Error)
+	}
+}
+
+// Меры-отношения не суммируются: итоги null, лишние измерения — ошибка.
+func TestPivotNonAdditive(t *testing.T) {
+	report := `{"columns":[{"name":"product","type":"ref"},{"name":"month","type":"date"},{"name":"margin","type":"number"}],` +
+		`"rows":[[{"id":"p1","label":"Tea"},"2026-01-01",30],[{"id":"p1","label":"Tea"},"2026-02-01",40]]}`
+	res, err := pivotReport(rawToolResult([]byte(report)), &pivotSpec{Rows: "product", Columns: "month"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := res.Content[0].Text; !strings.Contains(text, `[{"id":"p1","label":"Tea"},30,40,null]`) || !strings.Contains(text, `"total":null`) {
+		t.Errorf("result = %s", text)
+	}
+
+	wide := `{"columns":[{"name":"product","type":"ref"},{"name":"warehouse","type":"ref"},{"name":"month","type":"date"},{"name":"margin","type":"number"}],"rows":[]}`
+	if _, err := pivotReport(rawToolResult([]byte(wide)), &pivotSpec{Rows: "product", Columns: "month"}); err == nil {
+		t.Error("margin summed over warehouse")
+	}
+}
+
+// Значение столбца с именем колонки строк или «total» не должно затирать итог: ссылка получает
+// id, как у тёзок, прочие — номер.
+func TestPivotReservedNames(t *testing.T) {
+	columnNames := func(report string, spec *pivotSpec) ([]string, map[string]any) {
+		t.Helper()
+		res, err := pivotReport(rawToolResult([]byte(report)), spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Columns []struct{ Name string }
+			Totals  map[string]any
+		}
+		if err := json.Unmarshal([]byte(res.Content[0].Text), &got); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range got.Columns {
+			names = append(names, c.Name)
+		}
+		return names, got.Totals
+	}
+
+	p1 := refJSON("p1", "Tea")
+	refs := reportJSON([]string{"product:ref", "warehouse:ref", "amount:number"}, [][]any{
+		{p1, refJSON("w1", "total"), 1},
+		{p1, refJSON("w2", "product"), 2},
+		{p1, refJSON("w3", "Main"), 4},
+	})
+	names, totals := columnNames(refs, &pivotSpec{Rows: "product", Columns: "warehouse"})
+	if strings.Join(names, ",") != "product,total (w1),product (w2),Main,total" {
+		t.Errorf("columns = %v", names)
+	}
+	if totals["total (w1)"] != 1.0 || totals["total"] != 7.0 {
+		t.Errorf("totals = %v", totals)
+	}
+
+	codes := reportJSON([]string{"product:ref", "channel:string", "amount:number"}, [][]any{
+		{p1, "total", 1},
+		{p1, "retail", 2},
+	})
+	names, totals = columnNames(codes, &pivotSpec{Rows: "product", Columns: "channel"})
+	if strings.Join(names, ",") != "product,retail,total (2),total" {
+		t.Errorf("columns = %v", names)
+	}
+	if totals["total (2)"] != 1.0 || totals["total"] != 3.0 {
+		t.Errorf("totals = %v", totals)
+	}
+}
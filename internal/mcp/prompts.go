@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+const (
+	PromptCashConversionCycle = "cash_conversion_cycle"
+	PromptCategoryWatchdog    = "category_watchdog"
+	PromptStockoutVsDemand    = "stockout_vs_demand"
+)
+
+// promptTemplate — встроенный аналитический сценарий. tools — инструменты, которые сценарий
+// вызывает: промпт виден и выдаётся, только если вызывающему доступны ВСЕ они (по ToolScopes).
+// Иначе LLM получила бы инструкцию, которую не может выполнить, и пользователь — полуотчёт
+// без объяснения, куда делась половина цифр.
+type promptTemplate struct {
+	prompt Prompt
+	tools  []string
+	render func(args map[string]string) string
+}
+
+var promptTemplates = []promptTemplate{
+	{
+		prompt: Prompt{
+			Name:        PromptCashConversionCycle,
+			Description: "Cash conversion cycle (DIO + DSO − DPO) for a period: inventory, receivables and payables days from stock_balance, sales_report, receivables_balance, payables_balance and purchases_report.",
+			Arguments: []PromptArgument{
+				{Name: "from", Description: "Period start, YYYY-MM-DD", Required: true},
+				{Name: "to", Description: "Period end, YYYY-MM-DD", Required: true},
+			},
+		},
+		tools:  []string{ToolStockBalance, ToolSalesReport, ToolReceivablesBalance, ToolPayablesBalance, ToolPurchasesReport},
+		render: renderCashConversionCycle,
+	},
+	{
+		prompt: Prompt{
+			Name:        PromptCategoryWatchdog,
+			Description: "Weekly Category Watchdog review of one product group: lifecycle status, stock, sales and stockout days per item, with phasing-out items that still hold stock and new items that already run out.",
+			Arguments: []PromptArgument{
+				{Name: "category", Description: "Product group name (товарная группа)", Required: true},
+				{Name: "from", Description: "Review period start, YYYY-MM-DD", Required: true},
+				{Name: "to", Description: "Review period end, YYYY-MM-DD", Required: true},
+				{Name: "warehouse", Description: "Restrict to one warehouse (name); all trading warehouses when omitted"},
+			},
+		},
+		tools:  []string{ToolResolveProduct, ToolProductDetails, ToolStockBalance, ToolSalesReport, ToolAvailabilityReport},
+		render: renderCategoryWatchdog,
+	},
+	{
+		prompt: Prompt{
+			Name:        PromptStockoutVsDemand,
+			Description: "Stockout-vs-demand investigation: items that were out of stock for many days in the period, how much they sell when available, current stock and incoming supply.",
+			Arguments: []PromptArgument{
+				{Name: "from", Description: "Period start, YYYY-MM-DD", Required: true},
+				{Name: "to", Description: "Period end, YYYY-MM-DD", Required: true},
+				{Name: "scope", Description: "Product or product group name to investigate; the whole assortment when omitted"},
+				{Name: "warehouse", Description: "Restrict to one warehouse (name)"},
+			},
+		},
+		tools:  []string{ToolResolveProduct, ToolAvailabilityReport, ToolSalesReport, ToolStockBalance, ToolGoodsInTransit},
+		render: renderStockoutVsDemand,
+	},
+}
+
+// promptAllowed — все инструменты сценария доступны вызывающему. Без OAuth — всё, как и в tools/list.
+func promptAllowed(auth *oauth.AuthInfo, t promptTemplate) bool {
+	if auth == nil {
+		return true
+	}
+	for _, tool := range t.tools {
+		required, ok := ToolScopes[tool]
+		if !ok || !auth.HasScope(required) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) handlePromptsList(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	prompts := make([]Prompt, 0, len(promptTemplates))
+	for _, t := range promptTemplates {
+		if promptAllowed(auth, t) {
+			prompts = append(prompts, t.prompt)
+		}
+	}
+
+	sub, cid := authIdentity(auth)
+	h.logger.Info("mcp.prompt.list", "sub", sub, "client_id", cid, "count", len(prompts))
+
+	return NewResponse(req.ID, ListPromptsResult{Prompts: prompts})
+}
+
+// handlePromptsGet отдаёт сценарий с подставленными аргументами. Недоступный по правам промпт
+// отвечает так же, как несуществующий: в prompts/list его нет.
+func (h *Handler) handlePromptsGet(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	var params GetPromptParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return InvalidParams(req.ID, "failed to parse params")
+	}
+
+	t, ok := findPromptTemplate(params.Name)
+	if !ok || !promptAllowed(auth, t) {
+		return InvalidParams(req.ID, "unknown prompt: "+params.Name)
+	}
+
+	args := make(map[string]string, len(params.Arguments))
+	for k, v := range params.Arguments {
+		args[k] = strings.TrimSpace(v)
+	}
+	for _, a := range t.prompt.Arguments {
+		if a.Required && args[a.Name] == "" {
+			return InvalidParams(req.ID, fmt.Sprintf("prompt %q requires argument %q", t.prompt.Name, a.Name))
+		}
+	}
+
+	sub, cid := authIdentity(auth)
+	h.logger.Info("mcp.prompt.get", "sub", sub, "client_id", cid, "prompt", t.prompt.Name)
+
+	return NewResponse(req.ID, GetPromptResult{
+		Description: t.prompt.Description,
+		Messages: []PromptMessage{{
+			Role:    "user",
+			Content: TextContent(t.render(args)),
+		}},
+	})
+}
+
+func findPromptTemplate(name string) (promptTemplate, bool) {
+	for _, t := range promptTemplates {
+		if t.prompt.Name == name {
+			return t, true
+		}
+	}
+	return promptTemplate{}, false
+}
+
+// optional — фраза с необязательным аргументом или пусто, если аргумент не задан.
+func optional(format, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, value)
+}
+
+func renderCashConversionCycle(args map[string]string) string {
+	return fmt.Sprintf(`Calculate the cash conversion cycle for the period %[1]s — %[2]s.
+
+1. Inventory: call stock_balance with measures ["amount"] as of %[1]s and as of %[2]s; average the two totals.
+2. Sales: call sales_report for the period with measures ["amount"] and no group_by beyond what the totals need.
+3. Receivables: call receivables_balance as of %[1]s and %[2]s; use the receivable measure (not net), average the two.
+4. Payables: call payables_balance as of %[1]s and %[2]s; use the payable measure, average the two.
+5. Purchases: call purchases_report for the period (default in_transit handling) — this is the DPO base.
+
+Let N be the number of days in the period. Compute:
+- DIO = average inventory / purchases × N
+- DSO = average receivables / sales × N
+- DPO = average payables / purchases × N
+- CCC = DIO + DSO − DPO
+
+Present a small table with every input figure, each component in days and the resulting CCC. State which tool each number came from, and call out any component that dominates the cycle.`,
+		args["from"], args["to"])
+}
+
+func renderCategoryWatchdog(args map[string]string) string {
+	return fmt.Sprintf(`Run the weekly Category Watchdog review for the product group «%[1]s», period %[2]s — %[3]s%[4]s.
+
+1. Call resolve_product with query "%[1]s" and include_groups=true; pick the group candidate (ask me if several match).
+2. Call product_details with the group id to get every item's status, status_changed_at, markets and eu_certification.
+3. Call stock_balance filtered by the group (group_by ["product"], measures ["qty","amount"]) as of %[3]s.
+4. Call sales_report for the period, group_by ["product"], measures ["qty","amount"], and keep only the items from step 2 (sales_report has no product filter).
+5. Call availability_report for the period filtered by the group, group_by ["product"], measures ["oos_days","days","availability_pct"].
+
+Join the results by product id and report:
+- phasing_out and excluded items that still hold stock (qty and amount), largest first;
+- new and active items with oos_days > 0, worst availability first, with their sales in the period;
+- items whose status changed within the period;
+- items without EU certification (eu_certification.code = in_process) that are marked for the EU market.
+
+Keep status codes as returned (new, active, phasing_out, excluded); use labels only for display. Remember that an item at zero for the whole period has no availability rows — check it against its status instead.`,
+		args["category"], args["from"], args["to"], optional(", warehouse «%s» (resolve it with resolve_warehouse and filter every report by it)", args["warehouse"]))
+}
+
+func renderStockoutVsDemand(args map[string]string) string {
+	scope := "the whole assortment"
+	if args["scope"] != "" {
+		scope = fmt.Sprintf("«%s» (resolve it with resolve_product, include_groups=true, and filter every report by the chosen id)", args["scope"])
+	}
+
+	return fmt.Sprintf(`Investigate stockouts versus demand for %[1]s, period %[2]s — %[3]s%[4]s.
+
+1. Call availability_report for the period, group_by ["product"], measures ["oos_days","days","availability_pct"], sorted by oos_days desc, top 50.
+2. Call sales_report for the period, group_by ["product"], measures ["qty","amount"], and keep only the products from step 1 (sales_report has no product filter).
+3. Call stock_balance as of %[3]s for the same products (measures ["qty"]).
+4. Call goods_in_transit for the same products to see incoming supply and expected delivery_date.
+
+For each product compute the demand while available: qty sold / (days − oos_days), and the lost sales estimate: that daily rate × oos_days.
+Rank products by estimated lost sales and present: availability %%, oos_days, daily demand, lost qty and amount, current stock, quantity in transit and its expected arrival.
+Flag products that are still at zero with nothing in transit — those need a reorder now.`,
+		scope, args["from"], args["to"], optional(", warehouse «%s» (resolve it with resolve_warehouse and filter every report by it)", args["warehouse"]))
+}
@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Промпт виден только тому, кому доступны все инструменты сценария.
+func TestPromptsListFollowsScopes(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	names := func(scopes ...string) []string {
+		var list ListPromptsResult
+		if err := json.Unmarshal(rpc(t, h, withScopes(scopes...), "prompts/list", nil).Result, &list); err != nil {
+			t.Fatal(err)
+		}
+		out := make([]string, 0, len(list.Prompts))
+		for _, p := range list.Prompts {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	if got := names("mcp:resolve", "mcp:report:sales"); len(got) != 0 {
+		t.Errorf("without stock scope: %v", got)
+	}
+	if got := names("mcp:resolve", "mcp:report:sales", "mcp:report:stock"); strings.Join(got, ",") != PromptCategoryWatchdog+","+PromptStockoutVsDemand {
+		t.Errorf("stock+sales: %v", got)
+	}
+	if got := names("mcp:resolve", "mcp:report:sales", "mcp:report:stock", "mcp:report:money"); len(got) != 3 {
+		t.Errorf("all scopes: %v", got)
+	}
+}
+
+func TestPromptsGet(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := withScopes("mcp:resolve", "mcp:report:sales", "mcp:report:stock")
+
+	resp := rpc(t, h, ctx, "prompts/get", map[string]any{
+		"name":      PromptCategoryWatchdog,
+		"arguments": map[string]string{"category": "Шампуні", "from": "2026-06-01", "to": "2026-06-07"},
+	})
+	if resp.Error != nil {
+		t.Fatalf("get: %+v", resp.Error)
+	}
+	var got GetPromptResult
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Fatalf("messages: %+v", got.Messages)
+	}
+	text := got.Messages[0].Content.Text
+	if !strings.Contains(text, "«Шампуні»") || !strings.Contains(text, "2026-06-07") || strings.Contains(text, "%!") {
+		t.Errorf("rendered prompt: %s", text)
+	}
+
+	resp = rpc(t, h, ctx, "prompts/get", map[string]any{
+		"name":      PromptCategoryWatchdog,
+		"arguments": map[string]string{"category": "Шампуні"},
+	})
+	if resp.Error == nil || resp.Error.Code != CodeInvalidParams {
+		t.Errorf("missing required argument: %+v", resp)
+	}
+
+	// Без mcp:report:money сценарий CCC недоступен — и не отличается от несуществующего.
+	resp = rpc(t, h, ctx, "prompts/get", map[string]any{
+		"name":      PromptCashConversionCycle,
+		"arguments": map[string]string{"from": "2026-01-01", "to": "2026-03-31"},
+	})
+	if resp.Error == nil || !strings.Contains(resp.Error.Data.(string), "unknown prompt") {
+		t.Errorf("CCC without money scope: %+v", resp)
+	}
+}
+
+// Шаблоны рендерятся и с одними обязательными аргументами, и со всеми: fmt не должен оставить
+// в тексте следов вида %!s(MISSING).
+func TestPromptTemplatesRender(t *testing.T) {
+	for _, tpl := range promptTemplates {
+		required := map[string]string{}
+		all := map[string]string{}
+		for _, a := range tpl.prompt.Arguments {
+			all[a.Name] = "x-" + a.Name
+			if a.Required {
+				required[a.Name] = "x-" + a.Name
+			}
+		}
+		for _, args := range []map[string]string{required, all} {
+			if text := tpl.render(args); strings.Contains(text, "%!") {
+				t.Errorf("%s: broken format: %s", tpl.prompt.Name, text)
+			}
+		}
+	}
+}
@@ -1,6 +1,16 @@
 package mcp
 
-const ProtocolVersion = "2024-11-05"
+const (
+	ProtocolVersion = "2024-11-05"
+	// ProtocolVersionStreamable — ревизия, в которой появился транспорт Streamable HTTP
+	// (сессии через Mcp-Session-Id, SSE-поток на GET, SSE-ответ на POST) и аннотации инструментов.
+	ProtocolVersionStreamable = "2025-03-26"
+	// ProtocolVersionStructured — ревизия structured output (outputSchema/structuredContent),
+	// title у инструментов, elicitation и заголовка MCP-Protocol-Version.
+	ProtocolVersionStructured = "2025-06-18"
+	// LatestProtocolVersion — то, что гейт предлагает клиенту, чью ревизию он не знает.
+	LatestProtocolVersion = ProtocolVersionStructured
+)
 
 type InitializeParams struct {
 	ProtocolVersion string     `json:"protocolVersion"`
@@ -25,31 +35,210 @@ type ServerInfo struct {
 }
 
 type Capabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
+	Logging   *LoggingCapability   `json:"logging,omitempty"`
+	// Completions — с ревизии 2025-03-26; клиентам старше не объявляется.
+	Completions *CompletionsCapability `json:"completions,omitempty"`
 }
 
 type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// LoggingCapability — пустой объект: сервер шлёт notifications/message и понимает logging/setLevel.
+type LoggingCapability struct{}
+
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+// LoggingMessageParams — notifications/message. Data — произвольный JSON; у гейта это объект
+// с полем message и подробностями события.
+type LoggingMessageParams struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+type CompletionsCapability struct{}
+
+// CompleteParams — completion/complete. Ref указывает, чей аргумент дополняется:
+// ref/prompt (Name), ref/resource (URI шаблона) или расширение гейта ref/tool (Name).
+type CompleteParams struct {
+	Ref      CompleteRef      `json:"ref"`
+	Argument CompleteArgument `json:"argument"`
+}
+
+type CompleteRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompleteArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CompleteResult struct {
+	Completion Completion `json:"completion"`
+}
+
+// Completion — варианты значения. Спецификация ограничивает Values сотней; HasMore говорит
+// клиенту, что стоит уточнить ввод.
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
 type Tool struct {
-	Name        string `json:"name"`
+	Name string `json:"name"`
+	// Title — человекочитаемое имя для интерфейса клиента; Name остаётся идентификатором.
+	Title       string `json:"title,omitempty"`
 	Description string `json:"description,omitempty"`
 	InputSchema any    `json:"inputSchema"`
+	// OutputSchema — JSON Schema объекта structuredContent успешного ответа.
+	OutputSchema any              `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations — подсказки клиенту о поведении инструмента. Подсказки, а не гарантии:
+// права проверяются в handleToolsCall и на стороне 1С независимо от них.
+//
+// Булевы поля без omitempty: у openWorldHint значение по умолчанию в спецификации — true,
+// и опущенный false клиент прочитал бы наоборот.
+type ToolAnnotations struct {
+	ReadOnlyHint   bool `json:"readOnlyHint"`
+	IdempotentHint bool `json:"idempotentHint"`
+	OpenWorldHint  bool `json:"openWorldHint"`
+	// SensitivityHint — расширение гейта, не часть спецификации: "pii" помечает инструменты,
+	// выдающие персональные данные (журнал регистрации: пользователи, компьютеры, сеансы).
+	// Клиент, не знающий поля, его проигнорирует.
+	SensitivityHint string `json:"sensitivityHint,omitempty"`
+}
+
+// ListToolsParams — tools/list. Cursor — nextCursor предыдущей страницы; пусто — первая.
+type ListToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type ListToolsResult struct {
-	Tools []Tool `json:"tools"`
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type CallToolParams struct {
-	Name      string `json:"name"`
-	Arguments any    `json:"arguments,omitempty"`
+	Name      string       `json:"name"`
+	Arguments any          `json:"arguments,omitempty"`
+	Meta      *RequestMeta `json:"_meta,omitempty"`
+}
+
+// RequestMeta — служебные поля запроса (_meta). ProgressToken — строка или число: клиент
+// просит присылать по нему notifications/progress, пока запрос выполняется.
+type RequestMeta struct {
+	ProgressToken any `json:"progressToken,omitempty"`
+}
+
+// CancelledParams — notifications/cancelled: клиент больше не ждёт ответа на RequestID.
+type CancelledParams struct {
+	RequestID any    `json:"requestId"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type ProgressParams struct {
+	ProgressToken any     `json:"progressToken"`
+	Progress      float64 `json:"progress"`
+	Total         float64 `json:"total,omitempty"`
+	Message       string  `json:"message,omitempty"`
 }
 
 type CallToolResult struct {
 	Content []ContentBlock `json:"content"`
-	IsError bool           `json:"isError,omitempty"`
+	// StructuredContent — тот же ответ объектом, по схеме Tool.OutputSchema. У ошибок его нет.
+	StructuredContent any  `json:"structuredContent,omitempty"`
+	IsError           bool `json:"isError,omitempty"`
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate — параметризованный ресурс, URI задаётся шаблоном RFC 6570.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ListResourcesResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// GetPromptParams — аргументы промпта по спецификации всегда строки: их вводит пользователь
+// в форме клиента, а не LLM.
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
 }
 
 type ContentBlock struct {
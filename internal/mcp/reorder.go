@@ -0,0 +1,359 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"time"
+)
+
+// reorder_suggestions — утренний вопрос закупщика «что и сколько заказать» одним вызовом. Четыре
+// отчёта, которые модель до сих пор сводила сама и путала в единицах (дни наличия против дней
+// периода, остаток против прихода):
+//
+//	sales_report        — продано за окно lookback_days (до вчера включительно);
+//	availability_report — дни без остатка за то же окно;
+//	stock_balance       — остаток на текущий момент;
+//	goods_in_transit    — товары в пути с ожидаемой датой поставки.
+//
+// Скорость продаж — проданное / дни наличия: дни, когда товара не было, спрос не показывают, и
+// делить на них — занижать скорость ровно у тех товаров, которых не хватает. Всё в единицах
+// товара в день; даты — в часовом поясе базы (onec.Client.Now).
+//
+// Право на инструмент — mcp:report:stock и mcp:report:sales (ToolScopes + toolExtraScopes);
+// вложенные вызовы, как и у остальных составных инструментов, проверяются checkReportCall
+// (см. composite.go).
+//
+// У sales_report нет фильтра по товарам: product_ids ему не передаётся, а строки продаж чужих
+// товаров отбрасываются гейтом. Какие товары входят в product_ids, гейт узнаёт у product_details:
+// он разворачивает группы (IN HIERARCHY) и, в отличие от отчётов по остаткам, отдаёт и товары без
+// остатка, наличия и поставок — проданный до нуля товар группы из рекомендаций не выпадает. Для
+// этого нужен ещё mcp:resolve (scope product_details). Продажи по всем товарам могут упереться в
+// max_rows и молча потерять строки нужных товаров — тогда вызов отклоняется с просьбой сузить
+// warehouse_ids.
+
+const (
+	defaultReorderLookback = 28
+	defaultReorderHorizon  = 14
+	maxReorderDays         = 365
+
+	// productDetailsLimit — сколько товаров самое большее отдаёт product_details.
+	productDetailsLimit = 500
+)
+
+type reorderArgs struct {
+	Filters struct {
+		WarehouseIDs []string `json:"warehouse_ids"`
+		ProductIDs   []string `json:"product_ids"`
+	} `json:"filters"`
+	LookbackDays flexInt `json:"lookback_days"`
+	HorizonDays  flexInt `json:"horizon_days"`
+	Top          flexInt `json:"top"`
+}
+
+// reorderItem — товар на складе: всё, что о нём сказали четыре отчёта.
+type reorderItem struct {
+	product, warehouse any
+	sold, oosDays      float64
+	stock              float64
+	inTransit, due     float64   // всё в пути и то, что придёт до конца горизонта (или без даты)
+	arrivals           []arrival // поставки с датой — для прогноза
+	nextDelivery       string
+}
+
+// arrival — поставка через days дней от сегодня.
+type arrival struct {
+	days int
+	qty  float64
+}
+
+func (h *Handler) callReorderSuggestions(ctx context.Context, args any) (*CallToolResult, error) {
+	var a reorderArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	lookback := int(a.LookbackDays)
+	if lookback == 0 {
+		lookback = defaultReorderLookback
+	}
+	horizon := int(a.HorizonDays)
+	if horizon == 0 {
+		horizon = defaultReorderHorizon
+	}
+	var errs argumentErrors
+	if lookback < 1 || lookback > maxReorderDays {
+		errs = append(errs, argumentError{Path: "lookback_days", Message: "expected 1..365"})
+	}
+	if horizon < 1 || horizon > maxReorderDays {
+		errs = append(errs, argumentError{Path: "horizon_days", Message: "expected 1..365"})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	now := h.onecClient.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -lookback).Format(time.DateOnly), today.AddDate(0, 0, -1).Format(time.DateOnly)
+	period := func() map[string]any { return map[string]any{"from": from, "to": to} }
+	horizonEnd := today.AddDate(0, 0, horizon)
+
+	salesFilters := map[string]any{}
+	if len(a.Filters.WarehouseIDs) > 0 {
+		salesFilters["warehouse_ids"] = jsonStrings(a.Filters.WarehouseIDs)
+	}
+	filters := maps.Clone(salesFilters)
+	if len(a.Filters.ProductIDs) > 0 {
+		filters["product_ids"] = jsonStrings(a.Filters.ProductIDs)
+	}
+	calls := []reportCall{
+		{ToolSalesReport, map[string]any{
+			"period":   period(),
+			"filters":  salesFilters,
+			"group_by": []any{"product", "warehouse"},
+			"measures": []any{"qty"},
+		}},
+		{ToolAvailabilityReport, map[string]any{
+			"period":   period(),
+			"filters":  filters,
+			"group_by": []any{"product", "warehouse"},
+			"measures": []any{"oos_days"},
+		}},
+		{ToolStockBalance, map[string]any{
+			"filters":  filters,
+			"group_by": []any{"product", "warehouse"},
+			"measures": []any{"qty"},
+		}},
+		{ToolGoodsInTransit, map[string]any{
+			"filters":  filters,
+			"group_by": []any{"product", "warehouse", "delivery_date"},
+			"measures": []any{"qty"},
+		}},
+	}
+	if err := h.checkReportCalls(ctx, calls); err != nil {
+		return nil, err
+	}
+	// Товары, прошедшие product_ids: названные прямо и входящие в названные группы.
+	var allowed map[string]bool
+	if len(a.Filters.ProductIDs) > 0 {
+		var err error
+		if allowed, err = h.groupProducts(ctx, a.Filters.ProductIDs); err != nil {
+			return nil, err
+		}
+	}
+	reports, err := h.fetchReports(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+	sales, availability, stock, transit := reports[0], reports[1], reports[2], reports[3]
+	if allowed != nil && len(sales.rows) >= h.cfg.Limits.MaxRows {
+		return nil, argumentErrors{{
+			Path:    "filters.warehouse_ids",
+			Message: "sales_report has no product filter and hit the row limit for all products, so sales of the requested products may be missing; narrow warehouse_ids",
+		}}
+	}
+
+	var order []string
+	items := map[string]*reorderItem{}
+	item := func(t tableReport, row []any) *reorderItem {
+		product, warehouse := t.cell(row, "product"), t.cell(row, "warehouse")
+		key := rowKey([]any{product, warehouse}, []int{0, 1})
+		it, ok := items[key]
+		if !ok {
+			it = &reorderItem{product: product, warehouse: warehouse}
+			items[key] = it
+			order = append(order, key)
+		}
+		return it
+	}
+	for _, row := range sales.rows {
+		if id, _, _ := refCell(sales.cell(row, "product")); allowed != nil && !allowed[id] {
+			continue
+		}
+		item(sales, row).sold += numberValue(sales.cell(row, "qty"))
+	}
+	for _, row := range availability.rows {
+		item(availability, row).oosDays += numberValue(availability.cell(row, "oos_days"))
+	}
+	for _, row := range stock.rows {
+		item(stock, row).stock += numberValue(stock.cell(row, "qty"))
+	}
+	for _, row := range transit.rows {
+		it := item(transit, row)
+		qty := numberValue(transit.cell(row, "qty"))
+		it.inTransit += qty
+
+		date := cellText(transit.cell(row, "delivery_date"))
+		arrives, err := time.Parse(time.DateOnly, firstN(date, 10))
+		if err != nil {
+			// Без даты поставки — в пути, но когда придёт, неизвестно: к заказу засчитываем,
+			// в прогноз дня окончания не берём.
+			it.due += qty
+			continue
+		}
+		if !arrives.After(horizonEnd) {
+			it.due += qty
+		}
+		// Просроченная поставка ожидается сегодня.
+		it.arrivals = append(it.arrivals, arrival{days: max(0, int(arrives.Sub(today).Hours()/24)), qty: qty})
+		if d := arrives.Format(time.DateOnly); it.nextDelivery == "" || d < it.nextDelivery {
+			it.nextDelivery = d
+		}
+	}
+
+	type suggestion struct {
+		row      []any
+		stockout int
+		qty      float64
+	}
+	var suggestions []suggestion
+	var totalQty float64
+	for _, key := range order {
+		it := items[key]
+		// Без продаж за окно заказывать нечего — это уже вопрос неликвида, а не пополнения.
+		if it.sold <= 0 {
+			continue
+		}
+		inStockDays := float64(lookback) - math.Min(it.oosDays, float64(lookback))
+		if inStockDays <= 0 {
+			inStockDays = float64(lookback)
+		}
+		velocity := it.sold / inStockDays
+		onHand := math.Max(it.stock, 0)
+
+		stockoutDays := projectStockout(onHand, velocity, it.arrivals)
+		var nextDelivery any
+		if it.nextDelivery != "" {
+			nextDelivery = it.nextDelivery
+		}
+		qty := math.Max(0, math.Ceil(velocity*float64(horizon)-onHand-it.due))
+		totalQty += qty
+
+		suggestions = append(suggestions, suggestion{
+			row: []any{
+				it.product, it.warehouse,
+				roundTo(it.sold, 3), roundTo(it.oosDays, 0), roundTo(velocity, 3),
+				roundTo(it.stock, 3), roundTo(it.inTransit, 3), nextDelivery,
+				roundTo(onHand/velocity, 1), today.AddDate(0, 0, stockoutDays).Format(time.DateOnly), qty,
+			},
+			stockout: stockoutDays,
+			qty:      qty,
+		})
+	}
+	// Сначала то, что кончится раньше; при равенстве — что нужно заказать больше.
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if si, sj := suggestions[i].stockout, suggestions[j].stockout; si != sj {
+			return si < sj
+		}
+		return suggestions[i].qty > suggestions[j].qty
+	})
+
+	rows := make([][]any, 0, len(suggestions))
+	for _, s := range suggestions {
+		rows = append(rows, s.row)
+	}
+	if top := int(a.Top); top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	columns := []map[string]any{}
+	for _, c := range []struct{ name, typ string }{
+		{"product", "ref"}, {"warehouse", "ref"}, {"sold_qty", "number"}, {"oos_days", "number"},
+		{"velocity", "number"}, {"stock_qty", "number"}, {"in_transit_qty", "number"}, {"next_delivery", "date"},
+		{"days_of_cover", "number"}, {"stockout_date", "date"}, {"suggested_qty", "number"},
+	} {
+		columns = append(columns, map[string]any{"name": c.name, "type": c.typ})
+	}
+
+	resp := map[string]any{
+		"columns": columns,
+		"rows":    rows,
+		"totals": map[string]any{
+			"suggested_qty": totalQty,
+			"items":         len(suggestions),
+		},
+		"as_of":         today.Format(time.DateOnly),
+		"period":        period(),
+		"lookback_days": lookback,
+		"horizon_days":  horizon,
+		"horizon_end":   horizonEnd.Format(time.DateOnly),
+		"applied_filters": map[string]any{
+			"warehouse_ids": a.Filters.WarehouseIDs,
+			"product_ids":   a.Filters.ProductIDs,
+		},
+	}
+	// Любой из срезов упёрся в max_rows — часть товаров посчитана без продаж, остатка или прихода.
+	for _, r := range reports {
+		if len(r.rows) >= h.cfg.Limits.MaxRows {
+			resp["truncated"] = true
+		}
+	}
+	return toolResult(resp)
+}
+
+// groupProducts — id товаров, которые отбирает фильтр ids: сами ids и товары названных групп по
+// product_details (IN HIERARCHY).
+func (h *Handler) groupProducts(ctx context.Context, ids []string) (map[string]bool, error) {
+	if err := checkToolScope(ctx, ToolProductDetails); err != nil {
+		return nil, err
+	}
+	result, err := h.callProductDetails(ctx, map[string]any{"product_ids": ids, "fields": []string{"label"}})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Content) == 0 {
+		return nil, fmt.Errorf("%s: empty response", ToolProductDetails)
+	}
+	var resp struct {
+		Products []struct {
+			ID string `json:"id"`
+		} `json:"products"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &resp); err != nil {
+		return nil, fmt.Errorf("%s: %w", ToolProductDetails, err)
+	}
+	// Список обрезан — товары сверх него отбросились бы вместе с продажами.
+	if len(resp.Products) >= productDetailsLimit {
+		return nil, argumentErrors{{
+			Path:    "filters.product_ids",
+			Message: fmt.Sprintf("expands to %d or more products; pass smaller groups", productDetailsLimit),
+		}}
+	}
+
+	allowed := make(map[string]bool, len(ids)+len(resp.Products))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	for _, p := range resp.Products {
+		allowed[p.ID] = true
+	}
+	return allowed, nil
+}
+
+// projectStockout — через сколько дней кончится товар при скорости velocity с учётом поставок с
+// датой. 0 — кончился уже сегодня. Скорость у вызывающего всегда положительная.
+func projectStockout(onHand, velocity float64, arrivals []arrival) int {
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].days < arrivals[j].days })
+
+	left, day := onHand, 0
+	for _, a := range arrivals {
+		need := velocity * float64(a.days-day)
+		if left < need {
+			break // остаток кончится до этой поставки
+		}
+		left += a.qty - need
+		day = a.days
+	}
+	return day + int(left/velocity)
+}
+
+// firstN — первые n байт строки (дата из «YYYY-MM-DDT00:00:00»).
+func firstN(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reorderFake — склад w1: p1 продавался полпериода и ждёт поставку, p2 продаётся ровно,
+// p3 лежит без продаж.
+func reorderFake(today time.Time) func(path string, body map[string]any) string {
+	p1, p2, p3, w1 := refJSON("p1", "Item p1"), refJSON("p2", "Item p2"), refJSON("p3", "Item p3"), refJSON("w1", "Item w1")
+	return func(path string, body map[string]any) string {
+		switch path {
+		case "/mcp/reports/sales":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "qty:number"}, [][]any{{p1, w1, 20}, {p2, w1, 10}})
+		case "/mcp/reports/availability":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "oos_days:number"}, [][]any{{p1, w1, 5}})
+		case "/mcp/reports/stock":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "qty:number"}, [][]any{{p1, w1, 8}, {p2, w1, 30}, {p3, w1, 5}})
+		case "/mcp/reports/goods_in_transit":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "delivery_date:date", "qty:number"},
+				[][]any{{p1, w1, today.AddDate(0, 0, 5).Format(time.DateOnly), 40}})
+		}
+		return `{}`
+	}
+}
+
+func TestReorderSuggestions(t *testing.T) {
+	h, fake := newTestHandler(t)
+	now := h.onecClient.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	fake.respond = reorderFake(today)
+
+	res := callTool(t, h, ToolReorderSuggestions, map[string]any{
+		"filters":       map[string]any{"warehouse_ids": []any{"w1"}},
+		"lookback_days": 10,
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	if fake.count() != 4 {
+		t.Fatalf("1C calls = %d, want 4", fake.count())
+	}
+	for i := range 4 {
+		r := fake.recorded(t, i)
+		if period, ok := r.body["period"].(map[string]any); ok &&
+			(period["from"] != today.AddDate(0, 0, -10).Format(time.DateOnly) || period["to"] != today.AddDate(0, 0, -1).Format(time.DateOnly)) {
+			t.Errorf("%s period = %v", r.path, period)
+		}
+	}
+
+	var got struct{ Rows [][]any }
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != 2 {
+		t.Fatalf("rows = %v, want p1 and p2 (p3 has no sales)", got.Rows)
+	}
+
+	// p1: 20 шт. за 5 дней наличия — 4 в день; 8 шт. хватит на 2 дня, поставка через 5 — поздно.
+	// Заказ на 14 дней: 56 − 8 − 40 в пути = 8.
+	p1 := got.Rows[0]
+	if id, _, _ := refCell(p1[0]); id != "p1" || p1[4] != 4.0 || p1[8] != 2.0 ||
+		p1[9] != today.AddDate(0, 0, 2).Format(time.DateOnly) || p1[10] != 8.0 {
+		t.Errorf("p1 = %v", p1)
+	}
+	// p2: 1 в день, 30 шт. — на 30 дней, заказывать нечего.
+	p2 := got.Rows[1]
+	if p2[4] != 1.0 || p2[9] != today.AddDate(0, 0, 30).Format(time.DateOnly) || p2[10] != 0.0 {
+		t.Errorf("p2 = %v", p2)
+	}
+}
+
+// Поставка раньше, чем кончится остаток, отодвигает день окончания.
+func TestProjectStockout(t *testing.T) {
+	cases := []struct {
+		onHand, velocity float64
+		arrivals         []arrival
+		want             int
+	}{
+		{10, 2, nil, 5},
+		{0, 2, nil, 0},
+		{10, 2, []arrival{{days: 3, qty: 10}}, 10},
+		{10, 2, []arrival{{days: 8, qty: 10}}, 5},
+		{0, 1, []arrival{{days: 0, qty: 3}}, 3},
+	}
+	for _, c := range cases {
+		if got := projectStockout(c.onHand, c.velocity, c.arrivals); got != c.want {
+			t.Errorf("projectStockout(%v, %v, %v) = %d, want %d", c.onHand, c.velocity, c.arrivals, got, c.want)
+		}
+	}
+}
+
+// У sales_report нет фильтра по товарам: product_ids (здесь группа g1 из p1 и p2) ему не уходит,
+// а продажи товаров вне группы (p9) отбрасываются. p2 распродан — в отчётах по остаткам его нет,
+// но состав группы гейт берёт у product_details, и p2 остаётся в рекомендациях.
+func TestReorderSuggestionsFiltersProducts(t *testing.T) {
+	h, fake := newTestHandler(t)
+	now := h.onecClient.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	all := reorderFake(today)
+	w1 := refJSON("w1", "Item w1")
+	fake.respond = func(path string, body map[string]any) string {
+		switch path {
+		case "/mcp/reports/product_details":
+			return `{"products":[{"id":"p1","label":"Item p1"},{"id":"p2","label":"Item p2"}]}`
+		case "/mcp/reports/sales":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "qty:number"},
+				[][]any{{refJSON("p1", "Item p1"), w1, 20}, {refJSON("p2", "Item p2"), w1, 10}, {refJSON("p9", "Item p9"), w1, 50}})
+		case "/mcp/reports/stock":
+			return reportJSON([]string{"product:ref", "warehouse:ref", "qty:number"}, [][]any{{refJSON("p1", "Item p1"), w1, 8}})
+		}
+		return all(path, body)
+	}
+
+	res := callTool(t, h, ToolReorderSuggestions, map[string]any{
+		"filters":       map[string]any{"product_ids": []any{"g1"}},
+		"lookback_days": 10,
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	if fake.count() != 5 {
+		t.Fatalf("1C calls = %d, want 5", fake.count())
+	}
+	for i := range 5 {
+		r := fake.recorded(t, i)
+		if r.path == "/mcp/reports/product_details" {
+			if ids, _ := json.Marshal(r.body["product_ids"]); string(ids) != `["g1"]` {
+				t.Errorf("product_details product_ids = %s", ids)
+			}
+			continue
+		}
+		filters, _ := r.body["filters"].(map[string]any)
+		_, sent := filters["product_ids"]
+		if want := r.path != "/mcp/reports/sales"; sent != want {
+			t.Errorf("%s: product_ids sent = %v", r.path, sent)
+		}
+	}
+
+	var got struct{ Rows [][]any }
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, row := range got.Rows {
+		id, _, _ := refCell(row[0])
+		ids = append(ids, id)
+	}
+	if slices.Sort(ids); strings.Join(ids, ",") != "p1,p2" {
+		t.Errorf("products = %v, want p1 and p2", ids)
+	}
+}
+
+// Продажи по всем товарам упёрлись в max_rows: строки товаров группы могли не войти — отказ с
+// просьбой сузить склады, а не рекомендации по неполным продажам.
+func TestReorderSuggestionsSalesTruncated(t *testing.T) {
+	h, fake := newTestHandler(t)
+	h.cfg.Limits.MaxRows = 2
+	now := h.onecClient.Now()
+	all := reorderFake(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	fake.respond = func(path string, body map[string]any) string {
+		if path == "/mcp/reports/product_details" {
+			return `{"products":[{"id":"p1","label":"Item p1"}]}`
+		}
+		return all(path, body)
+	}
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name":      ToolReorderSuggestions,
+		"arguments": map[string]any{"filters": map[string]any{"product_ids": []any{"g1"}}},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams || !strings.Contains(res.Error.Message, "filters.warehouse_ids") {
+		t.Errorf("error = %+v", res.Error)
+	}
+}
+
+// Состав группы берётся у product_details — без mcp:resolve фильтр по товарам недоступен.
+func TestReorderSuggestionsProductsNeedResolve(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = reorderFake(time.Now())
+
+	res := rpc(t, h, withScopes("mcp:report:stock", "mcp:report:sales"), "tools/call", map[string]any{
+		"name":      ToolReorderSuggestions,
+		"arguments": map[string]any{"filters": map[string]any{"product_ids": []any{"g1"}}},
+	})
+	var result CallToolResult
+	if err := json.Unmarshal(res.Result, &result); err != nil || !result.IsError ||
+		!strings.Contains(result.Content[0].Text, `requires scope "mcp:resolve"`) {
+		t.Errorf("result = %s", res.Result)
+	}
+	if fake.count() != 0 {
+		t.Errorf("1C calls = %d, want 0", fake.count())
+	}
+}
@@ -0,0 +1,192 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// resourceScheme — схема URI ресурсов: onec://{slug}/{каталог}. Slug в URI — та же база,
+// что и в пути /{slug}/mcp: ресурс чужой базы этим хендлером не читается.
+const resourceScheme = "onec"
+
+// catalogResource — небольшой стабильный справочник 1С, отдаваемый ресурсом целиком.
+// Читается тем же Resolve*, что и одноимённый resolve-инструмент, с пустым запросом, — а значит,
+// через тот же resolve-кэш и под тем же правом: scope берётся из ToolScopes[tool], чтобы
+// ресурс нельзя было открыть в обход закрытого инструмента.
+type catalogResource struct {
+	name        string
+	tool        string
+	description string
+	read        func(h *Handler, ctx context.Context, query string, limit int) (any, error)
+}
+
+var catalogResources = []catalogResource{
+	{
+		name:        "warehouses",
+		tool:        ToolResolveWarehouse,
+		description: "All warehouses of the database (id, label, code, archived, for_production). Production warehouses are included only for callers holding mcp:report:cost, exactly as in resolve_warehouse.",
+		read: func(h *Handler, ctx context.Context, query string, limit int) (any, error) {
+			return h.onecClient.ResolveWarehouse(ctx, query, limit)
+		},
+	},
+	{
+		name:        "sales_channels",
+		tool:        ToolResolveSalesChannel,
+		description: "The sales channel hierarchy (parent nodes and their children). Parent UUIDs aggregate all descendants in sales_report.filters.sales_channel_ids.",
+		read: func(h *Handler, ctx context.Context, query string, limit int) (any, error) {
+			return h.onecClient.ResolveSalesChannel(ctx, query, limit)
+		},
+	},
+	{
+		name:        "cash_desks",
+		tool:        ToolResolveCash,
+		description: "All cash desks (кассы) for cash_balance.filters.cash_ids and cash_flow.filters.cash_ids.",
+		read: func(h *Handler, ctx context.Context, query string, limit int) (any, error) {
+			return h.onecClient.ResolveCash(ctx, query, limit)
+		},
+	},
+	{
+		name:        "operations",
+		tool:        ToolResolveOperation,
+		description: "All cash-flow operation types (виды движения денег) for cash_flow.filters.operation_ids.",
+		read: func(h *Handler, ctx context.Context, query string, limit int) (any, error) {
+			return h.onecClient.ResolveOperation(ctx, query, limit)
+		},
+	},
+}
+
+func findCatalogResource(name string) (catalogResource, bool) {
+	for _, c := range catalogResources {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return catalogResource{}, false
+}
+
+func (h *Handler) resourceURI(name string) string {
+	return resourceScheme + "://" + h.tenant + "/" + name
+}
+
+// resourceAllowed — право на ресурс совпадает с правом на его resolve-инструмент.
+// Без OAuth (auth == nil) доступно всё, как и с инструментами.
+func resourceAllowed(auth *oauth.AuthInfo, c catalogResource) bool {
+	if auth == nil {
+		return true
+	}
+	required, ok := ToolScopes[c.tool]
+	return ok && auth.HasScope(required)
+}
+
+// handleResourcesList отдаёт справочники, доступные вызывающему. Фильтрация — как в
+// handleToolsList: недоступное не показывается вовсе.
+func (h *Handler) handleResourcesList(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	resources := make([]Resource, 0, len(catalogResources))
+	for _, c := range catalogResources {
+		if !resourceAllowed(auth, c) {
+			continue
+		}
+		resources = append(resources, Resource{
+			URI:         h.resourceURI(c.name),
+			Name:        c.name,
+			Description: c.description,
+			MimeType:    "application/json",
+		})
+	}
+
+	sub, cid := authIdentity(auth)
+	h.logger.Info("mcp.resource.list", "sub", sub, "client_id", cid, "count", len(resources))
+
+	return NewResponse(req.ID, ListResourcesResult{Resources: resources})
+}
+
+// handleResourceTemplatesList — те же справочники с поиском: onec://{slug}/warehouses{?query}
+// сужает выдачу так же, как query в resolve-инструменте. Удобно, когда справочник всё-таки
+// велик, а в контекст нужна только его часть.
+func (h *Handler) handleResourceTemplatesList(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	templates := make([]ResourceTemplate, 0, len(catalogResources))
+	for _, c := range catalogResources {
+		if !resourceAllowed(auth, c) {
+			continue
+		}
+		templates = append(templates, ResourceTemplate{
+			URITemplate: h.resourceURI(c.name) + "{?query}",
+			Name:        c.name + "_search",
+			Description: c.description + " Filtered by name or code when query is given.",
+			MimeType:    "application/json",
+		})
+	}
+
+	return NewResponse(req.ID, ListResourceTemplatesResult{ResourceTemplates: templates})
+}
+
+// handleResourcesRead читает справочник по URI. Недоступный по правам ресурс отвечает тем же
+// «Resource not found», что и несуществующий: его нет и в resources/list, и выдавать сам факт
+// существования незачем.
+func (h *Handler) handleResourcesRead(ctx context.Context, req Request) *Response {
+	auth := oauth.FromContext(ctx)
+
+	var params ReadResourceParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return InvalidParams(req.ID, "failed to parse params")
+	}
+
+	c, query, ok := h.parseResourceURI(params.URI)
+	if !ok {
+		return ResourceNotFound(req.ID, params.URI)
+	}
+
+	if !resourceAllowed(auth, c) {
+		sub, _ := authIdentity(auth)
+		h.logger.Warn("oauth.scope.denied",
+			"resource", c.name, "required", ToolScopes[c.tool], "sub", sub)
+		return ResourceNotFound(req.ID, params.URI)
+	}
+
+	// Справочник отдаётся целиком, поэтому лимит — max_rows, а не resolve_limit: последний
+	// рассчитан на список кандидатов для уточнения, а не на полный каталог.
+	resp, err := c.read(h, ctx, query, h.cfg.Limits.MaxRows)
+	if err != nil {
+		h.logger.Error("resource read failed", "resource", c.name, "error", err)
+		return InternalError(req.ID, err.Error())
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return InternalError(req.ID, err.Error())
+	}
+
+	sub, cid := authIdentity(auth)
+	h.logger.Info("mcp.resource.read", "sub", sub, "client_id", cid, "resource", c.name)
+
+	return NewResponse(req.ID, ReadResourceResult{
+		Contents: []ResourceContents{{
+			URI:      params.URI,
+			MimeType: "application/json",
+			Text:     string(data),
+		}},
+	})
+}
+
+// parseResourceURI разбирает onec://{slug}/{каталог}[?query=...]. Slug обязан совпадать с базой
+// хендлера: иначе это ресурс другой базы, и для этого хендлера его не существует.
+func (h *Handler) parseResourceURI(raw string) (catalogResource, string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != resourceScheme || u.Host != h.tenant {
+		return catalogResource{}, "", false
+	}
+
+	c, ok := findCatalogResource(strings.Trim(u.Path, "/"))
+	if !ok {
+		return catalogResource{}, "", false
+	}
+	return c, strings.TrimSpace(u.Query().Get("query")), true
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// rpcResult — ответ JSON-RPC с сырым result: тесты распаковывают его в нужный тип сами.
+type rpcResult struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func rpc(t *testing.T, h *Handler, ctx context.Context, method string, params any) rpcResult {
+	t.Helper()
+
+	msg := map[string]any{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	rec := postRPC(t, h, ctx, msg, nil)
+
+	var out rpcResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", rec.Body.String(), err)
+	}
+	return out
+}
+
+func withScopes(scopes ...string) context.Context {
+	return oauth.ContextWithAuth(context.Background(), &oauth.AuthInfo{Sub: "u", Scopes: scopes})
+}
+
+func TestResourcesListFollowsScopes(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	var list ListResourcesResult
+	if err := json.Unmarshal(rpc(t, h, withScopes("mcp:resolve"), "resources/list", nil).Result, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, r := range list.Resources {
+		got[r.URI] = true
+	}
+	if !got["onec://test/warehouses"] || !got["onec://test/sales_channels"] {
+		t.Errorf("resolve scope must see warehouses and sales_channels: %+v", list.Resources)
+	}
+	if got["onec://test/cash_desks"] || got["onec://test/operations"] {
+		t.Errorf("money catalogs leaked without mcp:report:money: %+v", list.Resources)
+	}
+
+	var templates ListResourceTemplatesResult
+	if err := json.Unmarshal(rpc(t, h, withScopes("mcp:report:money"), "resources/templates/list", nil).Result, &templates); err != nil {
+		t.Fatal(err)
+	}
+	if len(templates.ResourceTemplates) != 2 || templates.ResourceTemplates[0].URITemplate != "onec://test/cash_desks{?query}" {
+		t.Errorf("templates for money scope: %+v", templates.ResourceTemplates)
+	}
+}
+
+func TestResourcesReadUsesResolver(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = `{"candidates":[{"id":"w1","label":"Основной","archived":false}]}`
+
+	resp := rpc(t, h, withScopes("mcp:resolve"), "resources/read", map[string]any{"uri": "onec://test/warehouses"})
+	if resp.Error != nil {
+		t.Fatalf("read: %+v", resp.Error)
+	}
+
+	var read ReadResourceResult
+	if err := json.Unmarshal(resp.Result, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Contents) != 1 || read.Contents[0].MimeType != "application/json" {
+		t.Fatalf("contents: %+v", read.Contents)
+	}
+
+	got := fake.recorded(t, 0)
+	if got.path != "/mcp/resolve/warehouse" || got.body["query"] != "" || got.body["limit"] != float64(5000) {
+		t.Errorf("1C request: %+v", got)
+	}
+
+	// Повторное чтение — из resolve-кэша, в 1С не ходит.
+	rpc(t, h, withScopes("mcp:resolve"), "resources/read", map[string]any{"uri": "onec://test/warehouses"})
+	if fake.count() != 1 {
+		t.Errorf("second read hit 1C: %d requests", fake.count())
+	}
+
+	rpc(t, h, withScopes("mcp:resolve"), "resources/read", map[string]any{"uri": "onec://test/warehouses?query=Осн"})
+	if q := fake.recorded(t, 1).body["query"]; q != "Осн" {
+		t.Errorf("templated read sent query %v", q)
+	}
+}
+
+// Ресурс, закрытый правами, неотличим от несуществующего, как и ресурс чужой базы.
+func TestResourcesReadDenied(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	for _, uri := range []string{"onec://test/cash_desks", "onec://other/warehouses", "onec://test/nope", "http://test/warehouses"} {
+		resp := rpc(t, h, withScopes("mcp:resolve"), "resources/read", map[string]any{"uri": uri})
+		if resp.Error == nil || resp.Error.Code != CodeResourceNotFound {
+			t.Errorf("%s: want resource not found, got %+v", uri, resp)
+		}
+	}
+	if fake.count() != 0 {
+		t.Errorf("denied reads reached 1C: %d requests", fake.count())
+	}
+}
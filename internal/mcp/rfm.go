@@ -0,0 +1,246 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/onec"
+)
+
+// rfm_segments — RFM-сегментация клиентов за период: списки «под угрозой оттока» для B2B-продаж,
+// которые customer_summary (один клиент за вызов) дать не может. Гейт берёт sales_report по
+// клиенту и дню и считает по каждому клиенту:
+//
+//	R (recency)   — дней от последней покупки до period.to;
+//	F (frequency) — число документов продажи (receipts);
+//	M (monetary)  — сумма продаж (amount).
+//
+// Каждая величина переводится в квинтиль 1..5 по рангу среди клиентов выборки (5 — лучший: самая
+// свежая покупка, больше всего документов, больше всего денег); равные значения получают равный
+// балл. Сегмент — по сетке R × FM, где FM = округлённое среднее F и M (см. rfmSegment).
+//
+// Клиенты без документов продажи за период (только возвраты) в выборку не попадают.
+
+// rfmSegmentNames — сегменты в порядке сводки: от лучших к потерянным.
+var rfmSegmentNames = []string{
+	"champions", "loyal", "potential_loyalist", "new", "promising", "need_attention",
+	"about_to_sleep", "cant_lose", "at_risk", "hibernating", "lost",
+}
+
+type rfmArgs struct {
+	Period   onec.Period    `json:"period"`
+	Filters  map[string]any `json:"filters"`
+	Segments []string       `json:"segments"`
+	Top      flexInt        `json:"top"`
+}
+
+// rfmCustomer — клиент с его показателями и баллами.
+type rfmCustomer struct {
+	ref          any
+	lastPurchase string
+	recency      int
+	receipts     float64
+	amount       float64
+	r, f, m      int
+	segment      string
+}
+
+func (h *Handler) callRFMSegments(ctx context.Context, args any) (*CallToolResult, error) {
+	var a rfmArgs
+	if err := mapToStruct(args, &a); err != nil {
+		return nil, err
+	}
+
+	asOf, errTo := time.Parse(time.DateOnly, a.Period.To)
+	from, errFrom := time.Parse(time.DateOnly, a.Period.From)
+	if errFrom != nil || errTo != nil || asOf.Before(from) {
+		return nil, argumentErrors{{Path: "period", Message: "expected from <= to as YYYY-MM-DD"}}
+	}
+
+	inner := map[string]any{
+		"period":   map[string]any{"from": a.Period.From, "to": a.Period.To},
+		"group_by": []any{"customer", "day"},
+		"measures": []any{"amount", "receipts"},
+	}
+	if len(a.Filters) > 0 {
+		inner["filters"] = a.Filters
+	}
+	if err := h.checkReportCall(ctx, ToolSalesReport, inner); err != nil {
+		return nil, err
+	}
+	report, err := h.fetchReport(ctx, ToolSalesReport, inner)
+	if err != nil {
+		return nil, err
+	}
+
+	var customers []*rfmCustomer
+	byKey := map[string]*rfmCustomer{}
+	for _, row := range report.rows {
+		ref := report.cell(row, "customer")
+		key := rowKey([]any{ref}, []int{0})
+		c, ok := byKey[key]
+		if !ok {
+			c = &rfmCustomer{ref: ref}
+			byKey[key] = c
+			customers = append(customers, c)
+		}
+		receipts := numberValue(report.cell(row, "receipts"))
+		c.receipts += receipts
+		c.amount += numberValue(report.cell(row, "amount"))
+		if day := firstN(cellText(report.cell(row, "day")), 10); receipts > 0 && day > c.lastPurchase {
+			c.lastPurchase = day
+		}
+	}
+	customers = slices.DeleteFunc(customers, func(c *rfmCustomer) bool { return c.lastPurchase == "" })
+
+	for _, c := range customers {
+		last, _ := time.Parse(time.DateOnly, c.lastPurchase)
+		c.recency = int(asOf.Sub(last).Hours() / 24)
+	}
+	// Меньше дней с покупки — лучше, поэтому recency ранжируется с обратным знаком.
+	quintiles(customers, func(c *rfmCustomer) float64 { return -float64(c.recency) }, func(c *rfmCustomer, s int) { c.r = s })
+	quintiles(customers, func(c *rfmCustomer) float64 { return c.receipts }, func(c *rfmCustomer, s int) { c.f = s })
+	quintiles(customers, func(c *rfmCustomer) float64 { return c.amount }, func(c *rfmCustomer, s int) { c.m = s })
+	for _, c := range customers {
+		c.segment = rfmSegment(c.r, int(math.Round(float64(c.f+c.m)/2)))
+	}
+
+	sort.SliceStable(customers, func(i, j int) bool {
+		if customers[i].amount != customers[j].amount {
+			return customers[i].amount > customers[j].amount
+		}
+		return strings.ToLower(cellText(customers[i].ref)) < strings.ToLower(cellText(customers[j].ref))
+	})
+
+	summary := rfmSummary(customers)
+
+	var rows [][]any
+	for _, c := range customers {
+		if len(a.Segments) > 0 && !containsString(a.Segments, c.segment) {
+			continue
+		}
+		rows = append(rows, []any{
+			c.ref, c.lastPurchase, c.recency, roundTo(c.receipts, 0), roundTo(c.amount, 2),
+			c.r, c.f, c.m, fmt.Sprintf("%d%d%d", c.r, c.f, c.m), c.segment,
+		})
+	}
+	matched := len(rows)
+	if top := int(a.Top); top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	var columns []map[string]any
+	for _, col := range []struct{ name, typ string }{
+		{"customer", "ref"}, {"last_purchase", "date"}, {"recency_days", "number"}, {"receipts", "number"},
+		{"amount", "number"}, {"r", "number"}, {"f", "number"}, {"m", "number"}, {"rfm", "string"}, {"segment", "string"},
+	} {
+		columns = append(columns, map[string]any{"name": col.name, "type": col.typ})
+	}
+
+	resp := map[string]any{
+		"columns": columns,
+		"rows":    rows,
+		"totals": map[string]any{
+			"customers": matched,
+		},
+		"summary": summary,
+		"period":  a.Period,
+		"as_of":   a.Period.To,
+	}
+	if filters, ok := report.fields["applied_filters"]; ok {
+		resp["applied_filters"] = filters
+	}
+	// Срез «клиент × день» упёрся в max_rows — часть клиентов или их покупок не учтена.
+	if len(report.rows) >= h.cfg.Limits.MaxRows {
+		resp["truncated"] = true
+	}
+	return toolResult(resp)
+}
+
+// quintiles проставляет балл 1..5 по рангу value среди клиентов (5 — наибольшее значение).
+// Равные значения получают балл первого из них, чтобы одинаковые клиенты не разъезжались
+// по соседним квинтилям из-за порядка строк.
+func quintiles(customers []*rfmCustomer, value func(*rfmCustomer) float64, set func(*rfmCustomer, int)) {
+	sorted := append([]*rfmCustomer(nil), customers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return value(sorted[i]) < value(sorted[j]) })
+
+	n := len(sorted)
+	score := 0
+	for i, c := range sorted {
+		if i == 0 || value(c) != value(sorted[i-1]) {
+			score = 1 + i*5/n
+		}
+		set(c, score)
+	}
+}
+
+// rfmSegment — сегмент по сетке R × FM (классическая RFM-сетка, у R 1 и 2 разведены потерянные
+// и спящие):
+//
+//	FM →   1                2                   3                   4           5
+//	R 5    new              potential_loyalist  potential_loyalist  champions   champions
+//	R 4    promising        potential_loyalist  potential_loyalist  loyal       loyal
+//	R 3    about_to_sleep   about_to_sleep      need_attention      loyal       loyal
+//	R 2    hibernating      hibernating         at_risk             at_risk     cant_lose
+//	R 1    lost             lost                at_risk             at_risk     cant_lose
+func rfmSegment(r, fm int) string {
+	switch {
+	case r >= 3 && fm >= 4:
+		if r == 5 {
+			return "champions"
+		}
+		return "loyal"
+	case r <= 2 && fm == 5:
+		return "cant_lose"
+	case r <= 2 && fm >= 3:
+		return "at_risk"
+	case r == 2:
+		return "hibernating"
+	case r == 1:
+		return "lost"
+	case r == 3 && fm == 3:
+		return "need_attention"
+	case r == 3:
+		return "about_to_sleep"
+	case fm == 1 && r == 5:
+		return "new"
+	case fm == 1:
+		return "promising"
+	}
+	return "potential_loyalist"
+}
+
+// rfmSummary — число клиентов и выручка по каждому сегменту, все сегменты в фиксированном порядке.
+func rfmSummary(customers []*rfmCustomer) map[string]any {
+	count := map[string]int{}
+	amount := map[string]float64{}
+	var total float64
+	for _, c := range customers {
+		count[c.segment]++
+		amount[c.segment] += c.amount
+		total += c.amount
+	}
+
+	rows := make([][]any, 0, len(rfmSegmentNames))
+	for _, s := range rfmSegmentNames {
+		var share any
+		if total != 0 {
+			share = roundTo(amount[s]/total*100, 2)
+		}
+		rows = append(rows, []any{s, count[s], roundTo(amount[s], 2), share})
+	}
+	return map[string]any{
+		"columns": []map[string]any{
+			{"name": "segment", "type": "string"},
+			{"name": "customers", "type": "number"},
+			{"name": "amount", "type": "number"},
+			{"name": "share_pct", "type": "number"},
+		},
+		"rows": rows,
+	}
+}
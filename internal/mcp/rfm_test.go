@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// rfmFake — пять клиентов с разными R/F/M и шестой, у которого за период только возврат.
+func rfmFake(path string, body map[string]any) string {
+	sales := []struct {
+		id, day          string
+		receipts, amount float64
+	}{
+		{"c1", "2026-03-01", 10, 2000},
+		{"c1", "2026-06-29", 10, 3000},
+		{"c2", "2026-06-20", 15, 4000},
+		{"c3", "2026-05-01", 1, 100},
+		{"c4", "2026-04-01", 10, 3000},
+		{"c5", "2026-01-15", 5, 500},
+		{"c6", "2026-06-01", 0, -50},
+	}
+	rows := make([][]any, len(sales))
+	for i, s := range sales {
+		rows[i] = []any{refJSON(s.id, "Customer "+s.id), s.day, s.amount, s.receipts}
+	}
+	return reportJSON([]string{"customer:ref", "day:date", "amount:number", "receipts:number"}, rows)
+}
+
+func TestRFMSegments(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.respond = rfmFake
+
+	res := callTool(t, h, ToolRFMSegments, map[string]any{
+		"period":   map[string]any{"from": "2026-01-01", "to": "2026-06-30"},
+		"filters":  map[string]any{"sales_channel_ids": []any{"b2b"}},
+		"segments": []any{"at_risk", "lost"},
+	})
+	if res.IsError {
+		t.Fatalf("tool error: %s", resultText(t, res))
+	}
+
+	body := fake.recorded(t, 0).body
+	if groupBy, _ := json.Marshal(body["group_by"]); string(groupBy) != `["customer","day"]` {
+		t.Errorf("group_by = %s", groupBy)
+	}
+	if filters, _ := body["filters"].(map[string]any); filters["sales_channel_ids"] == nil {
+		t.Errorf("filters not forwarded: %v", body["filters"])
+	}
+
+	var got struct {
+		Rows    [][]any
+		Summary struct{ Rows [][]any }
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// c4: давно (R2), но средне по частоте и деньгам — at_risk; c5: давно и мало — lost.
+	if len(got.Rows) != 2 {
+		t.Fatalf("rows = %v", got.Rows)
+	}
+	c4, c5 := got.Rows[0], got.Rows[1]
+	if id, _, _ := refCell(c4[0]); id != "c4" || c4[2] != 90.0 || c4[8] != "233" || c4[9] != "at_risk" {
+		t.Errorf("c4 = %v", c4)
+	}
+	if id, _, _ := refCell(c5[0]); id != "c5" || c5[8] != "122" || c5[9] != "lost" {
+		t.Errorf("c5 = %v", c5)
+	}
+
+	// Сводка — по всем клиентам (c6 без продаж не в счёт), независимо от segments.
+	counts := map[string]float64{}
+	var total float64
+	for _, row := range got.Summary.Rows {
+		counts[fmt.Sprint(row[0])] = row[1].(float64)
+		total += row[1].(float64)
+	}
+	if total != 5 || counts["champions"] != 1 || counts["loyal"] != 1 || counts["about_to_sleep"] != 1 {
+		t.Errorf("summary = %v", got.Summary.Rows)
+	}
+}
+
+func TestQuintilesTies(t *testing.T) {
+	customers := []*rfmCustomer{{amount: 10}, {amount: 10}, {amount: 10}, {amount: 50}, {amount: 90}}
+	quintiles(customers, func(c *rfmCustomer) float64 { return c.amount }, func(c *rfmCustomer, s int) { c.m = s })
+
+	var got []int
+	for _, c := range customers {
+		got = append(got, c.m)
+	}
+	if fmt.Sprint(got) != "[1 1 1 4 5]" {
+		t.Errorf("scores = %v", got)
+	}
+}
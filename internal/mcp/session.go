@@ -0,0 +1,251 @@
+package mcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SessionHeader — заголовок Streamable HTTP, которым сервер выдаёт идентификатор сессии в ответе
+// на initialize, а клиент возвращает его во всех последующих запросах (POST, GET, DELETE).
+const SessionHeader = "Mcp-Session-Id"
+
+// sessionIdleTTL — сколько живёт сессия без запросов. Открытый GET-поток сессию не держит вечно
+// сам по себе, но keep-alive на нём обновляет lastSeen: пока клиент слушает, сессия жива.
+const sessionIdleTTL = 30 * time.Minute
+
+// sessionStreamBuffer — сколько серверных сообщений ждёт в очереди GET-потока. Поток читает
+// живой клиент, поэтому очередь почти всегда пуста; переполнение означает зависшего читателя,
+// и тогда сообщение лучше потерять, чем заблокировать отправителя (см. Session.Notify).
+const sessionStreamBuffer = 64
+
+// Sessions — реестр MCP-сессий. Один на весь гейт, а не на базу: Registry.Reload пересобирает
+// обвязки всех баз на каждую правку в /admin, и если бы сессии жили в Handler, каждая такая
+// правка рвала бы все открытые подключения — клиентам пришлось бы заново проходить initialize.
+// Изоляция между базами — по полю Session.Tenant, проверяемому в Handler.lookupSession.
+type Sessions struct {
+	mu   sync.Mutex
+	byID map[string]*Session
+	ttl  time.Duration
+
+	// calls — выполняющиеся запросы для notifications/cancelled, в том числе вне сессий.
+	calls inflight
+
+	// pages — сохранённые результаты постраничных отчётов (см. pages.go).
+	pages pageCache
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{
+		byID: make(map[string]*Session),
+		ttl:  sessionIdleTTL,
+	}
+}
+
+// Session — состояние одного подключения MCP-клиента. Tenant и Sub фиксируются при initialize:
+// идентификатор сессии не секрет (он ходит в заголовках и логах прокси), поэтому чужой
+// пользователь или другая база по нему ничего не получат — только 404.
+type Session struct {
+	ID              string
+	Tenant          string
+	Sub             string
+	ProtocolVersion string
+
+	// logLevel — порог notifications/message, заданный logging/setLevel.
+	logLevel clientLogLevel
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	// scopes — права (scopeSet), с которыми пришёл последний запрос сессии. Токен за время
+	// сессии обновляется, и новый может нести другой набор прав — а с ним и другой tools/list.
+	scopes     string
+	scopesSeen bool
+	// stream — очередь открытого GET-потока (nil, когда клиент его не держит). Одновременно
+	// поток у сессии один: новый GET вытесняет старый, иначе сообщения делились бы между двумя
+	// читателями случайным образом.
+	stream chan []byte
+}
+
+// Create заводит сессию и попутно вычищает протухшие: сессий на гейт — единицы-десятки,
+// поэтому полный обход дешевле отдельной фоновой горутины с её остановкой.
+func (s *Sessions) Create(tenant, sub, protocolVersion string) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &Session{
+		ID:              id,
+		Tenant:          tenant,
+		Sub:             sub,
+		ProtocolVersion: protocolVersion,
+		lastSeen:        time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	s.byID[id] = sess
+	return sess, nil
+}
+
+// Get — сессия по идентификатору с продлением срока жизни. Протухшая сессия удаляется и не
+// возвращается: клиент получит 404 и, по спецификации, начнёт новую через initialize.
+func (s *Sessions) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	if sess.expired(s.ttl) {
+		delete(s.byID, id)
+		sess.closeStream()
+		return nil, false
+	}
+	sess.touch()
+	return sess, true
+}
+
+// Delete завершает сессию (DELETE от клиента). Открытый GET-поток закрывается вместе с ней.
+func (s *Sessions) Delete(id string) bool {
+	s.mu.Lock()
+	sess, ok := s.byID[id]
+	delete(s.byID, id)
+	s.mu.Unlock()
+
+	if ok {
+		sess.closeStream()
+	}
+	return ok
+}
+
+// NotifyToolsListChanged рассылает notifications/tools/list_changed во все сессии: вызывается
+// после Registry.Reload, который пересобирает обвязки всех баз разом. Возвращает число сессий,
+// до GET-потока которых уведомление дошло.
+func (s *Sessions) NotifyToolsListChanged() int {
+	s.mu.Lock()
+	sessions := make([]*Session, 0, len(s.byID))
+	for _, sess := range s.byID {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	sent := 0
+	for _, sess := range sessions {
+		if sess.Notify("notifications/tools/list_changed", nil) {
+			sent++
+		}
+	}
+	return sent
+}
+
+func (s *Sessions) sweepLocked() {
+	for id, sess := range s.byID {
+		if sess.expired(s.ttl) {
+			delete(s.byID, id)
+			sess.closeStream()
+		}
+	}
+}
+
+// Notify отправляет серверное уведомление в GET-поток сессии. false — потока нет или очередь
+// переполнена: уведомления MCP не требуют доставки, и блокировать отправителя из-за медленного
+// читателя хуже, чем потерять одно сообщение.
+func (sess *Session) Notify(method string, params any) bool {
+	data, err := encodeMessage(NewNotification(method, params))
+	if err != nil {
+		return false
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.stream == nil {
+		return false
+	}
+	select {
+	case sess.stream <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// attachStream открывает очередь GET-потока, вытесняя предыдущую. Возвращённый канал
+// закрывается, когда поток вытеснен или сессия завершена, — по этому признаку читатель
+// понимает, что ему пора отключаться.
+func (sess *Session) attachStream() chan []byte {
+	ch := make(chan []byte, sessionStreamBuffer)
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.stream != nil {
+		close(sess.stream)
+	}
+	sess.stream = ch
+	sess.lastSeen = time.Now()
+	return ch
+}
+
+// detachStream снимает очередь, если она всё ещё текущая: вытесненный поток не должен
+// закрыть очередь того, кто его вытеснил.
+func (sess *Session) detachStream(ch chan []byte) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.stream == ch {
+		close(ch)
+		sess.stream = nil
+	}
+}
+
+func (sess *Session) closeStream() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.stream != nil {
+		close(sess.stream)
+		sess.stream = nil
+	}
+}
+
+// observeScopes запоминает права очередного запроса и сообщает, изменились ли они с прошлого.
+// Первое наблюдение (при initialize) изменением не считается.
+func (sess *Session) observeScopes(scopes string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	changed := sess.scopesSeen && sess.scopes != scopes
+	sess.scopes = scopes
+	sess.scopesSeen = true
+	return changed
+}
+
+func (sess *Session) touch() {
+	sess.mu.Lock()
+	sess.lastSeen = time.Now()
+	sess.mu.Unlock()
+}
+
+// expired — сессия простаивает дольше ttl. Пока открыт GET-поток, сессия не протухает:
+// keep-alive на нём и есть признак живого клиента.
+func (sess *Session) expired(ttl time.Duration) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.stream == nil && time.Since(sess.lastSeen) > ttl
+}
+
+// newSessionID — 128 бит случайности в hex: спецификация требует глобально уникальный и
+// криптографически стойкий идентификатор из видимых ASCII-символов.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,161 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// stdioMaxMessage — предел одной строки stdin. bufio.Scanner по умолчанию режет на 64 КБ,
+// а батч из десятка вызовов с длинными списками UUID в фильтрах в это не влезает.
+const stdioMaxMessage = 8 << 20
+
+// ServeStdio — транспорт stdio: JSON-RPC сообщения по одному на строку (newline-delimited JSON)
+// из in, ответы и уведомления — так же построчно в out. Так гейт подключается к desktop
+// MCP-клиенту дочерним процессом, без HTTP и без OAuth.
+//
+// Аутентификации здесь нет: кто запустил процесс, тот уже имеет доступ к конфигу и SQLite.
+// Права вызывающего задаёт ctx (oauth.ContextWithAuth) — без AuthInfo работает как
+// статический токен, то есть без ограничений по scope.
+//
+// Запросы выполняются параллельно, иначе notifications/cancelled, пришедшее следом за долгим
+// отчётом, дождалось бы его конца и ничего бы не отменило. Возврат — по EOF на in, после
+// завершения всех начатых запросов.
+func (h *Handler) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	w := &stdioWriter{w: out}
+	ctx = withCallContext(ctx, callContext{
+		// Процесс обслуживает ровно одного клиента: вся сессия — это и есть stdio.
+		scope: "stdio",
+		notify: func(method string, params any) {
+			w.write(NewNotification(method, params))
+		},
+		logLevel: &clientLogLevel{},
+	})
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64<<10), stdioMaxMessage)
+
+	// Ревизия протокола, согласованная в initialize. Процесс — это и есть сессия, поэтому она
+	// хранится здесь, а не в Sessions.
+	var proto stdioProtocol
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		// Scanner переиспользует буфер под следующую строку, а сообщение уходит в горутину.
+		msg := append([]byte(nil), line...)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := h.handleStdioMessage(withProtocol(ctx, proto.get()), msg); resp != nil {
+				proto.remember(resp)
+				w.write(resp)
+			}
+		}()
+	}
+
+	return scanner.Err()
+}
+
+// handleStdioMessage обрабатывает одну строку и возвращает то, что надо записать в ответ
+// (Response или массив ответов батча), либо nil, если отвечать нечего.
+func (h *Handler) handleStdioMessage(ctx context.Context, msg []byte) any {
+	if isBatch(msg) {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(msg, &raw); err != nil {
+			return ParseError(nil)
+		}
+		if len(raw) == 0 {
+			return InvalidRequest(nil)
+		}
+
+		b := h.parseBatch(ctx, raw)
+		if b.empty() {
+			return nil
+		}
+		if out := h.runBatch(ctx, b); len(out) > 0 {
+			return out
+		}
+		return nil
+	}
+
+	var req Request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return ParseError(nil)
+	}
+	if req.JSONRPC != JSONRPCVersion {
+		return InvalidRequest(req.ID)
+	}
+
+	if req.ID == nil {
+		h.handleNotification(ctx, req)
+		return nil
+	}
+
+	h.logger.Info("mcp request", "method", req.Method, "id", req.ID, "transport", "stdio")
+
+	if resp := h.dispatch(ctx, req); resp != nil {
+		return resp
+	}
+	return nil
+}
+
+// stdioWriter сериализует запись в out: ответы параллельных запросов и уведомления не должны
+// перемешаться внутри строки.
+type stdioWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *stdioWriter) write(msg any) {
+	data, err := encodeMessage(msg)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.w.Write(append(data, '\n'))
+}
+
+// stdioProtocol — ревизия протокола stdio-соединения. До initialize пусто, и запросы идут
+// по defaultProtocolVersion.
+type stdioProtocol struct {
+	mu      sync.Mutex
+	version string
+}
+
+func (p *stdioProtocol) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.version
+}
+
+// remember запоминает версию из ответа на initialize — одиночного или внутри батча.
+func (p *stdioProtocol) remember(msg any) {
+	var responses []*Response
+	switch m := msg.(type) {
+	case *Response:
+		responses = []*Response{m}
+	case []*Response:
+		responses = m
+	}
+
+	for _, resp := range responses {
+		if result, ok := resp.Result.(InitializeResult); ok {
+			p.mu.Lock()
+			p.version = result.ProtocolVersion
+			p.mu.Unlock()
+		}
+	}
+}
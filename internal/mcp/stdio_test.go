@@ -0,0 +1,83 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Stdio: по строке на сообщение в обе стороны, уведомления без ответа, батч — одной строкой.
+func TestServeStdioFramesMessagesByLine(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`[{"jsonrpc":"2.0","id":2,"method":"tools/list"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`,
+		`not json`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	if err := h.ServeStdio(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatalf("ServeStdio: %v", err)
+	}
+
+	// Запросы выполняются параллельно, порядок строк не гарантирован — раскладываем по форме.
+	var single []Response
+	var batches [][]Response
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, stdioMaxMessage) // tools/list одной строкой длиннее 64 КБ
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if isBatch(line) {
+			var b []Response
+			if err := json.Unmarshal(line, &b); err != nil {
+				t.Fatalf("bad batch line %q: %v", line, err)
+			}
+			batches = append(batches, b)
+			continue
+		}
+		var r Response
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		single = append(single, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 2 || len(batches) != 1 {
+		t.Fatalf("got %d single and %d batch lines, want 2 and 1", len(single), len(batches))
+	}
+
+	var sawInit, sawParseError bool
+	for _, r := range single {
+		switch {
+		case r.Error != nil && r.Error.Code == CodeParseError:
+			sawParseError = true
+		case r.Error == nil && string(mustJSON(t, r.ID)) == "1":
+			sawInit = true
+		}
+	}
+	if !sawInit || !sawParseError {
+		t.Errorf("missing initialize result or parse error: %+v", single)
+	}
+
+	if len(batches[0]) != 1 || string(mustJSON(t, batches[0][0].ID)) != "2" || batches[0][0].Error != nil {
+		t.Errorf("batch answer = %+v, want only tools/list result", batches[0])
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
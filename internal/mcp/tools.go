@@ -35,6 +35,14 @@ const (
 	ToolProductionOutput         = "production_output"
 	ToolProductionConsumption    = "production_consumption"
 	ToolProductionDocumentDetail = "production_document"
+
+	// Аналитика поверх отчётов: гейт вызывает табличные отчёты сам и считает по их ответам.
+	ToolComparePeriods      = "compare_periods"
+	ToolCashConversionCycle = "cash_conversion_cycle"
+	ToolABCXYZAnalysis      = "abc_xyz_analysis"
+	ToolReorderSuggestions  = "reorder_suggestions"
+	ToolDetectAnomalies     = "detect_anomalies"
+	ToolRFMSegments         = "rfm_segments"
 )
 
 // ScopeReportCost — доступ к себестоимости. Исторически это measure-level право (меры
@@ -99,12 +107,43 @@ var ToolScopes = map[string]string{
 	ToolProductionOutput:         ScopeReportCost,
 	ToolProductionConsumption:    ScopeReportCost,
 	ToolProductionDocumentDetail: ScopeReportCost,
+	// Сравнение периодов видно с правом на продажи — основной случай; вложенный отчёт
+	// (purchases_report, cash_flow) при вызове требует ещё и своего scope (см. composite.go).
+	ToolComparePeriods: "mcp:report:sales",
+	// Цикл оборота денег — деньги (ДЗ, КЗ, закупки), себестоимость, продажи и остатки: остальные
+	// права — в toolExtraScopes.
+	ToolCashConversionCycle: "mcp:report:money",
+	// ABC/XYZ — срез sales_report; measure=profit дополнительно требует mcp:report:cost.
+	ToolABCXYZAnalysis: "mcp:report:sales",
+	// Рекомендации к заказу — остатки и товары в пути плюс скорость продаж: право на продажи —
+	// в toolExtraScopes.
+	ToolReorderSuggestions: "mcp:report:stock",
+	// Аномалии ищутся по продажам или по деньгам: инструмент виден с любым из двух прав
+	// (toolAnyScopes), право на выбранный отчёт проверяется при вызове (см. composite.go).
+	ToolDetectAnomalies: "mcp:report:sales",
+	ToolRFMSegments:     "mcp:report:sales",
+}
+
+// toolExtraScopes — права, которые инструмент требует сверх ToolScopes: для инструментов, данные
+// которых лежат в нескольких контурах сразу. Без любого из них инструмент не виден в tools/list
+// и отклоняется на tools/call так же, как без основного scope.
+var toolExtraScopes = map[string][]string{
+	ToolCashConversionCycle: {ScopeReportCost, "mcp:report:sales", "mcp:report:stock"},
+	ToolReorderSuggestions:  {"mcp:report:sales"},
+}
+
+// toolAnyScopes — инструменты, которым вместо ToolScopes хватает любого из перечисленных прав:
+// составные инструменты, которые считают по отчёту на выбор из разных контуров. Право на
+// выбранный отчёт проверяет checkReportCall.
+var toolAnyScopes = map[string][]string{
+	ToolDetectAnomalies: {"mcp:report:sales", "mcp:report:money"},
 }
 
 func GetTools() []Tool {
-	return []Tool{
+	return withNameFilters([]Tool{
 		{
 			Name:        ToolResolveCustomer,
+			Title:       "Find customer",
 			Description: "Search customers by name, phone, or other identifying information. Returns a list of matching candidates for disambiguation. Set include_groups=true to also search the customer catalog GROUPS (folders) — UUIDs of groups can be passed to sales_report.filters.customer_ids and will be applied via IN HIERARCHY (matches all customers within the group).",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -124,9 +163,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"phone": stringProp(), "city": stringProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveWarehouse,
+			Title:       "Find warehouse",
 			Description: "Search warehouses by name or code. Returns a list of matching candidates for disambiguation. Each candidate carries for_production (bool): a production warehouse — where materials are written off and finished goods are received. Those are returned only to callers holding the mcp:report:cost permission; without it they do not exist as far as this tool is concerned. Their UUIDs are accepted by production_output / production_consumption / production_document and (with the same permission) by stock_balance and availability_report.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -142,9 +184,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"code": stringProp(), "for_production": boolProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveMaterial,
+			Title:       "Find material",
 			Description: "Search raw materials and components (сырьё, комплектующие, тара, этикетки) by name or article. This is the counterpart of resolve_product: the two split the same 1C item catalog and never overlap — resolve_product returns goods for sale, resolve_material returns items flagged as production-only, which resolve_product will never find. Returns id, label, code (артикул) and unit (the unit rates are expressed in). Feed the id into specification_where_used (which products consume this material), specification_explode, product_specification or production_consumption. Requires the mcp:report:cost permission.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -164,9 +209,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"code": stringProp(), "unit": stringProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveProduct,
+			Title:       "Find product",
 			Description: "Search products by name or code (артикул). Returns a list of matching candidates for disambiguation. Pass a UUID directly to look up a known product. Set include_groups=true to also search the product catalog GROUPS (товарные группы) — UUIDs of groups can be passed to stock_balance.filters.product_ids or sales_report (via top_products) and will be applied via IN HIERARCHY (matches all products within the group). Each candidate also carries lifecycle fields: status {code,label} (new|active|phasing_out|excluded), status_changed_at (date), markets ([UA|EU|OTHER]) and eu_certification {code,label} (certified|in_process|not_required) — use them to tell an expected sales drop (product being phased out / withdrawn from a market) from an anomaly worth investigating.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -186,9 +234,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"code": stringProp(), "status": codeLabelProp(), "status_changed_at": stringProp(), "markets": arrayOf(stringProp()), "eu_certification": codeLabelProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolProductDetails,
+			Title:       "Product status details",
 			Description: "Batch-fetch lifecycle/status attributes for a list of products or product GROUPS in one call — use it to enrich many SKUs at once (e.g. a whole category) instead of calling resolve_product per item. product_ids accepts leaf product UUIDs and group UUIDs (from resolve_product with include_groups=true), expanded via IN HIERARCHY; up to 500 products are returned. For each product returns id, label, code, group {id,label}, status {code,label} (new|active|phasing_out|excluded), status_changed_at (date), markets ([UA|EU|OTHER]) and eu_certification {code,label} (certified|in_process|not_required). Use it in the weekly category report to classify each SKU's sales drop as expected (being phased out / withdrawn from a market) vs an anomaly.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -206,9 +257,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"product_ids"},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"products": arrayOf(objectProp())}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveSalesChannel,
+			Title:       "Find sales channel",
 			Description: "Search sales channels by name. The catalog is hierarchical: returns both parent nodes (e.g. 'B2B', 'B2C') and their children (e.g. 'B2B Online', 'B2B Offline'). Pass a parent node UUID into sales_report.filters.sales_channel_ids to aggregate over all descendants (filter is applied via IN HIERARCHY), or a leaf UUID for a single channel.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -224,30 +278,21 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(nil),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolSalesReport,
+			Title:       "Sales report",
 			Description: "Get sales report from the «РеализацияТоваров» register for a specified period. By default groups by warehouse and customer and returns amount and qty. Filters: customer_ids (accepts both leaf customer UUIDs and customer-group UUIDs — applied via IN HIERARCHY), warehouse_ids, sales_channel_ids (accepts both leaf channel UUIDs and parent-node UUIDs like 'B2B'/'B2C' — applied via IN HIERARCHY, captures all descendants), customer_cohort ('new' | 'returning'). Dimensions (group_by): warehouse, customer, product, seller, sales_channel, day, week, month, cohort, product_group, customer_group (cohort = 'new'/'returning'; day/week/month return ISO date strings 'YYYY-MM-DD'; product_group / customer_group aggregate by parent group of the hierarchical catalog — товарная группа / группа контрагентов). Measures: amount, qty, receipts (number of sales documents), avg_check (amount / receipts), customers (COUNT DISTINCT customer), and — for users with the mcp:report:cost permission — cost (purchase cost), profit (amount - cost), margin (profit / amount, percent). customer_cohort='new'|'returning' restricts the sample (new = customer ДатаСоздания within the calendar month preceding the period start). To compare new vs returning side-by-side use group_by=['cohort'] instead of the cohort filter. Reference cells in rows come back as {id,label} objects (no extra resolve call needed). Response also includes period {from,to} and applied_filters (customers, warehouses, sales_channels, customer_cohort, new_since). Use group_by to pick dimensions, measures to pick metrics, top to limit rows, and sort to order results. sort.field must be one of the selected group_by dimensions or measures (otherwise the entry is ignored).",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Report period",
-						"properties": map[string]any{
-							"from": map[string]any{
-								"type":        "string",
-								"format":      "date",
-								"description": "Start date (YYYY-MM-DD)",
-							},
-							"to": map[string]any{
-								"type":        "string",
-								"format":      "date",
-								"description": "End date (YYYY-MM-DD)",
-							},
-						},
-						"required": []string{"from", "to"},
-					},
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"cursor":        cursorProp(),
+					"period":        periodProp("Report period"),
 					"filters": map[string]any{
 						"type":        "object",
 						"description": "Optional filters",
@@ -307,22 +352,17 @@ func GetTools() []Tool {
 				},
 				"required": []string{"period"},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolTopProducts,
+			Title:       "Top products",
 			Description: "Get top-N best-selling products for a period. Thin wrapper over sales_report grouped by product and sorted by the selected metric. Use this instead of sales_report when the user asks 'top products', 'bestsellers', 'what sold most' — the tool name is a strong hint for LLM tool selection.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Report period",
-						"properties": map[string]any{
-							"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-							"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-						},
-						"required": []string{"from", "to"},
-					},
+					"period": periodProp("Report period"),
 					"filters": map[string]any{
 						"type":        "object",
 						"description": "Optional filters",
@@ -343,9 +383,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"period"},
 			},
+			OutputSchema: passthroughOutput(nil),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolCustomerSummary,
+			Title:       "Customer summary",
 			Description: "Get a summary card for a single customer over a period: total amount, qty, number of receipts, average check, last purchase date, and top-N most bought products. For users with the mcp:report:cost permission, totals also include cost (purchase cost), profit (amount - cost) and margin (profit / amount, percent). Replaces 3-4 sequential sales_report calls with one. Use when the user asks about a specific customer (e.g. 'how much did X buy', 'tell me about customer Y').",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -354,15 +397,7 @@ func GetTools() []Tool {
 						"type":        "string",
 						"description": "Customer UUID (from resolve_customer)",
 					},
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Period for the summary",
-						"properties": map[string]any{
-							"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-							"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-						},
-						"required": []string{"from", "to"},
-					},
+					"period": periodProp("Period for the summary"),
 					"top_products": map[string]any{
 						"type":        "integer",
 						"description": "How many top products to include (default: 5)",
@@ -370,13 +405,20 @@ func GetTools() []Tool {
 				},
 				"required": []string{"customer_id", "period"},
 			},
+			OutputSchema: passthroughOutput(nil),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolStockBalance,
+			Title:       "Stock balance",
 			Description: "Get product stock balance from the «ОстаткиТоваров» register as of a given date. By default groups by both warehouse and product and returns the qty measure. Use group_by to pick dimensions (warehouse, product, product_group), measures to pick metrics (qty, amount), top to limit rows, and sort to order (sort.field must be one of the selected group_by dimensions or measures). Use product_group to aggregate by parent group of the hierarchical product catalog (товарная группа), useful for answering questions about totals per group rather than per item. Do not combine product with product_group — the group column would be fully determined by the leaf; the server silently drops the redundant product_group in that case. Coverage depends on permissions: by default the report shows goods for sale on trading warehouses only. With the mcp:report:cost permission it also covers the production side — raw materials and components (see resolve_material) on production warehouses (see resolve_warehouse.for_production).",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"cursor":        cursorProp(),
 					"date": map[string]any{
 						"type":        "string",
 						"format":      "date",
@@ -430,22 +472,20 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolAvailabilityReport,
+			Title:       "Stock availability (stockout days)",
 			Description: "Get product availability (out-of-stock days) over a period from the daily stock register. For each SKU/group × warehouse (and optionally per week) returns oos_days (days out of stock), days (calendar days in the period), availability_pct (fraction 0..1 = in-stock days / days) and avg_qty (average daily balance). Use this to tell a demand-driven sales drop from a supply problem — 'was there simply nothing to sell?'. A day counts as out of stock when the end-of-day balance is <= 0. Items that were never stocked are excluded. Caveat: a SKU that was out of stock for the ENTIRE period (it dropped to zero before the window) has no rows and won't appear — combine with resolve_product status to spot active items with zero availability. Like stock_balance, the report covers trading warehouses and goods for sale; the production side (materials on production warehouses) is included only with the mcp:report:cost permission.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Reporting period (required)",
-						"properties": map[string]any{
-							"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-							"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-						},
-						"required": []string{"from", "to"},
-					},
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"period":        periodProp("Reporting period (required)"),
 					"filters": map[string]any{
 						"type":        "object",
 						"description": "Optional filters",
@@ -490,9 +530,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"period"},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveCash,
+			Title:       "Find cash desk",
 			Description: "Search cash desks (кассы) by name or code. Returns matching candidates for disambiguation. Pass a UUID directly to look up a known cash desk. Use the returned id in cash_balance.filters.cash_ids or cash_flow.filters.cash_ids.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -508,9 +551,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"code": stringProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveCostArticle,
+			Title:       "Find cost article",
 			Description: "Search cost articles (статьи затрат) by name or code. The catalog is hierarchical: set include_groups=true to also return groups (cost-article folders). Pass a group UUID into cash_flow.filters.cost_article_ids to aggregate over all articles within it (applied via IN HIERARCHY), or a leaf UUID for a single article. Use the returned id in cash_flow.filters.cost_article_ids.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -530,9 +576,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(map[string]any{"code": stringProp()}),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolResolveOperation,
+			Title:       "Find cash-flow operation",
 			Description: "Search cash-flow operation types (виды движения денег — e.g. settlements with customers / suppliers / investors) by name. Use the returned id in cash_flow.filters.operation_ids, or pass it as group_by=[\"operation\"] to break cash flow down by operation type.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -548,13 +597,19 @@ func GetTools() []Tool {
 				},
 				"required": []string{"query"},
 			},
+			OutputSchema: candidatesOutput(nil),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolCashBalance,
+			Title:       "Cash balance",
 			Description: "Get cash-on-hand balance from the «ДеньгиВКассе» register as of a given date, broken down by cash desk (касса). Use group_by to pick dimensions (cash, firm), measures (balance), top to limit rows, and sort (sort.field must be a selected dimension or measure). Requires the mcp:report:money permission. NOTE: amounts are in each cash desk's own currency (the register has no currency dimension); the grand total simply sums them, so it is only meaningful when all selected cash desks share one currency.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
 					"date": map[string]any{
 						"type":        "string",
 						"format":      "date",
@@ -598,22 +653,20 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolCashFlow,
+			Title:       "Cash flow",
 			Description: "Get cash flow (turnovers) from the «ДвижениеДенежныхСредств» register for a period. Amounts are net of the base currency only (the register stores a duplicate row in the management-accounting currency which is excluded). Measures: inflow (gross money in), outflow (gross money out, positive), net (inflow - outflow). Dimensions (group_by): account (cash desk / bank account), operation (operation type — ВидОперации), analytics (counterparty / cost article / employee / ... — composite, returned as {id,label,kind} where kind is the entity type), firm, day, week, month. Default groups by operation and returns inflow/outflow/net. Filters: cash_ids (account dimension), operation_ids (operation type), cost_article_ids and customer_ids (both filter the analytics dimension and are combined via OR). sort.field must be a selected dimension or measure. Requires the mcp:report:money permission. Use this for questions like 'how much cash came in/out', 'spending by cost article', 'cash movements by counterparty'.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Report period",
-						"properties": map[string]any{
-							"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-							"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-						},
-						"required": []string{"from", "to"},
-					},
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"period":        periodProp("Report period"),
 					"filters": map[string]any{
 						"type":        "object",
 						"description": "Optional filters",
@@ -668,13 +721,19 @@ func GetTools() []Tool {
 				},
 				"required": []string{"period"},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolReceivablesBalance,
+			Title:       "Accounts receivable",
 			Description: "Get accounts-receivable balances from customers (взаиморасчёты с покупателями) from the «Взаиморасчеты» register as of a given date, broken down by customer. The balance is shown EXPANDED, not netted: receivable (ДЗ — what customers owe us) and advance (авансы полученные — prepayments we still owe goods for) are returned as separate measures, split by the sign of each customer's net balance. Note: the register has no contract/order dimension, so a receivable and an advance of the SAME customer across different deals are already netted into one figure — expansion is across customers, not within one. Dimensions (group_by): customer, firm (default: customer). Measures: receivable, advance, net (= receivable - advance; >0 means the customer is a net debtor). Filters: customer_ids (UUIDs from resolve_customer — applied via IN HIERARCHY, accepts customer-group UUIDs), firm_ids (UA/PL legal entity — use group_by=[\"firm\"] to see the split and to exclude intra-group settlements when consolidating). Requires the mcp:report:money permission. Amounts are in the base currency. sort.field must be a selected dimension or measure.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
 					"date": map[string]any{
 						"type":        "string",
 						"format":      "date",
@@ -723,13 +782,19 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolPayablesBalance,
+			Title:       "Accounts payable",
 			Description: "Get accounts-payable balances to suppliers (расчёты с поставщиками) from the «Взаиморасчеты» register as of a given date, broken down by supplier. Suppliers live in the same counterparty catalog as customers, so supplier UUIDs are resolved via resolve_customer. The balance is shown EXPANDED, not netted: payable (КЗ — what we owe suppliers) and advance (авансы выданные — prepayments we made that suppliers still owe goods for) are returned as separate measures, split by the sign of each supplier's net balance. Note: the register has no contract/order dimension, so a payable and an advance of the SAME supplier across different deals are already netted into one figure — expansion is across suppliers, not within one. Dimensions (group_by): supplier, firm (default: supplier). Measures: payable, advance, net (= payable - advance; >0 means we are a net debtor to the supplier). Filters: supplier_ids (UUIDs from resolve_customer — applied via IN HIERARCHY), firm_ids (UA/PL legal entity — use group_by=[\"firm\"] to see the split and exclude intra-group settlements when consolidating). Requires the mcp:report:money permission. Amounts are in the base currency. sort.field must be a selected dimension or measure.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
 					"date": map[string]any{
 						"type":        "string",
 						"format":      "date",
@@ -778,22 +843,21 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolPurchasesReport,
+			Title:       "Purchases report",
 			Description: "Get goods-purchase turnover (обороты поступления ТМЦ) from «ПриходнаяНакладная» documents for a period — by supplier, product, warehouse or time bucket. Amounts are NET of returns (ВидОперации=Возврат is subtracted) and include VAT — the correct purchases base for a DPO denominator. Only posted documents are counted. CURRENCY: line amounts are stored in the document currency, so `amount` is converted to the base currency at the document's rate; `amount_currency` keeps the raw document-currency figure and is only meaningful together with group_by=[\"currency\"]. IN TRANSIT: an invoice marked «в пути» posts neither stock nor a payable (the goods have not arrived), so by default such documents are EXCLUDED — pass in_transit=true for exactly those, or in_transit=\"any\" for everything; use goods_in_transit for the stock still on its way. Dimensions (group_by): supplier, firm, warehouse, product, product_group, currency, in_transit, day, week, month, delivery_date (default: supplier, month; day/week/month/delivery_date return ISO date strings). Measures: amount (base currency, incl. VAT), amount_currency, amount_without_vat, qty, documents (count of invoices) — default: amount. Filters: supplier_ids (UUIDs from resolve_customer — suppliers share the counterparty catalog; applied via IN HIERARCHY), firm_ids (UA/PL legal entity), product_ids (IN HIERARCHY, accepts group UUIDs), warehouse_ids. Requires the mcp:report:money permission; without mcp:report:cost the report covers goods for sale only — purchases of raw materials are excluded. sort.field must be a selected dimension or measure.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"period": map[string]any{
-						"type":        "object",
-						"description": "Report period",
-						"properties": map[string]any{
-							"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-							"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-						},
-						"required": []string{"from", "to"},
-					},
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"cursor":        cursorProp(),
+					"period":        periodProp("Report period"),
 					"filters": map[string]any{
 						"type":        "object",
 						"description": "Optional filters",
@@ -855,13 +919,20 @@ func GetTools() []Tool {
 				},
 				"required": []string{"period"},
 			},
+			OutputSchema: reportOutput(true),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolGoodsInTransit,
+			Title:       "Goods in transit",
 			Description: "Stock that is IN TRANSIT as of a date — paid for or ordered, already booked to the firm, but not yet accepted at the warehouse. It lives in a separate 1C register («ОстаткиТоваровВПути»), which is why none of it shows up in stock_balance: a purchase invoice flagged «в пути» posts here instead, and the same document moves the goods into the normal stock register once it is re-posted as arrived. Use it to answer 'what is on its way and when does it land', 'do we need to reorder or is it already coming', and to reconcile a stockout against incoming supply. Dimensions (group_by): warehouse (the destination), product, product_group, firm, status (how far along the delivery is), supplier, document (the source invoice) and delivery_date — the EXPECTED ARRIVAL date (ДатаПоставки from the invoice header; empty means no date was set, not 'no delivery'). Default: warehouse + product. Measures: qty, amount (base currency), amount_in_currency (cost-accounting currency) — default qty + amount; both come pre-converted from the register, no rate juggling. Rows whose balance nets to zero are omitted — those deliveries already arrived. Requires the mcp:report:stock permission; without mcp:report:cost, materials and production warehouses are excluded as everywhere else.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
+					"pivot":         pivotProp(),
+					"derived":       derivedProp(),
+					"cursor":        cursorProp(),
 					"date": map[string]any{
 						"type":        "string",
 						"format":      "date",
@@ -925,9 +996,12 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
 			Name:        ToolEventLog,
+			Title:       "Event log",
 			Description: "Read the 1C event log (журнал регистрации). List events for a period filtered by severity and/or technical event type, and optionally by user or session — all filters are independent and optional, and the period defaults to the current day. Common questions: 'errors today' → level=[\"error\"]; 'all postings today' → events=[\"_$Data$_.Post\"]; 'logins today' → events=[\"_$Session$_.Start\"]; 'what did user X do' → user=\"X\". To reconstruct what led to an error: first call with level=[\"error\"] (and user, if known) to locate the failure — each event carries its session number and timestamp — then call again with that session number and no level filter to get the full chronological trace of that session up to the error. Events come back in chronological order (oldest first) with date, level, user, user_id (the author's IB-user UUID), event (technical name like _$Data$_.Post), event_presentation, comment, metadata, object, session, transaction_status, computer. For the audit trail of one specific document or catalog item, use object_history instead. NOTE on attribution: each event belongs to the user who AUTHORED it in the log; background/scheduled jobs are recorded under a service user, not a document's 'responsible' person — so a user= filter can legitimately return nothing for changes actually made by a background process (use object_history, or a session filter, to see those). Requires the mcp:admin:eventlog permission (the log contains PII).",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -964,9 +1038,12 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"events": arrayOf(objectProp())}),
+			Annotations:  readOnlyPIITool(),
 		},
 		{
 			Name:        ToolObjectHistory,
+			Title:       "Object change history",
 			Description: "Read the 1C event log (журнал регистрации) for a specific OBJECT or object TYPE — who created, changed, posted, unposted or deleted it, and when. Pass object_type plus object_id (UUID) to audit one specific object, or object_type alone for all events of that type in the period. object_type is the full metadata name: for catalog items use 'Catalog.<Name>' (e.g. Catalog.Контрагенты) and get the UUID from resolve_customer/resolve_product/resolve_warehouse; for documents use 'Document.<Name>' (e.g. Document.ДокументОтгрузки) and get the UUID from find_document (by type+number+date). Returns events (chronological) with date, user, event/event_presentation (Создание/Изменение/Проведение/Отмена проведения/Удаление), comment, session. Requires the mcp:admin:eventlog permission.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -999,9 +1076,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"object_type"},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"events": arrayOf(objectProp())}),
+			Annotations:  readOnlyPIITool(),
 		},
 		{
 			Name:        ToolFindDocument,
+			Title:       "Find document",
 			Description: "Find a 1C document by type, number and/or date — returns matching candidates with their UUID (id) so you can audit them with object_history. doc_type is the document metadata name, e.g. 'ДокументОтгрузки' (the 'Document.' prefix is optional). You must provide at least 'number' (substring match) or 'period' (search window). Returns candidates with id, object_type, number, date, posted, deletion_mark, presentation. Requires the mcp:admin:eventlog permission.",
 			InputSchema: map[string]any{
 				"type": "object",
@@ -1029,9 +1109,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"doc_type"},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"candidates": arrayOf(objectProp())}),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolProductSpecification,
+			Name:  ToolProductSpecification,
+			Title: "Bill of materials",
 			Description: "Bill of materials (спецификация) for a product as of a date: which materials go into it and at what rate. " +
 				"Rates come from the «MaterialSpecification» register, read as a slice at `date`, so you can ask what the composition looked like at any past moment. " +
 				"A composition is identified by FOUR keys: product + matrix (матрица) + composition_type (тип состава) + production_group (группировка производства). " +
@@ -1041,6 +1124,7 @@ func GetTools() []Tool {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
 					"product_id": map[string]any{
 						"type":        "string",
 						"description": "Product UUID (from resolve_product). Either product_id or product_ids is required.",
@@ -1073,9 +1157,12 @@ func GetTools() []Tool {
 					},
 				},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolSpecificationCost,
+			Name:  ToolSpecificationCost,
+			Title: "Bill of materials cost",
 			Description: "Planned material cost of a product per its bill of materials: the same rows as product_specification plus `price` (material price as of the date) and `amount` (qty_total × price), with an `amount` total. " +
 				"This is the PLANNED cost from the composition, not the actual cost of a production run — for actuals use production_document (FIFO cost of materials actually written off). " +
 				"Prices come from the «ЦеныТоваров» register; price_type defaults to «ЦенаЗакупки» (purchase price). " +
@@ -1083,10 +1170,11 @@ func GetTools() []Tool {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"product_id":  map[string]any{"type": "string", "description": "Product UUID (from resolve_product). Either product_id or product_ids is required."},
-					"product_ids": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Several product UUIDs at once."},
-					"date":        map[string]any{"type": "string", "format": "date", "description": "Composition and prices as of this date (YYYY-MM-DD). Defaults to now."},
-					"qty":         map[string]any{"type": "number", "description": "Quantity of product to cost (default: 1)."},
+					"output_format": outputFormatProp(),
+					"product_id":    map[string]any{"type": "string", "description": "Product UUID (from resolve_product). Either product_id or product_ids is required."},
+					"product_ids":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Several product UUIDs at once."},
+					"date":          map[string]any{"type": "string", "format": "date", "description": "Composition and prices as of this date (YYYY-MM-DD). Defaults to now."},
+					"qty":           map[string]any{"type": "number", "description": "Quantity of product to cost (default: 1)."},
 					"price_type_id": map[string]any{
 						"type":        "string",
 						"description": "Price type UUID to value materials with. Defaults to «ЦенаЗакупки» (purchase price) — the same type the production document itself uses.",
@@ -1096,9 +1184,12 @@ func GetTools() []Tool {
 					"production_group_id": map[string]any{"type": "string", "description": "Narrow to one production group."},
 				},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolSpecificationExplode,
+			Name:  ToolSpecificationExplode,
+			Title: "Multi-level bill of materials",
 			Description: "Multi-level explosion (разузлование) of a bill of materials: any material that has its own composition is expanded further, down to raw materials. " +
 				"Answers 'what raw materials does making N of this actually consume', which product_specification cannot — it only shows the first level, including semi-finished items. " +
 				"Rows are flat with `level` (1 = direct materials) and `path` (chain from the top product), plus `has_spec` telling whether a material is itself further expandable. " +
@@ -1107,9 +1198,10 @@ func GetTools() []Tool {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"product_id": map[string]any{"type": "string", "description": "Product UUID to explode (from resolve_product). Required."},
-					"date":       map[string]any{"type": "string", "format": "date", "description": "Compositions as of this date (YYYY-MM-DD). Defaults to now."},
-					"qty":        map[string]any{"type": "number", "description": "Quantity of the top product (default: 1). qty_total on every level is scaled by it."},
+					"output_format": outputFormatProp(),
+					"product_id":    map[string]any{"type": "string", "description": "Product UUID to explode (from resolve_product). Required."},
+					"date":          map[string]any{"type": "string", "format": "date", "description": "Compositions as of this date (YYYY-MM-DD). Defaults to now."},
+					"qty":           map[string]any{"type": "number", "description": "Quantity of the top product (default: 1). qty_total on every level is scaled by it."},
 					"max_depth": map[string]any{
 						"type":        "integer",
 						"description": "How many levels to expand (default 3, max 10). Rows with has_spec=true at the deepest level mean the tree continues below max_depth.",
@@ -1125,9 +1217,12 @@ func GetTools() []Tool {
 				},
 				"required": []string{"product_id"},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolSpecificationWhereUsed,
+			Name:  ToolSpecificationWhereUsed,
+			Title: "Where a material is used",
 			Description: "Reverse explosion: which products contain a given material, and at what rate per unit. " +
 				"Use it for impact questions — 'this raw material got more expensive / is out of stock, which products are affected'. " +
 				"Only CURRENT compositions are returned: a material dropped from a composition by a newer «СпецификацияМатериалов» document does not show up, even though its old record still lives in the register slice. " +
@@ -1135,6 +1230,7 @@ func GetTools() []Tool {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
 					"material_id": map[string]any{
 						"type":        "string",
 						"description": "Material UUID (from resolve_material). Either material_id or material_ids is required.",
@@ -1147,9 +1243,12 @@ func GetTools() []Tool {
 					"limit":               map[string]any{"type": "integer", "description": "Maximum rows to return (default 100, max 500)."},
 				},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolSpecificationVersions,
+			Name:  ToolSpecificationVersions,
+			Title: "Bill of materials history",
 			Description: "Change history of a product's bill of materials: one version per «СпецификацияМатериалов» document that changed it, newest first, each with its full material list and a diff against the previous version. " +
 				"Answers 'when and how did the recipe change' — e.g. to explain a jump in material cost. " +
 				"The diff is computed against the previous version OF THE SAME variant (matrix + composition_type + production_group): variants live in parallel and comparing across them is meaningless. " +
@@ -1173,15 +1272,19 @@ func GetTools() []Tool {
 				},
 				"required": []string{"product_id"},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"product": objectProp(), "total_versions": map[string]any{"type": "integer"}, "versions": arrayOf(objectProp())}),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolSpecificationList,
+			Name:  ToolSpecificationList,
+			Title: "Bills of materials list",
 			Description: "Inventory of bills of materials. Default mode lists products that HAVE a composition — one row per variant: product, matrix, composition_type, production_group, materials_count, spec_date, spec_document (plus total_variants and a truncated flag). " +
 				"With missing_only=true it flips around and lists products that were PRODUCED in `period` but have NO composition as of the date — the usual cause of the «Не задан состав для продукции» error when filling materials in a production document. " +
 				"In that mode columns are product, produced_qty, documents, last_production_date, and only assembly operations are counted. Requires the mcp:report:cost permission.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
+					"output_format": outputFormatProp(),
 					"missing_only": map[string]any{
 						"type":        "boolean",
 						"description": "List products produced in `period` that have no composition, instead of listing existing compositions.",
@@ -1202,28 +1305,37 @@ func GetTools() []Tool {
 					"limit":               map[string]any{"type": "integer", "description": "Maximum rows (default 200, max 500; 100 in missing_only mode)."},
 				},
 			},
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolProductionOutput,
+			Name:  ToolProductionOutput,
+			Title: "Production output",
 			Description: "Production OUTPUT turnover from posted «Производство» documents for a period — what was manufactured. Reads the Продукция table of the document. " +
 				"By default only ASSEMBLY (сборка) operations are counted: disassembly is the mirror operation, where the Продукция table holds what was taken apart, and summing both into one 'produced' figure is wrong. Pass operation_type to change that; the effective value is echoed in applied_filters. " +
 				"Dimensions (group_by): product, product_group, warehouse (склад продукции), employee, matrix, composition_type, production_group, firm, operation, document, day, week, month (default: product, month). " +
 				"Measures: qty, amount (incl. VAT), amount_novat, documents, plus plan/fact — qty_plan, raw_qty_plan, qty_variance (fact − plan). Plan fields are not always filled in, so a zero plan means 'not planned', not 'zero output'. " +
 				"Amounts here are the sums entered in the document; for the actual FIFO cost of a run use production_document. Requires the mcp:report:cost permission. sort.field must be a selected dimension or measure.",
-			InputSchema: productionSchema(true),
+			InputSchema:  productionSchema(true),
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolProductionConsumption,
+			Name:  ToolProductionConsumption,
+			Title: "Production material consumption",
 			Description: "Material CONSUMPTION from posted «Производство» documents for a period — what was written off. Reads the Материалы table of the document. " +
 				"This table carries both the material and the product it was consumed for, so `material` and `product` can be combined in group_by to get cost of materials per manufactured item. " +
 				"By default only ASSEMBLY (сборка) operations are counted (in disassembly this table is what comes OUT, not what is consumed); pass operation_type to change that. " +
 				"Dimensions (group_by): material, material_group, product (the item it went into), product_group, warehouse (склад материалов), matrix, composition_type, production_group, firm, operation, document, day, week, month (default: material, month). " +
 				"Measures: qty, amount (incl. VAT), amount_novat, documents. " +
 				"Amounts are the sums entered in the document, NOT the FIFO cost the batch accounting actually wrote off — for that use production_document. Requires the mcp:report:cost permission.",
-			InputSchema: productionSchema(false),
+			InputSchema:  productionSchema(false),
+			OutputSchema: reportOutput(false),
+			Annotations:  readOnlyTool(),
 		},
 		{
-			Name: ToolProductionDocumentDetail,
+			Name:  ToolProductionDocumentDetail,
+			Title: "Production document",
 			Description: "Full detail of one «Производство» document: header, both tables and its actual register movements. " +
 				"Use it to explain a single production run, or to compare planned and actual cost. Get the document UUID from production_output/production_consumption with group_by=[\"document\"] (or from find_document). " +
 				"Returns {document, products[], materials[], movements[], summary}. movements are the real «ОстаткиТоваров» postings (direction expense/receipt, warehouse, product, batch, qty, amount). " +
@@ -1239,6 +1351,328 @@ func GetTools() []Tool {
 				},
 				"required": []string{"document_id"},
 			},
+			OutputSchema: passthroughOutput(map[string]any{"document": objectProp(), "products": arrayOf(objectProp()), "materials": arrayOf(objectProp()), "movements": arrayOf(objectProp()), "summary": objectProp()}),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolComparePeriods,
+			Title: "Compare two periods",
+			Description: "Run one report (sales_report, purchases_report or cash_flow) for two periods and get the rows side by side with the change already computed — use it for 'how did March compare to last March' instead of two calls. " +
+				"Pass the report's own arguments (filters, group_by, measures) in `arguments`, the current period in `period`, and either an explicit `base_period` or `compare_to`: previous (the same number of days right before, default), mom (one month earlier) or yoy (one year earlier); a period ending on the last day of a month is shifted to the last day of the target month. " +
+				"Rows are matched on the group_by dimensions by id, so renamed items still line up; a row missing in one period counts as zero there. " +
+				"Each measure M becomes four columns: M_base, M_current, M_delta (current − base) and M_delta_pct (percent of |base|; null when base is 0). totals follow the same naming. " +
+				"Rows are ordered by the absolute change of the first measure; top keeps the N largest movers. top and sort inside `arguments` are ignored — both periods are fetched in full so the match is complete. " +
+				"Requires the scope of the chosen report (mcp:report:money for purchases_report and cash_flow).",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"report": map[string]any{
+						"type":        "string",
+						"enum":        comparableReports,
+						"description": "Report to compare.",
+					},
+					"arguments": map[string]any{
+						"type":        "object",
+						"description": "Arguments of the report exactly as you would pass them to it, without period (filters, group_by, measures, in_transit, ...).",
+					},
+					"period":      periodProp("Current period"),
+					"base_period": optionalPeriodProp("Period to compare with. Omit to derive it from compare_to."),
+					"compare_to": map[string]any{
+						"type":        "string",
+						"enum":        []string{compareToPrevious, compareToMoM, compareToYoY},
+						"description": "How to derive base_period when it is omitted (default: previous).",
+					},
+					"top": map[string]any{
+						"type":        "integer",
+						"description": "Keep only the N rows with the largest absolute change of the first measure.",
+					},
+					"output_format": outputFormatProp(),
+				},
+				"required": []string{"report", "period"},
+			},
+			OutputSchema: compareOutput(),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolCashConversionCycle,
+			Title: "Cash conversion cycle",
+			Description: "Cash conversion cycle for a period in one call: DIO (days inventory outstanding), DSO (days sales outstanding), DPO (days payables outstanding) and CCC = DIO + DSO − DPO, each with its numerator and denominator. " +
+				"DIO = average stock value (stock_balance amount) / cost of goods sold (sales_report cost) × days; DSO = average receivables (receivables_balance receivable, advances excluded) / revenue (sales_report amount) × days; DPO = average payables (payables_balance payable) / purchases (purchases_report amount, net of returns) × days. " +
+				"Averages are the mean of the balances as of period.from and period.to. A component with a zero denominator has days = null, and then so does ccc. " +
+				"firm_ids narrows receivables, payables and purchases (stock and sales have no firm dimension); warehouse_ids narrows stock, sales and purchases. " +
+				"The gateway runs the underlying reports in parallel. Requires both mcp:report:money and mcp:report:cost.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"period": periodProp("Period the turnover is measured over"),
+					"firm_ids": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Firm (UA/PL legal entity) UUIDs, taken from a prior call with group_by=[\"firm\"].",
+					},
+					"warehouse_ids": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Warehouse UUIDs (from resolve_warehouse).",
+					},
+				},
+				"required": []string{"period"},
+			},
+			OutputSchema: cccOutput(),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolABCXYZAnalysis,
+			Title: "ABC/XYZ product classification",
+			Description: "Classify products (or product groups) by sales contribution (ABC) and demand stability (XYZ) in one call — the gateway fetches the full product × month sales_report slice itself, so the result is not limited by top. " +
+				"ABC: items sorted by the measure (amount by default) descending; an item is A while the cumulative share BEFORE it is below abc_thresholds.a (default 80%), B below abc_thresholds.b (default 95%), C otherwise; items with a zero or negative measure are always C. " +
+				"XYZ: coefficient of variation (population standard deviation / mean, percent) of monthly qty over every month of the period, months without sales counting as 0; X if cv_pct <= xyz_thresholds.x (default 10), Y if <= xyz_thresholds.y (default 25), Z otherwise or when nothing was sold (cv_pct null). Use a period of at least 3 full months for a meaningful XYZ. " +
+				"Rows: item, measure, share_pct, cumulative_pct, abc, qty, cv_pct, xyz, class (e.g. \"AX\"), ordered by the measure; top keeps the first N rows but classes and summary always cover all items. " +
+				"summary is the 3×3 matrix (AX … CZ) with the number of items, the measure and its share in each cell. truncated=true means the slice hit the row limit and classes are computed on incomplete data — narrow the filters. " +
+				"measure=profit requires the mcp:report:cost permission.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"period": periodProp("Analysed period; whole months give the most meaningful XYZ"),
+					"filters": map[string]any{
+						"type":        "object",
+						"description": "Optional sales_report filters",
+						"properties": map[string]any{
+							"customer_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Filter by customer or customer-group IDs (from resolve_customer), applied as IN HIERARCHY.",
+							},
+							"warehouse_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Filter by warehouse IDs (from resolve_warehouse)",
+							},
+							"sales_channel_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Filter by sales channel IDs (from resolve_sales_channel), applied as IN HIERARCHY.",
+							},
+						},
+					},
+					"level": map[string]any{
+						"type":        "string",
+						"enum":        abcLevels,
+						"description": "What to classify: product (default) or product_group.",
+					},
+					"measure": map[string]any{
+						"type":        "string",
+						"enum":        abcMeasures,
+						"description": "Measure for ABC (default: amount). profit requires mcp:report:cost.",
+					},
+					"abc_thresholds": map[string]any{
+						"type":        "object",
+						"description": "Cumulative share boundaries in percent, 0 < a < b <= 100 (default a=80, b=95).",
+						"properties": map[string]any{
+							"a": map[string]any{"type": "number"},
+							"b": map[string]any{"type": "number"},
+						},
+					},
+					"xyz_thresholds": map[string]any{
+						"type":        "object",
+						"description": "Coefficient of variation boundaries in percent, 0 < x < y (default x=10, y=25).",
+						"properties": map[string]any{
+							"x": map[string]any{"type": "number"},
+							"y": map[string]any{"type": "number"},
+						},
+					},
+					"top": map[string]any{
+						"type":        "integer",
+						"description": "Return only the first N rows (classes and summary still cover all items).",
+					},
+					"output_format": outputFormatProp(),
+				},
+				"required": []string{"period"},
+			},
+			OutputSchema: abcXYZOutput(),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolReorderSuggestions,
+			Title: "Reorder suggestions",
+			Description: "What to order and how much, per product × warehouse, in one call — the gateway combines sales_report, availability_report, stock_balance and goods_in_transit itself. All quantities are in product units; velocity is units per day. " +
+				"velocity = qty sold over the last lookback_days (up to yesterday) / days the item was in stock in that window (lookback_days − oos_days), so stockouts do not understate demand. " +
+				"days_of_cover = current stock / velocity (stock on hand only). stockout_date projects the stock forward at that velocity, adding in-transit deliveries on their expected delivery_date (overdue deliveries count as arriving today; undated ones are not projected). " +
+				"suggested_qty = ceil(velocity × horizon_days − stock − in-transit due by the end of the horizon or without a date), never negative. " +
+				"Only items with sales in the window are returned, ordered by stockout_date (soonest first), then by suggested_qty. Dates are in the database timezone. truncated=true means one of the underlying reports hit the row limit — narrow the filters. " +
+				"Requires both mcp:report:stock and mcp:report:sales.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"filters": map[string]any{
+						"type":        "object",
+						"description": "Optional filters applied to all four reports",
+						"properties": map[string]any{
+							"warehouse_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Warehouses to plan for (from resolve_warehouse). Omit for all trading warehouses.",
+							},
+							"product_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Product or product-group IDs (from resolve_product), applied as IN HIERARCHY. The gateway expands groups with product_details (needs mcp:resolve, up to 500 products) to filter the sales, which has no product filter; if the unfiltered sales hit the row limit, the call fails — add warehouse_ids.",
+							},
+						},
+					},
+					"lookback_days": map[string]any{
+						"type":        "integer",
+						"description": "Sales window for the velocity, days up to yesterday (default 28, max 365).",
+					},
+					"horizon_days": map[string]any{
+						"type":        "integer",
+						"description": "Days of demand the order should cover from today (default 14, max 365).",
+					},
+					"top": map[string]any{
+						"type":        "integer",
+						"description": "Return only the first N rows (the most urgent).",
+					},
+					"output_format": outputFormatProp(),
+				},
+			},
+			OutputSchema: reorderOutput(),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolDetectAnomalies,
+			Title: "Detect anomalies",
+			Description: "Flag unusual days in a daily series — the 'anything unusual yesterday?' question in one call. The gateway fetches sales_report (default) or cash_flow grouped by day, optionally split by one dimension, and compares the checked days (check_days ending at date, default: yesterday) with the lookback_days before them. " +
+				"The baseline is robust: the median of the same weekday in the baseline window (Mondays against Mondays; all days when the window has fewer than 4 of that weekday), and the score is the modified z-score 0.6745 × (actual − median) / MAD. A day is flagged when |score| >= threshold (default 3.5). A day missing from the report counts as 0, so if the checked days or the baseline hit the row limit the call fails — add filters, drop dimension or shorten the window. When the baseline has no spread at all (e.g. all zeros) any different value is flagged with score null. " +
+				"Rows: [dimension], day, weekday, actual, expected (the baseline median), deviation, deviation_pct (null when expected is 0), score, direction (high/low); strongest first. " +
+				"Dates are in the database timezone. Listed with mcp:report:sales or mcp:report:money; the call requires the scope of the chosen report (mcp:report:sales for sales_report, mcp:report:money for cash_flow).",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"report": map[string]any{
+						"type":        "string",
+						"enum":        []string{ToolSalesReport, ToolCashFlow},
+						"description": "Report to build the daily series from (default: sales_report).",
+					},
+					"measure": map[string]any{
+						"type":        "string",
+						"description": "Measure of the series. sales_report: amount (default), qty, receipts, customers. cash_flow: net (default), inflow, outflow.",
+					},
+					"dimension": map[string]any{
+						"type":        "string",
+						"description": "Optional split, one series per value. sales_report: warehouse, sales_channel, product_group, customer_group, seller. cash_flow: account, operation, firm.",
+					},
+					"filters": map[string]any{
+						"type":        "object",
+						"description": "Filters of the chosen report exactly as you would pass them to it (e.g. warehouse_ids, sales_channel_ids for sales_report; cash_ids, operation_ids for cash_flow).",
+					},
+					"date": map[string]any{
+						"type":        "string",
+						"format":      "date",
+						"description": "Last day to check (YYYY-MM-DD). Default: yesterday.",
+					},
+					"check_days": map[string]any{
+						"type":        "integer",
+						"description": "Number of days to check, ending at date (default 1, max 31).",
+					},
+					"lookback_days": map[string]any{
+						"type":        "integer",
+						"description": "Baseline window right before the checked days (default 90, min 28, max 365).",
+					},
+					"threshold": map[string]any{
+						"type":        "number",
+						"description": "Minimum |score| to flag (default 3.5; lower it for more, smaller deviations).",
+					},
+					"direction": map[string]any{
+						"type":        "string",
+						"enum":        anomalyDirections,
+						"description": "Which deviations to return: both (default), high (spikes) or low (drops).",
+					},
+					"top": map[string]any{
+						"type":        "integer",
+						"description": "Return only the N strongest anomalies.",
+					},
+					"output_format": outputFormatProp(),
+				},
+			},
+			OutputSchema: anomaliesOutput(),
+			Annotations:  readOnlyTool(),
+		},
+		{
+			Name:  ToolRFMSegments,
+			Title: "Customer RFM segments",
+			Description: "Segment the customers who bought in a period by recency, frequency and monetary value (RFM) — use it for churn-risk lists ('which regular customers stopped buying?') instead of calling customer_summary one customer at a time. " +
+				"The gateway fetches sales_report grouped by customer and day and computes per customer: recency_days (days from the last sales document to period.to), receipts (number of sales documents) and amount. " +
+				"Each is turned into a quintile score 1..5 by rank among the customers in the sample (5 = most recent / most frequent / most money; equal values get equal scores), and the segment comes from R and FM = round((F + M) / 2): " +
+				"champions (R5, FM4-5), loyal (R3-4, FM4-5), potential_loyalist (R4-5, FM2-3), new (R5, FM1), promising (R4, FM1), need_attention (R3, FM3), about_to_sleep (R3, FM1-2), cant_lose (R1-2, FM5), at_risk (R1-2, FM3-4), hibernating (R2, FM1-2), lost (R1, FM1-2). " +
+				"Scores are relative to the sample, so the period should be long enough to cover several purchase cycles (e.g. 6-12 months for B2B). Customers with no sales documents in the period are not included. " +
+				"Rows are ordered by amount; segments narrows the rows (summary always covers every segment). truncated=true means the customer × day slice hit the row limit — narrow the filters or the period.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"period": periodProp("Analysed period; recency is measured to period.to"),
+					"filters": map[string]any{
+						"type":        "object",
+						"description": "Optional filters, as in sales_report",
+						"properties": map[string]any{
+							"customer_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Customer or customer-group IDs (from resolve_customer), applied as IN HIERARCHY.",
+							},
+							"sales_channel_ids": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "Sales channel IDs (from resolve_sales_channel), applied as IN HIERARCHY — e.g. the 'B2B' node.",
+							},
+						},
+					},
+					"segments": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string", "enum": rfmSegmentNames},
+						"description": "Return only customers in these segments, e.g. [\"at_risk\", \"cant_lose\"] for a churn-risk list.",
+					},
+					"top": map[string]any{
+						"type":        "integer",
+						"description": "Return only the first N rows (by amount).",
+					},
+					"output_format": outputFormatProp(),
+				},
+				"required": []string{"period"},
+			},
+			OutputSchema: rfmOutput(),
+			Annotations:  readOnlyTool(),
+		},
+	})
+}
+
+// periodExpressions — подсказка модели к форме period-выражением (см. onec/period.go).
+const periodExpressions = "Either {from, to} or a relative period string resolved by the gateway in the " +
+	"tenant's timezone: today, yesterday, this_week, last_week, this_month, last_month, this_quarter, " +
+	"last_quarter, this_year, last_year, ytd, last_N_days (e.g. last_30_days, ends today), 2025 (year), " +
+	"2025-03 (month), 2025-Q3 (quarter), 2025-W14 (ISO week). this_* periods end today; the resolved dates " +
+	"are echoed as resolved_period."
+
+// periodProp — обязательный период отчёта: {from, to} или выражение относительного периода,
+// которое гейт разворачивает до вызова (resolvePeriods).
+func periodProp(description string) map[string]any {
+	prop := optionalPeriodProp(description)
+	prop["anyOf"].([]map[string]any)[0]["required"] = []string{"from", "to"}
+	return prop
+}
+
+// optionalPeriodProp — то же без обязательных from/to (base_period compare_periods).
+func optionalPeriodProp(description string) map[string]any {
+	return map[string]any{
+		"description": description + ". " + periodExpressions,
+		"anyOf": []map[string]any{
+			{
+				"type": "object",
+				"properties": map[string]any{
+					"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
+					"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
+				},
+			},
+			{"type": "string", "description": "Relative period, e.g. last_month or 2025-Q3"},
 		},
 	}
 }
@@ -1299,15 +1733,11 @@ func productionSchema(output bool) map[string]any {
 	return map[string]any{
 		"type": "object",
 		"properties": map[string]any{
-			"period": map[string]any{
-				"type":        "object",
-				"description": "Report period",
-				"properties": map[string]any{
-					"from": map[string]any{"type": "string", "format": "date", "description": "Start date (YYYY-MM-DD)"},
-					"to":   map[string]any{"type": "string", "format": "date", "description": "End date (YYYY-MM-DD)"},
-				},
-				"required": []string{"from", "to"},
-			},
+			"cursor":        cursorProp(),
+			"output_format": outputFormatProp(),
+			"pivot":         pivotProp(),
+			"derived":       derivedProp(),
+			"period":        periodProp("Report period"),
 			"operation_type": map[string]any{
 				"type":        "string",
 				"enum":        []string{"assembly", "disassembly", "all"},
@@ -1344,3 +1774,18 @@ func productionSchema(output bool) map[string]any {
 		"required": []string{"period"},
 	}
 }
+
+// readOnlyTool — аннотации инструментов гейта: все они только читают одну базу 1С. Повторный
+// вызов с теми же аргументами ничего не меняет (idempotent), а мир закрыт — ответы берутся
+// из конкретной базы, а не из внешних источников (openWorld=false). Клиенты по ним перестают
+// спрашивать подтверждение на каждый отчёт.
+func readOnlyTool() *ToolAnnotations {
+	return &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}
+}
+
+// readOnlyPIITool — то же для инструментов, раскрывающих персональные данные.
+func readOnlyPIITool() *ToolAnnotations {
+	a := readOnlyTool()
+	a.SensitivityHint = "pii"
+	return a
+}
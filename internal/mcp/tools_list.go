@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"slices"
+	"strconv"
+	"strings"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// toolsCursorPrefix — метка курсора tools/list. Курсор для клиента непрозрачен, но метка не даёт
+// принять за него курсор другого списка (или мусор, похожий на base64-число).
+const toolsCursorPrefix = "tools:"
+
+// pageTools режет список инструментов на страницу по курсору. size <= 0 — всё одной страницей,
+// как было до пагинации. Курсор — смещение, а не имя последнего инструмента: список стабилен
+// по порядку GetTools, а если между страницами его изменил Registry.Reload или права, клиент
+// об этом узнает из notifications/tools/list_changed и перечитает список с начала.
+//
+// ok=false — курсор не выдавался этим сервером (по спецификации это -32602).
+func pageTools(tools []Tool, cursor string, size int) (page []Tool, next string, ok bool) {
+	offset := 0
+	if cursor != "" {
+		var valid bool
+		if offset, valid = decodeToolsCursor(cursor); !valid || offset > len(tools) {
+			return nil, "", false
+		}
+	}
+	if size <= 0 {
+		return tools[offset:], "", true
+	}
+
+	end := min(offset+size, len(tools))
+	if end < len(tools) {
+		next = encodeToolsCursor(end)
+	}
+	return tools[offset:end], next, true
+}
+
+func encodeToolsCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(toolsCursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeToolsCursor(cursor string) (int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	digits, ok := strings.CutPrefix(string(raw), toolsCursorPrefix)
+	if !ok {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(digits)
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+// scopeSet — права вызывающего в каноническом виде для сравнения между запросами сессии:
+// порядок scope в токене значения не имеет. Без OAuth — пустая строка.
+func scopeSet(auth *oauth.AuthInfo) string {
+	if auth == nil {
+		return ""
+	}
+	scopes := slices.Clone(auth.Scopes)
+	slices.Sort(scopes)
+	return strings.Join(slices.Compact(scopes), " ")
+}
@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// Страницы tools/list по nextCursor складываются ровно в полный список.
+func TestToolsListPagination(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.cfg.MCP.ToolsPageSize = 7
+
+	var names []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(GetTools()) {
+			t.Fatal("pagination never ends")
+		}
+		var params any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		res := rpc(t, h, context.Background(), "tools/list", params)
+		if res.Error != nil {
+			t.Fatalf("tools/list: %+v", res.Error)
+		}
+		var page ListToolsResult
+		if err := json.Unmarshal(res.Result, &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Tools) > 7 {
+			t.Fatalf("page of %d tools, want at most 7", len(page.Tools))
+		}
+		for _, tool := range page.Tools {
+			names = append(names, tool.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	all := GetTools()
+	if len(names) != len(all) {
+		t.Fatalf("paged %d tools, want %d", len(names), len(all))
+	}
+	for i, tool := range all {
+		if names[i] != tool.Name {
+			t.Fatalf("tool %d = %s, want %s", i, names[i], tool.Name)
+		}
+	}
+
+	for _, bad := range []string{"garbage", encodeToolsCursor(len(all) + 1), "dG9vbHM6LTE"} {
+		if res := rpc(t, h, context.Background(), "tools/list", map[string]any{"cursor": bad}); res.Error == nil || res.Error.Code != CodeInvalidParams {
+			t.Errorf("cursor %q: %+v, want -32602", bad, res.Error)
+		}
+	}
+}
+
+// Смена прав между запросами сессии и пересборка реестра дают notifications/tools/list_changed.
+func TestToolsListChangedNotifications(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	sid := initialize(t, h, asUser("alice"), ProtocolVersionStreamable).Header().Get(SessionHeader)
+	sess, ok := h.sessions.Get(sid)
+	if !ok {
+		t.Fatal("session not registered")
+	}
+	stream := sess.attachStream()
+
+	list := map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+	headers := map[string]string{SessionHeader: sid}
+
+	postRPC(t, h, asUser("alice"), list, headers)
+	if len(stream) != 0 {
+		t.Fatalf("notification without a scope change: %s", <-stream)
+	}
+
+	wider := oauth.ContextWithAuth(context.Background(),
+		&oauth.AuthInfo{Sub: "alice", Scopes: []string{"mcp:report:sales", "mcp:resolve"}})
+	postRPC(t, h, wider, list, headers)
+	if len(stream) != 1 || !strings.Contains(string(<-stream), "notifications/tools/list_changed") {
+		t.Fatal("scope change was not announced")
+	}
+
+	if sent := h.sessions.NotifyToolsListChanged(); sent != 1 {
+		t.Errorf("NotifyToolsListChanged reached %d sessions, want 1", sent)
+	}
+	if !strings.Contains(string(<-stream), "notifications/tools/list_changed") {
+		t.Error("reload notification not delivered")
+	}
+}
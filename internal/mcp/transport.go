@@ -0,0 +1,418 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// sseKeepAlive — период SSE-комментария, которым держится соединение. Меньше типичного
+// proxy_read_timeout (60 с): без него прокси рвёт и тихий GET-поток, и POST, пока 1С считает
+// отчёт (ReportTimeout доходит до 45 с).
+const sseKeepAlive = 20 * time.Second
+
+// ServeHTTP — транспорт Streamable HTTP на одном пути /{slug}/mcp:
+//
+//	POST   — JSON-RPC сообщение клиента; ответ JSON или, для tools/call, SSE-поток;
+//	GET    — SSE-поток серверных уведомлений сессии;
+//	DELETE — завершение сессии.
+//
+// Клиенты, не знающие о сессиях (старые коннекторы, curl), продолжают работать как раньше:
+// POST без Mcp-Session-Id обслуживается вне сессии.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.bearerToken != "" && !h.authenticate(r) {
+		h.writeJSON(w, http.StatusOK, Unauthorized(nil))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.servePost(w, r)
+	case http.MethodGet:
+		h.serveStream(w, r)
+	case http.MethodDelete:
+		h.serveDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) servePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeJSON(w, http.StatusOK, ParseError(nil))
+		return
+	}
+
+	if isBatch(body) {
+		h.serveBatch(w, r, body)
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.writeJSON(w, http.StatusOK, ParseError(nil))
+		return
+	}
+
+	if req.JSONRPC != JSONRPCVersion {
+		h.writeJSON(w, http.StatusOK, InvalidRequest(req.ID))
+		return
+	}
+
+	h.logger.Info("mcp request", "method", req.Method, "id", req.ID)
+
+	// initialize сессию открывает, а не предъявляет: заголовок в нём, если клиент его и прислал
+	// (переподключение после 404), значения не имеет.
+	var sess *Session
+	if req.Method != "initialize" {
+		var ok bool
+		if sess, ok = h.checkSession(w, r, req.ID); !ok {
+			return
+		}
+		if !h.checkProtocolHeader(w, r, req.ID) {
+			return
+		}
+	}
+
+	ctx := h.requestContext(r, sess)
+
+	// Уведомление (JSON-RPC 2.0 §4.1): нет id — ответа быть не должно, даже об ошибке.
+	// Сюда попадает notifications/initialized, который шлёт каждый MCP-клиент после initialize.
+	// Раньше он проваливался в default и получал "Method not found" — формально нарушение спеки.
+	if req.ID == nil {
+		h.handleNotification(ctx, req)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// tools/call — единственный метод, ответ на который может ждать 1С десятки секунд. SSE-ответ
+	// позволяет держать соединение keep-alive'ами и слать в него уведомления по ходу вызова.
+	if req.Method == "tools/call" && acceptsEventStream(r) {
+		h.serveEventStream(ctx, w, func(ctx context.Context) any {
+			if resp := h.dispatch(ctx, req); resp != nil {
+				return resp
+			}
+			return nil
+		})
+		return
+	}
+
+	resp := h.dispatch(ctx, req)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted) // запрос отменён клиентом — отвечать нечем
+		return
+	}
+
+	if req.Method == "initialize" && resp.Error == nil {
+		if !h.openSession(w, r, resp) {
+			h.writeJSON(w, http.StatusOK, InternalError(req.ID, "failed to create session"))
+			return
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// checkSession проверяет Mcp-Session-Id, если клиент его прислал. false — ответ (404) уже
+// записан. Отсутствие заголовка не ошибка: так работают клиенты, не знающие о сессиях
+// (тогда возвращается nil-сессия).
+func (h *Handler) checkSession(w http.ResponseWriter, r *http.Request, id any) (*Session, bool) {
+	sid := r.Header.Get(SessionHeader)
+	if sid == "" {
+		return nil, true
+	}
+	sess, ok := h.lookupSession(r, sid)
+	if !ok {
+		h.writeJSON(w, http.StatusNotFound, SessionNotFound(id))
+		return nil, false
+	}
+
+	// Права сменились (новый токен после refresh, правка scope базы) — набор доступных
+	// инструментов тоже: клиенту пора перечитать tools/list.
+	if sess.observeScopes(scopeSet(oauth.FromContext(r.Context()))) {
+		h.logger.Info("mcp.session.scopes_changed", "session", sess.ID)
+		sess.Notify("notifications/tools/list_changed", nil)
+	}
+	return sess, true
+}
+
+// requestContext снабжает контекст запроса тем, что знает о нём транспорт (callContext).
+// В сессии id запросов уникальны в её пределах, а уведомления по умолчанию уходят в её
+// GET-поток. Вне сессии областью служит пара база+пользователь, и слать уведомления некуда.
+// Без пользователя (статический токен, без авторизации) области нет вовсе: все такие клиенты
+// неотличимы, и общая область позволила бы одному отменять запросы другого, угадав id. Их
+// запросы не отменяются — для отмены нужна сессия.
+// Ревизия протокола — из сессии или заголовка MCP-Protocol-Version (requestProtocol).
+func (h *Handler) requestContext(r *http.Request, sess *Session) context.Context {
+	cc := callContext{protocol: requestProtocol(r, sess)}
+	if sess != nil {
+		cc.scope = "session:" + sess.ID
+		cc.notify = func(method string, params any) { sess.Notify(method, params) }
+		cc.logLevel = &sess.logLevel
+	} else {
+		if sub, _ := authIdentity(oauth.FromContext(r.Context())); sub != "" {
+			cc.scope = "tenant:" + h.tenant + "/" + sub
+		}
+	}
+	return withCallContext(r.Context(), cc)
+}
+
+// openSession заводит сессию по успешному initialize и выдаёт её идентификатор заголовком.
+// Сессия помечается базой и пользователем: предъявить её сможет только тот же sub на той же базе.
+func (h *Handler) openSession(w http.ResponseWriter, r *http.Request, resp *Response) bool {
+	result, ok := resp.Result.(InitializeResult)
+	if !ok {
+		return false
+	}
+
+	sub, _ := authIdentity(oauth.FromContext(r.Context()))
+	sess, err := h.sessions.Create(h.tenant, sub, result.ProtocolVersion)
+	if err != nil {
+		h.logger.Error("mcp session create failed", "error", err)
+		return false
+	}
+	sess.observeScopes(scopeSet(oauth.FromContext(r.Context())))
+
+	w.Header().Set(SessionHeader, sess.ID)
+	h.logger.Info("mcp.session.open", "session", sess.ID, "sub", sub, "protocol", result.ProtocolVersion)
+	return true
+}
+
+// lookupSession — сессия из заголовка, если она жива и принадлежит этой базе и этому пользователю.
+// Чужая сессия неотличима от несуществующей: 404 в обоих случаях.
+func (h *Handler) lookupSession(r *http.Request, id string) (*Session, bool) {
+	sess, ok := h.sessions.Get(id)
+	if !ok {
+		return nil, false
+	}
+	sub, _ := authIdentity(oauth.FromContext(r.Context()))
+	if sess.Tenant != h.tenant || sess.Sub != sub {
+		h.logger.Warn("mcp.session.mismatch", "session", id, "sub", sub)
+		return nil, false
+	}
+	return sess, true
+}
+
+// serveStream — GET: долгоживущий SSE-поток серверных сообщений сессии. Без сессии слать
+// в него нечего (уведомления адресуются сессии), поэтому Mcp-Session-Id обязателен.
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	sid := r.Header.Get(SessionHeader)
+	if sid == "" {
+		h.writeJSON(w, http.StatusBadRequest, InvalidRequest(nil))
+		return
+	}
+	sess, ok := h.lookupSession(r, sid)
+	if !ok {
+		h.writeJSON(w, http.StatusNotFound, SessionNotFound(nil))
+		return
+	}
+
+	stream := newEventStream(w)
+	if err := stream.open(); err != nil {
+		h.logger.Error("mcp stream open failed", "error", err)
+		return
+	}
+
+	queue := sess.attachStream()
+	defer sess.detachStream(queue)
+
+	h.logger.Info("mcp.stream.open", "session", sess.ID)
+	defer h.logger.Info("mcp.stream.close", "session", sess.ID)
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data, ok := <-queue:
+			if !ok {
+				return // сессия завершена или поток вытеснен новым GET
+			}
+			if stream.event(data) != nil {
+				return
+			}
+		case <-ticker.C:
+			if stream.ping() != nil {
+				return
+			}
+			sess.touch()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveDelete — явное завершение сессии клиентом.
+func (h *Handler) serveDelete(w http.ResponseWriter, r *http.Request) {
+	sid := r.Header.Get(SessionHeader)
+	if sid == "" {
+		h.writeJSON(w, http.StatusBadRequest, InvalidRequest(nil))
+		return
+	}
+	if _, ok := h.lookupSession(r, sid); !ok {
+		h.writeJSON(w, http.StatusNotFound, SessionNotFound(nil))
+		return
+	}
+
+	h.sessions.Delete(sid)
+	h.logger.Info("mcp.session.close", "session", sid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveEventStream отвечает на POST SSE-потоком: заголовки уходят сразу, пока run работает —
+// keep-alive и уведомления запроса (progress), в конце — одно событие с тем, что run вернул
+// (ответ или массив ответов батча), после чего поток закрывается. run вернул nil — запрос
+// отменён клиентом, и поток закрывается без ответа.
+func (h *Handler) serveEventStream(ctx context.Context, w http.ResponseWriter, run func(ctx context.Context) any) {
+	stream := newEventStream(w)
+	if err := stream.open(); err != nil {
+		h.logger.Warn("mcp stream open failed", "error", err)
+		return
+	}
+
+	ctx = withNotifier(ctx, func(method string, params any) {
+		if data, err := encodeMessage(NewNotification(method, params)); err == nil {
+			_ = stream.event(data)
+		}
+	})
+
+	stop := stream.keepAlive(sseKeepAlive)
+	msg := run(ctx)
+	stop()
+
+	if msg == nil {
+		return
+	}
+
+	data, err := encodeMessage(msg)
+	if err != nil {
+		h.logger.Error("failed to encode response", "error", err)
+		return
+	}
+	if err := stream.event(data); err != nil {
+		h.logger.Warn("mcp stream write failed", "error", err)
+	}
+}
+
+// writeJSON отдаёт обычный JSON-ответ: один Response или массив ответов батча.
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, msg any) {
+	w.Header().Set("Content-Type", "application/json")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	h.writeResponse(w, msg)
+}
+
+// acceptsEventStream — клиент объявил, что понимает SSE. Спецификация требует от него
+// перечислять в Accept и application/json, и text/event-stream; старые клиенты шлют только JSON
+// (или ничего) и получают JSON, как раньше.
+func acceptsEventStream(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeMessage(msg any) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// eventStream — писатель SSE поверх ResponseWriter. Запись под мьютексом: keep-alive идёт из
+// своей горутины параллельно с событиями.
+type eventStream struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{w: w, rc: http.NewResponseController(w)}
+}
+
+// open отправляет заголовки потока. X-Accel-Buffering выключает буферизацию в nginx —
+// иначе события копились бы в прокси до конца ответа, и весь смысл SSE терялся.
+func (s *eventStream) open() error {
+	h := s.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w.WriteHeader(http.StatusOK)
+	return s.flush()
+}
+
+func (s *eventStream) event(data []byte) error {
+	return s.write(fmt.Sprintf("event: message\ndata: %s\n\n", data))
+}
+
+func (s *eventStream) ping() error {
+	return s.write(": ping\n\n")
+}
+
+func (s *eventStream) write(chunk string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write([]byte(chunk)); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// flush проталкивает записанное клиенту. Обёртка ResponseWriter без Flush — не ошибка:
+// события дойдут одним куском в конце ответа, просто без промежуточной доставки.
+func (s *eventStream) flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// keepAlive пингует поток с периодом every, пока не вызвана возвращённая функция остановки.
+// Остановка синхронная: после неё горутина гарантированно больше не пишет в поток.
+func (s *eventStream) keepAlive(every time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.ping() != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,241 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/mcp-sales-mvp/internal/oauth"
+)
+
+// postRPC отправляет одно JSON-RPC сообщение POST'ом с заданными заголовками.
+func postRPC(t *testing.T, h *Handler, ctx context.Context, msg map[string]any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader(body)).WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func initialize(t *testing.T, h *Handler, ctx context.Context, version string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postRPC(t, h, ctx, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "initialize",
+		"params":  map[string]any{"protocolVersion": version, "clientInfo": map[string]any{"name": "test"}},
+	}, nil)
+}
+
+func asUser(sub string) context.Context {
+	return oauth.ContextWithAuth(context.Background(), &oauth.AuthInfo{Sub: sub, Scopes: []string{"mcp:resolve"}})
+}
+
+func TestInitializeOpensSession(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	cases := []struct{ asked, want string }{
+		{ProtocolVersionStreamable, ProtocolVersionStreamable},
+		{ProtocolVersionStructured, ProtocolVersionStructured},
+		{"2024-11-05", "2024-11-05"},
+		{"1999-01-01", LatestProtocolVersion},
+	}
+	for _, tc := range cases {
+		rec := initialize(t, h, context.Background(), tc.asked)
+
+		if rec.Header().Get(SessionHeader) == "" {
+			t.Errorf("initialize(%s): no %s header", tc.asked, SessionHeader)
+		}
+
+		var envelope struct {
+			Result InitializeResult `json:"result"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("unmarshal %s: %v", rec.Body.String(), err)
+		}
+		if envelope.Result.ProtocolVersion != tc.want {
+			t.Errorf("initialize(%s) negotiated %s, want %s", tc.asked, envelope.Result.ProtocolVersion, tc.want)
+		}
+	}
+}
+
+// Неизвестная, закрытая или чужая сессия — 404: по нему клиент понимает, что пора в initialize.
+func TestSessionLifecycle(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ping := map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+
+	rec := postRPC(t, h, asUser("alice"), ping, map[string]string{SessionHeader: "nope"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown session: HTTP %d, want 404", rec.Code)
+	}
+
+	sid := initialize(t, h, asUser("alice"), ProtocolVersionStreamable).Header().Get(SessionHeader)
+
+	if rec := postRPC(t, h, asUser("alice"), ping, map[string]string{SessionHeader: sid}); rec.Code != http.StatusOK {
+		t.Fatalf("own session: HTTP %d, want 200", rec.Code)
+	}
+	if rec := postRPC(t, h, asUser("bob"), ping, map[string]string{SessionHeader: sid}); rec.Code != http.StatusNotFound {
+		t.Fatalf("someone else's session: HTTP %d, want 404", rec.Code)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "/mcp", nil).WithContext(asUser("alice"))
+	del.Header.Set(SessionHeader, sid)
+	delRec := httptest.NewRecorder()
+	h.ServeHTTP(delRec, del)
+	if delRec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: HTTP %d, want 204", delRec.Code)
+	}
+
+	if rec := postRPC(t, h, asUser("alice"), ping, map[string]string{SessionHeader: sid}); rec.Code != http.StatusNotFound {
+		t.Fatalf("closed session: HTTP %d, want 404", rec.Code)
+	}
+}
+
+// Сессия одной базы не предъявляется другой, даже если реестр сессий у них общий.
+func TestSessionIsTenantScoped(t *testing.T) {
+	h, _ := newTestHandler(t)
+	other := NewHandler("other", h.onecClient, h.cfg, "", h.sessions, h.logger)
+
+	sid := initialize(t, h, context.Background(), ProtocolVersionStreamable).Header().Get(SessionHeader)
+
+	rec := postRPC(t, other, context.Background(),
+		map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"},
+		map[string]string{SessionHeader: sid})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("session of another tenant: HTTP %d, want 404", rec.Code)
+	}
+}
+
+// Клиент, принимающий SSE, получает ответ на tools/call событием потока; остальные — JSON, как раньше.
+func TestToolsCallAnswersWithEventStream(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	rec := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0",
+		"id":      7,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": ToolStockBalance, "arguments": map[string]any{}},
+	}, map[string]string{"Accept": "application/json, text/event-stream"})
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	data := lastEventData(t, rec.Body.String())
+	var envelope struct {
+		ID     int            `json:"id"`
+		Result CallToolResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+		t.Fatalf("unmarshal event %q: %v", data, err)
+	}
+	if envelope.ID != 7 || envelope.Result.IsError {
+		t.Errorf("unexpected response event: %s", data)
+	}
+
+	plain := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0",
+		"id":      8,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": ToolStockBalance, "arguments": map[string]any{}},
+	}, map[string]string{"Accept": "application/json"})
+	if ct := plain.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("JSON-only client got Content-Type %q", ct)
+	}
+}
+
+// GET-поток сессии доставляет серверные уведомления.
+func TestSessionStreamDeliversNotifications(t *testing.T) {
+	h, _ := newTestHandler(t)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	sid := initialize(t, h, context.Background(), ProtocolVersionStreamable).Header().Get(SessionHeader)
+	sess, ok := h.sessions.Get(sid)
+	if !ok {
+		t.Fatal("session not registered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionHeader, sid)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET stream: HTTP %d", resp.StatusCode)
+	}
+
+	// Поток подключается асинхронно относительно ответа на заголовки — ждём, пока очередь появится.
+	deadline := time.Now().Add(2 * time.Second)
+	for !sess.Notify("notifications/tools/list_changed", nil) {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never attached to the session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			if !strings.Contains(line, "notifications/tools/list_changed") {
+				t.Errorf("unexpected event: %s", line)
+			}
+			return
+		}
+	}
+}
+
+func TestStreamRequiresSession(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET without session: HTTP %d, want 400", rec.Code)
+	}
+}
+
+// lastEventData — data последнего SSE-события в теле ответа.
+func lastEventData(t *testing.T, body string) string {
+	t.Helper()
+
+	var data string
+	for _, line := range strings.Split(body, "\n") {
+		if rest, ok := strings.CutPrefix(line, "data: "); ok {
+			data = rest
+		}
+	}
+	if data == "" {
+		t.Fatalf("no SSE event in %q", body)
+	}
+	return data
+}
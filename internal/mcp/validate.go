@@ -0,0 +1,311 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Проверка аргументов tools/call по InputSchema инструмента — до похода в 1С. Без неё
+// неизвестный group_by, кривая дата или пропущенный period.from уезжали в 1С и возвращались
+// невнятной ошибкой, а модель тратила ещё один вызов на угадывание.
+//
+// Проверяется то подмножество JSON Schema, которое реально встречается в GetTools(): type,
+// properties, required, enum, items, anyOf и format: date. Лишние поля не отвергаются — 1С и
+// раньше их игнорировала.
+//
+// Проверка терпит ровно то же, что терпит разбор аргументов (flexInt/flexBool, unstringifyJSON,
+// unmarshalObjectOrString): число строкой, булево строкой или 0/1, объект пустой строкой.
+// Отвергать то, что хендлер принял бы, значило бы сломать работающие вызовы.
+
+// argumentError — одна ошибка аргумента: путь в нотации filters.warehouse_ids[2] и допустимые
+// значения, если они перечислимы.
+type argumentError struct {
+	Path    string   `json:"path"`
+	Message string   `json:"message"`
+	Allowed []string `json:"allowed,omitempty"`
+	// Candidates — кандидаты неоднозначного наименования в фильтре (см. names.go).
+	Candidates []nameCandidate `json:"candidates,omitempty"`
+}
+
+func (e argumentError) String() string {
+	s := e.Path + ": " + e.Message
+	if len(e.Allowed) > 0 {
+		s += " (allowed: " + strings.Join(e.Allowed, ", ") + ")"
+	}
+	return s
+}
+
+// argumentErrors — ошибки аргументов как error: так их возвращают составные инструменты,
+// проверяющие аргументы вложенного отчёта сами (см. composite.go). handleToolsCall отвечает на
+// них тем же -32602, что и на ошибки верхнего уровня.
+type argumentErrors []argumentError
+
+func (e argumentErrors) Error() string { return formatArgumentErrors(e) }
+
+// toolInputSchemas — входные схемы по имени инструмента. GetTools детерминирован, поэтому
+// схемы строятся один раз; validateArguments их только читает.
+var toolInputSchemas = sync.OnceValue(func() map[string]any {
+	schemas := make(map[string]any)
+	for _, t := range GetTools() {
+		schemas[t.Name] = t.InputSchema
+	}
+	return schemas
+})
+
+func toolInputSchema(name string) any {
+	return toolInputSchemas()[name]
+}
+
+// validateArguments проверяет args (уже прошедшие unstringifyJSON) по schema. Пустой результат —
+// аргументы годны.
+func validateArguments(schema any, args any) []argumentError {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	var errs []argumentError
+	validateValue(s, args, "", true, &errs)
+	return errs
+}
+
+// formatArgumentErrors — текст ошибки для модели: все проблемы разом, чтобы исправить их
+// одним повторным вызовом.
+func formatArgumentErrors(errs []argumentError) string {
+	parts := make([]string, len(errs))
+	for i, e := range errs {
+		parts[i] = e.String()
+	}
+	return "invalid arguments: " + strings.Join(parts, "; ")
+}
+
+func validateValue(schema map[string]any, v any, path string, required bool, errs *[]argumentError) {
+	fail := func(msg string, allowed ...string) {
+		name := path
+		if name == "" {
+			name = "arguments"
+		}
+		*errs = append(*errs, argumentError{Path: name, Message: msg, Allowed: allowed})
+	}
+
+	if variants, ok := schema["anyOf"].([]map[string]any); ok {
+		// Объект, не подошедший под объектную форму, получает её ошибки: «не подходит ни под
+		// одну форму» про period без to ничего не объясняет.
+		var objectErrs []argumentError
+		for _, variant := range variants {
+			var probe []argumentError
+			validateValue(variant, v, path, required, &probe)
+			if len(probe) == 0 {
+				return
+			}
+			if _, isObject := v.(map[string]any); isObject && variant["type"] == "object" {
+				objectErrs = probe
+			}
+		}
+		if objectErrs != nil {
+			*errs = append(*errs, objectErrs...)
+			return
+		}
+		fail("does not match any allowed form", describeVariants(variants)...)
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			// Пустая строка вместо объекта — «не задано» (см. unmarshalObjectOrString).
+			if s, isString := v.(string); isString && strings.TrimSpace(s) == "" {
+				obj = map[string]any{}
+			} else {
+				fail("expected an object, got " + jsonKind(v))
+				return
+			}
+		}
+		validateObject(schema, obj, path, errs)
+
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			fail("expected an array, got " + jsonKind(v))
+			return
+		}
+		itemSchema, _ := schema["items"].(map[string]any)
+		if itemSchema == nil {
+			return
+		}
+		for i, item := range items {
+			validateValue(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), true, errs)
+		}
+
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			fail("expected a string, got " + jsonKind(v))
+			return
+		}
+		// Пустая строка в необязательном поле хендлерами читается как «по умолчанию».
+		if s == "" && !required {
+			return
+		}
+		if enum, ok := schema["enum"].([]string); ok && !containsString(enum, s) {
+			fail(fmt.Sprintf("unknown value %q", s), enum...)
+			return
+		}
+		if schema["format"] == "date" {
+			if _, err := time.Parse(time.DateOnly, s); err != nil {
+				fail(fmt.Sprintf("expected a date YYYY-MM-DD, got %q", s))
+			}
+		}
+
+	case "integer", "number":
+		if !isNumeric(v) {
+			fail("expected a number, got " + jsonKind(v))
+		}
+
+	case "boolean":
+		if !isBoolean(v) {
+			fail("expected a boolean, got " + jsonKind(v))
+		}
+	}
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string, errs *[]argumentError) {
+	props, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]string)
+
+	for _, name := range required {
+		if v, ok := obj[name]; !ok || v == nil || isBlankObject(props[name], v) {
+			*errs = append(*errs, argumentError{Path: joinPath(path, name), Message: "is required"})
+		}
+	}
+
+	// Порядок имён фиксирован, чтобы текст ошибки не прыгал от вызова к вызову.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := obj[name]
+		prop, ok := props[name].(map[string]any)
+		if !ok || v == nil {
+			continue
+		}
+		isRequired := containsString(required, name)
+		if isRequired && isBlankObject(prop, v) {
+			continue // уже сообщено как отсутствующее
+		}
+		validateValue(prop, v, joinPath(path, name), isRequired, errs)
+	}
+}
+
+// isBlankObject — объект, переданный пустой строкой: для обязательного поля это то же отсутствие.
+// Объектом считается и anyOf с объектной формой (period: объект или выражение).
+func isBlankObject(schema any, v any) bool {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return false
+	}
+	object := s["type"] == "object"
+	variants, _ := s["anyOf"].([]map[string]any)
+	for _, variant := range variants {
+		object = object || variant["type"] == "object"
+	}
+	if !object {
+		return false
+	}
+	str, ok := v.(string)
+	return ok && strings.TrimSpace(str) == ""
+}
+
+// isNumeric — число или его строковая форма, как принимают flexInt/flexFloat.
+func isNumeric(v any) bool {
+	switch t := v.(type) {
+	case float64:
+		return true
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return true
+		}
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	}
+	return false
+}
+
+// isBoolean — булево в любой форме, которую принимает flexBool.
+func isBoolean(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return true
+	case float64:
+		return t == 0 || t == 1
+	case string:
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "", "true", "false", "1", "0":
+			return true
+		}
+	}
+	return false
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describeVariants — допустимые формы anyOf для текста ошибки: тип или перечень значений.
+func describeVariants(variants []map[string]any) []string {
+	var out []string
+	for _, v := range variants {
+		if enum, ok := v["enum"].([]string); ok {
+			for _, e := range enum {
+				out = append(out, strconv.Quote(e))
+			}
+			continue
+		}
+		if t, ok := v["type"].(string); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
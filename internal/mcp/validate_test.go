@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateArgumentsReportsFieldAndAllowedValues(t *testing.T) {
+	cases := []struct {
+		name    string
+		tool    string
+		args    map[string]any
+		path    string
+		message string
+		allowed string
+	}{
+		{
+			name:    "unknown group_by",
+			tool:    ToolSalesReport,
+			args:    map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}, "group_by": []any{"product", "colour"}},
+			path:    "group_by[1]",
+			message: `unknown value "colour"`,
+			allowed: "warehouse",
+		},
+		{
+			name:    "missing period.from",
+			tool:    ToolSalesReport,
+			args:    map[string]any{"period": map[string]any{"to": "2026-01-31"}},
+			path:    "period.from",
+			message: "is required",
+		},
+		{
+			name:    "malformed date",
+			tool:    ToolStockBalance,
+			args:    map[string]any{"date": "31.01.2026"},
+			path:    "date",
+			message: "expected a date YYYY-MM-DD",
+		},
+		{
+			name:    "period as blank string",
+			tool:    ToolCashFlow,
+			args:    map[string]any{"period": ""},
+			path:    "period",
+			message: "is required",
+		},
+		{
+			name:    "ids as a plain string",
+			tool:    ToolProductDetails,
+			args:    map[string]any{"product_ids": "p1"},
+			path:    "product_ids",
+			message: "expected an array",
+		},
+		{
+			name:    "anyOf",
+			tool:    ToolPurchasesReport,
+			args:    map[string]any{"period": map[string]any{"from": "2026-01-01", "to": "2026-01-31"}, "in_transit": "maybe"},
+			path:    "in_transit",
+			message: "does not match",
+			allowed: `"any"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateArguments(toolInputSchema(tc.tool), tc.args)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want exactly one", errs)
+			}
+			e := errs[0]
+			if e.Path != tc.path || !strings.Contains(e.Message, tc.message) {
+				t.Errorf("error = %+v, want %s: %s", e, tc.path, tc.message)
+			}
+			if tc.allowed != "" && !slices.Contains(e.Allowed, tc.allowed) {
+				t.Errorf("allowed = %v, want it to list %s", e.Allowed, tc.allowed)
+			}
+		})
+	}
+}
+
+// Всё, что разбор аргументов терпит, проверка тоже пропускает.
+func TestValidateArgumentsKeepsTolerances(t *testing.T) {
+	period := map[string]any{"from": "2026-01-01", "to": "2026-01-31"}
+
+	cases := []struct {
+		tool string
+		args map[string]any
+	}{
+		{ToolSalesReport, map[string]any{"period": period, "top": "10"}},
+		{ToolSalesReport, map[string]any{"period": period, "top": 10.0, "filters": ""}},
+		{ToolResolveCustomer, map[string]any{"query": "acme", "include_groups": "1", "limit": "5"}},
+		{ToolResolveCustomer, map[string]any{"query": "acme", "include_groups": 0.0}},
+		{ToolPurchasesReport, map[string]any{"period": period, "in_transit": "any"}},
+		{ToolPurchasesReport, map[string]any{"period": period, "in_transit": true}},
+		{ToolStockBalance, map[string]any{"date": "", "unknown_extra": 1.0}},
+		{ToolStockBalance, nil},
+	}
+	for _, tc := range cases {
+		var args any
+		if tc.args != nil {
+			args = tc.args
+		}
+		if errs := validateArguments(toolInputSchema(tc.tool), args); len(errs) != 0 {
+			t.Errorf("%s %v: %+v", tc.tool, tc.args, errs)
+		}
+	}
+}
+
+// Невалидный вызов отвечает -32602 и до 1С не доходит; period строкой-JSON проходит проверку.
+func TestToolsCallValidatesBeforeCalling1C(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	res := rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name":      ToolSalesReport,
+		"arguments": map[string]any{"period": map[string]any{"from": "2026-01-01"}, "measures": []any{"revenue"}},
+	})
+	if res.Error == nil || res.Error.Code != CodeInvalidParams {
+		t.Fatalf("error = %+v, want -32602", res.Error)
+	}
+	for _, want := range []string{"period.to: is required", `measures[0]: unknown value "revenue"`, "allowed: amount"} {
+		if !strings.Contains(res.Error.Message, want) {
+			t.Errorf("message %q does not mention %q", res.Error.Message, want)
+		}
+	}
+	if fake.count() != 0 {
+		t.Errorf("invalid call reached 1C %d times", fake.count())
+	}
+
+	res = rpc(t, h, context.Background(), "tools/call", map[string]any{
+		"name":      ToolSalesReport,
+		"arguments": map[string]any{"period": `{"from":"2026-01-01","to":"2026-01-31"}`},
+	})
+	if res.Error != nil {
+		t.Fatalf("double-encoded period rejected: %+v", res.Error)
+	}
+}
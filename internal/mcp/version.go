@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+)
+
+// ProtocolVersionHeader — заголовок, которым клиент ревизии 2025-06-18 и новее сообщает
+// согласованную версию в каждом HTTP-запросе после initialize.
+const ProtocolVersionHeader = "MCP-Protocol-Version"
+
+// defaultProtocolVersion — ревизия запроса, версия которого неизвестна: вне сессии и без
+// MCP-Protocol-Version, или в stdio до initialize. Спецификация велит считать такой запрос
+// 2025-03-26 — последней ревизией, в которой заголовка ещё не было.
+const defaultProtocolVersion = ProtocolVersionStreamable
+
+// protocolFeatures — возможности, которые гейт включает в ответы только тем, кто о них знает.
+// Клиент старой ревизии вправе строго проверять форму ответа, и незнакомое поле (title,
+// structuredContent) у такого клиента ломает разбор — поэтому новое прячется, а не просто
+// «игнорируется клиентом».
+//
+//	annotations      — Tool.annotations (readOnlyHint и др.);
+//	completions      — capability completions (completion/complete);
+//	titles           — Tool.title;
+//	structuredOutput — Tool.outputSchema в tools/list и structuredContent в tools/call.
+type protocolFeatures struct {
+	annotations      bool
+	completions      bool
+	titles           bool
+	structuredOutput bool
+}
+
+// protocolRevisions — поддерживаемые ревизии MCP. Новая ревизия добавляется сюда вместе со
+// своими возможностями; ответ клиенту собирается по этой таблице, а не по сравнению строк версий.
+var protocolRevisions = map[string]protocolFeatures{
+	ProtocolVersion: {},
+	ProtocolVersionStreamable: {
+		annotations: true,
+		completions: true,
+	},
+	ProtocolVersionStructured: {
+		annotations:      true,
+		completions:      true,
+		titles:           true,
+		structuredOutput: true,
+	},
+}
+
+// negotiateVersion — ответ на protocolVersion клиента в initialize: его же версия, если гейт
+// её поддерживает, иначе новейшая из поддерживаемых. Клиент, которому она не подходит,
+// по спецификации сам разорвёт соединение.
+func negotiateVersion(asked string) string {
+	if _, ok := protocolRevisions[asked]; ok {
+		return asked
+	}
+	return LatestProtocolVersion
+}
+
+// featuresFor — возможности ревизии. Неизвестная или пустая версия — как defaultProtocolVersion.
+func featuresFor(version string) protocolFeatures {
+	if f, ok := protocolRevisions[version]; ok {
+		return f
+	}
+	return protocolRevisions[defaultProtocolVersion]
+}
+
+// protocolFeaturesFrom — возможности ревизии, согласованной для запроса (callContext.protocol).
+func protocolFeaturesFrom(ctx context.Context) protocolFeatures {
+	return featuresFor(callContextFrom(ctx).protocol)
+}
+
+// withProtocol задаёт ревизию запроса, сохраняя остальное в callContext.
+func withProtocol(ctx context.Context, version string) context.Context {
+	cc := callContextFrom(ctx)
+	cc.protocol = version
+	return withCallContext(ctx, cc)
+}
+
+// checkProtocolHeader проверяет MCP-Protocol-Version, если клиент его прислал. Неподдерживаемая
+// версия — 400, как требует спецификация: молча подставить другую значило бы отвечать клиенту
+// в форме, которую он не ждёт. false — ответ уже записан.
+func (h *Handler) checkProtocolHeader(w http.ResponseWriter, r *http.Request, id any) bool {
+	v := r.Header.Get(ProtocolVersionHeader)
+	if v == "" {
+		return true
+	}
+	if _, ok := protocolRevisions[v]; !ok {
+		h.writeJSON(w, http.StatusBadRequest, InvalidRequest(id))
+		return false
+	}
+	return true
+}
+
+// requestProtocol — ревизия HTTP-запроса: согласованная в сессии, иначе из заголовка,
+// иначе defaultProtocolVersion.
+func requestProtocol(r *http.Request, sess *Session) string {
+	if sess != nil && sess.ProtocolVersion != "" {
+		return sess.ProtocolVersion
+	}
+	if v := r.Header.Get(ProtocolVersionHeader); v != "" {
+		return v
+	}
+	return defaultProtocolVersion
+}
+
+// adaptTools убирает из описаний инструментов то, чего ревизия клиента не знает. tools — свежая
+// копия из GetTools, поэтому правится на месте.
+func adaptTools(tools []Tool, f protocolFeatures) {
+	for i := range tools {
+		if !f.titles {
+			tools[i].Title = ""
+		}
+		if !f.annotations {
+			tools[i].Annotations = nil
+		}
+		if !f.structuredOutput {
+			tools[i].OutputSchema = nil
+		}
+	}
+}
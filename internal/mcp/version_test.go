@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// listTools — tools/list в сессии, согласованной на version.
+func listTools(t *testing.T, h *Handler, version string) []map[string]any {
+	t.Helper()
+
+	sid := initialize(t, h, context.Background(), version).Header().Get(SessionHeader)
+	rec := postRPC(t, h, context.Background(), map[string]any{
+		"jsonrpc": "2.0", "id": 2, "method": "tools/list",
+	}, map[string]string{SessionHeader: sid})
+
+	var envelope struct {
+		Result struct {
+			Tools []map[string]any `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("unmarshal %s: %v", rec.Body.String(), err)
+	}
+	if len(envelope.Result.Tools) == 0 {
+		t.Fatalf("tools/list(%s) is empty", version)
+	}
+	return envelope.Result.Tools
+}
+
+// Описания инструментов следуют ревизии сессии: старому клиенту — ни одного незнакомого поля.
+func TestToolsListFollowsNegotiatedRevision(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	cases := []struct {
+		version                          string
+		title, annotations, outputSchema bool
+	}{
+		{ProtocolVersion, false, false, false},
+		{ProtocolVersionStreamable, false, true, false},
+		{ProtocolVersionStructured, true, true, true},
+	}
+	for _, tc := range cases {
+		for _, tool := range listTools(t, h, tc.version) {
+			_, title := tool["title"]
+			_, annotations := tool["annotations"]
+			_, outputSchema := tool["outputSchema"]
+			if title != tc.title || annotations != tc.annotations || outputSchema != tc.outputSchema {
+				t.Errorf("%s: tool %v has title=%v annotations=%v outputSchema=%v",
+					tc.version, tool["name"], title, annotations, outputSchema)
+				break
+			}
+		}
+	}
+}
+
+// structuredContent получает только клиент ревизии 2025-06-18; без сессии и заголовка —
+// ревизия по умолчанию (2025-03-26), а неизвестная версия в заголовке — 400.
+func TestStructuredContentFollowsRevision(t *testing.T) {
+	h, fake := newTestHandler(t)
+	fake.response = `{"columns":[],"rows":[],"totals":{}}`
+
+	call := map[string]any{
+		"jsonrpc": "2.0", "id": 3, "method": "tools/call",
+		"params": map[string]any{"name": ToolStockBalance, "arguments": map[string]any{}},
+	}
+	structured := func(headers map[string]string) (int, bool) {
+		rec := postRPC(t, h, context.Background(), call, headers)
+		var envelope struct {
+			Result map[string]any `json:"result"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &envelope)
+		_, ok := envelope.Result["structuredContent"]
+		return rec.Code, ok
+	}
+
+	if _, ok := structured(nil); ok {
+		t.Error("request of unknown revision got structuredContent")
+	}
+	if _, ok := structured(map[string]string{ProtocolVersionHeader: ProtocolVersionStructured}); !ok {
+		t.Error("2025-06-18 client lost structuredContent")
+	}
+	if code, _ := structured(map[string]string{ProtocolVersionHeader: "1999-01-01"}); code != http.StatusBadRequest {
+		t.Errorf("unsupported %s: HTTP %d, want 400", ProtocolVersionHeader, code)
+	}
+
+	sid := initialize(t, h, context.Background(), ProtocolVersionStructured).Header().Get(SessionHeader)
+	if _, ok := structured(map[string]string{SessionHeader: sid}); !ok {
+		t.Error("session negotiated on 2025-06-18 lost structuredContent")
+	}
+}
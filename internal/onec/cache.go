@@ -1,6 +1,7 @@
 package onec
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"sync"
@@ -88,6 +89,29 @@ func (c *resolveCache) Get(entity, query string, limit int) ([]byte, bool) {
 	return e.payload, true
 }
 
+// CacheHitFunc — наблюдатель попаданий в кэш резолвов: entity — справочник (с суффиксом
+// +groups, если запрос включал группы), query — строка запроса как её прислал вызывающий.
+type CacheHitFunc func(entity, query string)
+
+type cacheHitKey struct{}
+
+// WithCacheHitHook вешает на ctx наблюдателя попаданий в кэш. Так MCP-слой узнаёт, что ответ
+// взят из кэша, и может сообщить об этом клиенту: из возвращаемого значения Resolve* этого не видно.
+func WithCacheHitHook(ctx context.Context, fn CacheHitFunc) context.Context {
+	return context.WithValue(ctx, cacheHitKey{}, fn)
+}
+
+// cached — Get с уведомлением наблюдателя из ctx о попадании.
+func (c *resolveCache) cached(ctx context.Context, entity, query string, limit int) ([]byte, bool) {
+	payload, ok := c.Get(entity, query, limit)
+	if ok {
+		if fn, _ := ctx.Value(cacheHitKey{}).(CacheHitFunc); fn != nil {
+			fn(entity, query)
+		}
+	}
+	return payload, ok
+}
+
 func (c *resolveCache) Set(entity, query string, limit int, payload []byte) {
 	if c == nil {
 		return
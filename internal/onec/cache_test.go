@@ -1,6 +1,7 @@
 package onec
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"runtime"
@@ -237,3 +238,29 @@ func TestClientCloseWithoutCache(t *testing.T) {
 	}, testLogger())
 	client.Close()
 }
+
+// Наблюдатель из ctx видит только попадания, промах проходит молча.
+func TestResolveCacheHitHook(t *testing.T) {
+	c := newResolveCache(time.Minute)
+	defer c.Close()
+
+	var hits []string
+	ctx := WithCacheHitHook(context.Background(), func(entity, query string) {
+		hits = append(hits, entity+"|"+query)
+	})
+
+	if _, ok := c.cached(ctx, "customer", "Acme", 10); ok {
+		t.Fatal("empty cache returned a hit")
+	}
+	c.Set("customer", "acme", 10, []byte(`{}`))
+	if _, ok := c.cached(ctx, "customer", "Acme", 10); !ok {
+		t.Fatal("miss after Set")
+	}
+	if _, ok := c.cached(context.Background(), "customer", "Acme", 10); !ok {
+		t.Fatal("hit without a hook must still be served")
+	}
+
+	if len(hits) != 1 || hits[0] != "customer|Acme" {
+		t.Errorf("hits = %v, want [customer|Acme]", hits)
+	}
+}
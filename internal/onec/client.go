@@ -29,6 +29,8 @@ type Settings struct {
 	DefaultTenant string
 	// ResolveCacheTTL — TTL кэша resolve_*. <= 0 отключает кэш.
 	ResolveCacheTTL time.Duration
+	// Location — часовой пояс базы для относительных периодов. nil — локальная зона гейта.
+	Location *time.Location
 }
 
 // Client — HTTP-клиент одной базы 1С. Экземпляр создаётся на каждый тенант:
@@ -43,6 +45,7 @@ type Client struct {
 	defaultTenant    string
 	logger           *slog.Logger
 	resolveCache     *resolveCache
+	location         *time.Location
 }
 
 func NewClient(s Settings, logger *slog.Logger) *Client {
@@ -61,9 +64,19 @@ func NewClient(s Settings, logger *slog.Logger) *Client {
 		defaultTenant: s.DefaultTenant,
 		logger:        logger,
 		resolveCache:  newResolveCache(s.ResolveCacheTTL),
+		location:      s.Location,
 	}
 }
 
+// Now — текущее время в часовом поясе базы: «сегодня» для относительных периодов — это сегодня
+// там, где работает учёт, а не на сервере гейта.
+func (c *Client) Now() time.Time {
+	if c.location == nil {
+		return time.Now()
+	}
+	return time.Now().In(c.location)
+}
+
 // Close освобождает фоновые ресурсы клиента (сейчас — janitor кэша резолвов).
 // Вызывается реестром для баз, вытесненных при пересборке; после него клиент использовать нельзя.
 func (c *Client) Close() {
@@ -165,7 +178,7 @@ func (c *Client) ResolveCustomer(ctx context.Context, query string, limit int, i
 	if includeGroups {
 		cacheKey = "customer+groups"
 	}
-	if cached, ok := c.resolveCache.Get(cacheKey, query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, cacheKey, query, limit); ok {
 		var resp ResolveCustomerResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -207,7 +220,7 @@ func (c *Client) ResolveWarehouse(ctx context.Context, query string, limit int)
 	if costScoped(ctx) {
 		cacheKey = "warehouse+production"
 	}
-	if cached, ok := c.resolveCache.Get(cacheKey, query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, cacheKey, query, limit); ok {
 		var resp ResolveWarehouseResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -235,7 +248,7 @@ func (c *Client) ResolveMaterial(ctx context.Context, query string, limit int, i
 	if includeGroups {
 		cacheKey = "material+groups"
 	}
-	if cached, ok := c.resolveCache.Get(cacheKey, query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, cacheKey, query, limit); ok {
 		var resp ResolveMaterialResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -259,7 +272,7 @@ func (c *Client) ResolveProduct(ctx context.Context, query string, limit int, in
 	if includeGroups {
 		cacheKey = "product+groups"
 	}
-	if cached, ok := c.resolveCache.Get(cacheKey, query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, cacheKey, query, limit); ok {
 		var resp ResolveProductResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -279,7 +292,7 @@ func (c *Client) ResolveProduct(ctx context.Context, query string, limit int, in
 }
 
 func (c *Client) ResolveSalesChannel(ctx context.Context, query string, limit int) (*ResolveSalesChannelResponse, error) {
-	if cached, ok := c.resolveCache.Get("sales_channel", query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, "sales_channel", query, limit); ok {
 		var resp ResolveSalesChannelResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -299,7 +312,7 @@ func (c *Client) ResolveSalesChannel(ctx context.Context, query string, limit in
 }
 
 func (c *Client) ResolveCash(ctx context.Context, query string, limit int) (*ResolveCashResponse, error) {
-	if cached, ok := c.resolveCache.Get("cash", query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, "cash", query, limit); ok {
 		var resp ResolveCashResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -325,7 +338,7 @@ func (c *Client) ResolveCostArticle(ctx context.Context, query string, limit int
 	if includeGroups {
 		cacheKey = "cost_article+groups"
 	}
-	if cached, ok := c.resolveCache.Get(cacheKey, query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, cacheKey, query, limit); ok {
 		var resp ResolveCostArticleResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -345,7 +358,7 @@ func (c *Client) ResolveCostArticle(ctx context.Context, query string, limit int
 }
 
 func (c *Client) ResolveOperation(ctx context.Context, query string, limit int) (*ResolveOperationResponse, error) {
-	if cached, ok := c.resolveCache.Get("operation", query, limit); ok {
+	if cached, ok := c.resolveCache.cached(ctx, "operation", query, limit); ok {
 		var resp ResolveOperationResponse
 		if err := json.Unmarshal(cached, &resp); err == nil {
 			return &resp, nil
@@ -145,11 +145,23 @@ type ResolveSalesChannelResponse struct {
 type Period struct {
 	From string `json:"from"`
 	To   string `json:"to"`
+	// Expr — относительный период (last_month, 2025-Q3, …), пришедший строкой вместо объекта.
+	// В 1С не уходит: гейт сначала разворачивает его в даты (см. Resolve).
+	Expr string `json:"-"`
 }
 
 // UnmarshalJSON терпит тот же косяк LLM, что и фильтры: period может прийти объектом,
 // объектом-в-строке (двойное кодирование) или пустой строкой. Покрывает все отчёты с period.
+// Строка, которая не объект, — выражение относительного периода: оно сохраняется в Expr.
 func (p *Period) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		if s = strings.TrimSpace(s); s != "" && !strings.HasPrefix(s, "{") {
+			*p = Period{Expr: s}
+			return nil
+		}
+	}
+
 	type alias Period
 	var a alias
 	if err := unmarshalObjectOrString(data, &a); err != nil {
@@ -0,0 +1,128 @@
+package onec
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Относительные периоды. «Прошлый квартал» модель раньше считала сама и регулярно ошибалась
+// на концах месяцев и стыках годов; теперь она передаёт выражение, а гейт разворачивает его
+// по своим часам в часовом поясе базы (Client.Now) и возвращает получившиеся даты рядом с
+// ReportEcho.Period — чтобы было видно, за что именно посчитан отчёт.
+//
+// «this_*» — с начала периода по сегодня включительно, «last_*» — предыдущий период целиком,
+// last_N_days — N дней по сегодня включительно. Неделя — ISO (с понедельника).
+
+// ResolvedPeriod — эхо развёрнутого выражения.
+type ResolvedPeriod struct {
+	Expression string `json:"expression"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Timezone   string `json:"timezone"`
+}
+
+var (
+	lastDaysRe = regexp.MustCompile(`^last_(\d+)_days$`)
+	yearRe     = regexp.MustCompile(`^(\d{4})$`)
+	monthRe    = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	quarterRe  = regexp.MustCompile(`^(\d{4})-q([1-4])$`)
+	weekRe     = regexp.MustCompile(`^(\d{4})-w(\d{2})$`)
+)
+
+// maxLastDays — потолок last_N_days: десять лет. Больше — почти наверняка опечатка, а отчёт
+// за такой период 1С будет считать до таймаута.
+const maxLastDays = 3660
+
+// Resolve разворачивает Expr в даты на момент now; «сегодня» — дата now в его зоне. Период без
+// Expr возвращается как есть.
+func (p Period) Resolve(now time.Time) (Period, error) {
+	if p.Expr == "" {
+		return p, nil
+	}
+	from, to, err := resolvePeriodExpr(strings.ToLower(strings.TrimSpace(p.Expr)), now)
+	if err != nil {
+		return Period{}, err
+	}
+	return Period{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Expr: p.Expr}, nil
+}
+
+func resolvePeriodExpr(expr string, now time.Time) (from, to time.Time, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	quarter := time.Date(today.Year(), (today.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	year := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+	switch expr {
+	case "today":
+		return today, today, nil
+	case "yesterday":
+		d := today.AddDate(0, 0, -1)
+		return d, d, nil
+	case "this_week", "wtd":
+		return monday, today, nil
+	case "last_week":
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), nil
+	case "this_month", "mtd":
+		return month, today, nil
+	case "last_month":
+		return month.AddDate(0, -1, 0), month.AddDate(0, 0, -1), nil
+	case "this_quarter", "qtd":
+		return quarter, today, nil
+	case "last_quarter":
+		return quarter.AddDate(0, -3, 0), quarter.AddDate(0, 0, -1), nil
+	case "this_year", "ytd":
+		return year, today, nil
+	case "last_year":
+		return year.AddDate(-1, 0, 0), year.AddDate(0, 0, -1), nil
+	}
+
+	if m := lastDaysRe.FindStringSubmatch(expr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > maxLastDays {
+			return from, to, fmt.Errorf("period %q: N must be between 1 and %d", expr, maxLastDays)
+		}
+		return today.AddDate(0, 0, 1-n), today, nil
+	}
+	if m := yearRe.FindStringSubmatch(expr); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		from = time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, -1), nil
+	}
+	if m := monthRe.FindStringSubmatch(expr); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		mon, _ := strconv.Atoi(m[2])
+		if mon < 1 || mon > 12 {
+			return from, to, fmt.Errorf("period %q: month must be 01..12", expr)
+		}
+		from = time.Date(y, time.Month(mon), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, -1), nil
+	}
+	if m := quarterRe.FindStringSubmatch(expr); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		q, _ := strconv.Atoi(m[2])
+		from = time.Date(y, time.Month(q*3-2), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 3, -1), nil
+	}
+	if m := weekRe.FindStringSubmatch(expr); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		w, _ := strconv.Atoi(m[2])
+		// Первая ISO-неделя — та, в которую попадает 4 января.
+		jan4 := time.Date(y, 1, 4, 0, 0, 0, 0, time.UTC)
+		from = jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(w-1)*7)
+		if isoYear, _ := from.ISOWeek(); w < 1 || isoYear != y {
+			return time.Time{}, time.Time{}, fmt.Errorf("period %q: year %d has no week %d", expr, y, w)
+		}
+		return from, from.AddDate(0, 0, 6), nil
+	}
+	if d, err := time.Parse(time.DateOnly, expr); err == nil {
+		return d, d, nil
+	}
+
+	return from, to, fmt.Errorf("unknown period %q: expected today, yesterday, this_week, last_week, "+
+		"this_month, last_month, this_quarter, last_quarter, this_year, last_year, ytd, last_N_days, "+
+		"YYYY, YYYY-MM, YYYY-QN, YYYY-WNN or YYYY-MM-DD", expr)
+}
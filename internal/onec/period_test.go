@@ -0,0 +1,71 @@
+package onec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPeriodResolve(t *testing.T) {
+	// Четверг, 15 января 2026, поздний вечер по Киеву.
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+	now := time.Date(2026, 1, 15, 23, 30, 0, 0, kyiv)
+
+	cases := []struct {
+		expr, from, to string
+	}{
+		{"today", "2026-01-15", "2026-01-15"},
+		{"yesterday", "2026-01-14", "2026-01-14"},
+		{"this_week", "2026-01-12", "2026-01-15"},
+		{"last_week", "2026-01-05", "2026-01-11"},
+		{"last_month", "2025-12-01", "2025-12-31"},
+		{"last_quarter", "2025-10-01", "2025-12-31"},
+		{"ytd", "2026-01-01", "2026-01-15"},
+		{"last_year", "2025-01-01", "2025-12-31"},
+		{"last_30_days", "2025-12-17", "2026-01-15"},
+		{"2025-Q3", "2025-07-01", "2025-09-30"},
+		{"2024-02", "2024-02-01", "2024-02-29"},
+		{"2025-W14", "2025-03-31", "2025-04-06"},
+		{"2026-W01", "2025-12-29", "2026-01-04"},
+		{"2025", "2025-01-01", "2025-12-31"},
+	}
+	for _, tc := range cases {
+		got, err := Period{Expr: tc.expr}.Resolve(now)
+		if err != nil || got.From != tc.from || got.To != tc.to {
+			t.Errorf("%s = %s..%s, %v; want %s..%s", tc.expr, got.From, got.To, err, tc.from, tc.to)
+		}
+	}
+
+	// «Сегодня» — дата в зоне базы, а не в UTC.
+	late := time.Date(2026, 1, 15, 1, 0, 0, 0, kyiv)
+	if got, _ := (Period{Expr: "today"}).Resolve(late); got.From != "2026-01-15" {
+		t.Errorf("today at 01:00 Kyiv = %s", got.From)
+	}
+
+	for _, bad := range []string{"next_month", "2025-Q5", "2025-13", "2025-W54", "last_0_days"} {
+		if _, err := (Period{Expr: bad}).Resolve(now); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestPeriodUnmarshalExpression(t *testing.T) {
+	var p Period
+	if err := json.Unmarshal([]byte(`"last_month"`), &p); err != nil || p.Expr != "last_month" || p.From != "" {
+		t.Errorf("expression: %+v, %v", p, err)
+	}
+
+	p = Period{}
+	if err := json.Unmarshal([]byte(`"{\"from\":\"2026-01-01\",\"to\":\"2026-01-31\"}"`), &p); err != nil || p.From != "2026-01-01" || p.Expr != "" {
+		t.Errorf("object in string: %+v, %v", p, err)
+	}
+
+	// Выражение в 1С не уходит.
+	data, _ := json.Marshal(Period{From: "2026-01-01", To: "2026-01-31", Expr: "2026-01"})
+	if string(data) != `{"from":"2026-01-01","to":"2026-01-31"}` {
+		t.Errorf("marshal = %s", data)
+	}
+}
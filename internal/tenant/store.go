@@ -58,11 +58,41 @@ func (s *Store) migrate() error {
 			return fmt.Errorf("tenant: migrate: %w", err)
 		}
 	}
+
+	// Колонки, появившиеся после первой версии таблицы.
+	return s.addColumnIfMissing("tenants", "timezone", `TEXT NOT NULL DEFAULT ''`)
+}
+
+// addColumnIfMissing — идемпотентный ALTER TABLE: SQLite не умеет ADD COLUMN IF NOT EXISTS,
+// поэтому сначала смотрим в PRAGMA table_info.
+func (s *Store) addColumnIfMissing(table, column, decl string) error {
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("tenant: inspect %s: %w", table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("tenant: inspect %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("tenant: inspect %s: %w", table, err)
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl)); err != nil {
+		return fmt.Errorf("tenant: add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
 const tenantColumns = `slug, name, enabled, base_url, username, password,
-	timeout_ms, report_timeout_ms, resolve_cache_ttl_sec, tenant_header, default_tenant,
+	timeout_ms, report_timeout_ms, resolve_cache_ttl_sec, tenant_header, default_tenant, timezone,
 	mcp_token, api_token, dev_access_key, default_scopes, supported_scopes, created_at, updated_at`
 
 // List — все базы, включая выключенные, в порядке слага (детерминированный вывод в /admin и логах).
@@ -110,9 +140,9 @@ func (s *Store) Create(ctx context.Context, t *Tenant) error {
 
 	_, err = s.db.ExecContext(ctx,
 		`INSERT INTO tenants (`+tenantColumns+`)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		t.Slug, t.Name, boolToInt(t.Enabled), t.BaseURL, t.Username, t.Password,
-		t.TimeoutMs, t.ReportTimeoutMs, t.ResolveCacheTTLSec, t.TenantHeader, t.DefaultTenant,
+		t.TimeoutMs, t.ReportTimeoutMs, t.ResolveCacheTTLSec, t.TenantHeader, t.DefaultTenant, t.Timezone,
 		t.MCPToken, t.APIToken, t.DevAccessKey, defaults, supported,
 		t.CreatedAt.Unix(), t.UpdatedAt.Unix(),
 	)
@@ -142,12 +172,12 @@ func (s *Store) Update(ctx context.Context, t *Tenant) error {
 		`UPDATE tenants SET
 			name = ?, enabled = ?, base_url = ?, username = ?, password = ?,
 			timeout_ms = ?, report_timeout_ms = ?, resolve_cache_ttl_sec = ?,
-			tenant_header = ?, default_tenant = ?,
+			tenant_header = ?, default_tenant = ?, timezone = ?,
 			mcp_token = ?, api_token = ?, dev_access_key = ?,
 			default_scopes = ?, supported_scopes = ?, updated_at = ?
 		 WHERE slug = ?`,
 		t.Name, boolToInt(t.Enabled), t.BaseURL, t.Username, t.Password,
-		t.TimeoutMs, t.ReportTimeoutMs, t.ResolveCacheTTLSec, t.TenantHeader, t.DefaultTenant,
+		t.TimeoutMs, t.ReportTimeoutMs, t.ResolveCacheTTLSec, t.TenantHeader, t.DefaultTenant, t.Timezone,
 		t.MCPToken, t.APIToken, t.DevAccessKey, defaults, supported, t.UpdatedAt.Unix(),
 		t.Slug,
 	)
@@ -195,7 +225,7 @@ func scanTenant(sc scanner) (*Tenant, error) {
 	)
 	err := sc.Scan(
 		&t.Slug, &t.Name, &enabled, &t.BaseURL, &t.Username, &t.Password,
-		&t.TimeoutMs, &t.ReportTimeoutMs, &t.ResolveCacheTTLSec, &t.TenantHeader, &t.DefaultTenant,
+		&t.TimeoutMs, &t.ReportTimeoutMs, &t.ResolveCacheTTLSec, &t.TenantHeader, &t.DefaultTenant, &t.Timezone,
 		&t.MCPToken, &t.APIToken, &t.DevAccessKey, &defaults, &supported, &createdAt, &updated,
 	)
 	if err != nil {
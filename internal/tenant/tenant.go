@@ -37,6 +37,10 @@ type Tenant struct {
 	TenantHeader       string
 	DefaultTenant      string
 
+	// Timezone — IANA-зона базы (Europe/Kyiv): в ней гейт считает относительные периоды
+	// (today, last_month, …). Пусто — локальная зона процесса гейта.
+	Timezone string
+
 	// MCPToken — статический Bearer для /{slug}/mcp. Работает только при oauth.enabled=false.
 	MCPToken string
 	// APIToken — Bearer для REST /{slug}/resolve/*, /{slug}/reports/*. Пусто = REST не публикуется.
@@ -70,6 +74,21 @@ func (t *Tenant) ResolveCacheTTL() time.Duration {
 	return time.Duration(t.ResolveCacheTTLSec) * time.Second
 }
 
+// Location — зона Timezone; без неё — локальная зона. Validate уже проверил имя, поэтому ошибка
+// здесь означает зону, которой нет в tzdata процесса (база сохранена другой сборкой): тогда
+// локальная зона вместе с ошибкой — вызывающий должен о ней сообщить, иначе «вчера» молча
+// считается не в том поясе.
+func (t *Tenant) Location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return time.Local, fmt.Errorf("timezone %q: %w", t.Timezone, err)
+	}
+	return loc, nil
+}
+
 // slugRe — слаг попадает в путь URL и в OAuth issuer: только нижний регистр, цифры и дефис.
 var slugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
 
@@ -88,6 +107,7 @@ func (t *Tenant) Normalize() {
 	t.Slug = strings.ToLower(strings.TrimSpace(t.Slug))
 	t.Name = strings.TrimSpace(t.Name)
 	t.BaseURL = strings.TrimRight(strings.TrimSpace(t.BaseURL), "/")
+	t.Timezone = strings.TrimSpace(t.Timezone)
 
 	if t.Name == "" {
 		t.Name = t.Slug
@@ -131,6 +151,11 @@ func (t *Tenant) Validate() error {
 	if t.TimeoutMs < 0 || t.ReportTimeoutMs < 0 {
 		return fmt.Errorf("таймауты не могут быть отрицательными")
 	}
+	if t.Timezone != "" {
+		if _, err := time.LoadLocation(t.Timezone); err != nil {
+			return fmt.Errorf("неизвестный часовой пояс %q, ожидается имя IANA, например Europe/Kyiv", t.Timezone)
+		}
+	}
 	return nil
 }
 